@@ -2,22 +2,30 @@ package main
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 
 	"gemini-cli-go/internal/api"
+	"gemini-cli-go/internal/checkpoint"
 	"gemini-cli-go/internal/filesystem"
 	"gemini-cli-go/internal/auth"
+	"gemini-cli-go/internal/command"
 	"gemini-cli-go/internal/errors"
+	"gemini-cli-go/internal/mcp"
+	"gemini-cli-go/internal/memory"
 	config_pkg "gemini-cli-go/internal/config"
 	tool_pkg "gemini-cli-go/internal/tool"
 	"gemini-cli-go/internal/telemetry"
-	"gemini-cli-go/internal/shared"
+	"gemini-cli-go/internal/ui"
 
 	"github.com/google/generative-ai-go/genai"
 	"github.com/google/uuid" // Import for sessionId
@@ -32,9 +40,25 @@ var rootCmd = &cobra.Command{
 	
 Gemini CLI allows you to interact with Gemini models,
 and automate AI-powered workflows directly from your terminal.`,
+	// Flags are parsed by the time PersistentPreRun runs, so the configuration
+	// sees the values given on the command line.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		initializeCli(cmd)
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if mcpManager != nil {
+			mcpManager.Close()
+		}
+		telemetry.ShutdownTelemetry(context.Background())
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		// Default behavior when no subcommand is provided
-		fmt.Println("Welcome to Gemini CLI! Use 'gemini --help' for more information.")
+		// Default behavior when no subcommand is provided:
+		// interactive REPL on a TTY without a prompt, non-interactive otherwise.
+		if isStdinTTY() && globalCliConfig.Prompt == "" && globalCliConfig.JSONSchema == "" {
+			runInteractive(nil)
+			return
+		}
+		runNonInteractive()
 	},
 }
 
@@ -44,115 +68,30 @@ var chatCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1), // プロンプトが1つだけ必要
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
-		var client *api.Client
-		var err error
-
-		// Use globalCliConfig for authentication and model
-		if globalCliConfig.SelectedAuthType != nil && *globalCliConfig.SelectedAuthType == "oauth" {
-			token, err := auth.LoadToken()
-			if err == nil && token.Valid() {
-				// Use OAuth2 client
-				oauthConfig := auth.GetOAuth2Config(os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET")) // Still using env for client ID/secret for now
-				httpClient := auth.GetHTTPClient(ctx, oauthConfig, token)
-				client, err = api.NewClient(ctx, "", httpClient, globalCliConfig.Model) // Pass modelName
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
-					os.Exit(1)
-				}
-				fmt.Println("Using OAuth2 for authentication.")
-			} else {
-				fmt.Println("Error: OAuth2 selected but no valid token found. Please run 'gemini auth'.")
-				os.Exit(1)
-			}
-		} else { // Fallback to API key if no auth type selected or not oauth
-			apiKey := os.Getenv("GEMINI_API_KEY") // Still using env for API key for now
-			if apiKey == "" {
-				fmt.Println("Error: GEMINI_API_KEY environment variable not set and no valid OAuth2 token found.")
-				fmt.Println("Please get your API key from https://aistudio.google.com/apikey or run 'gemini auth'.")
-				os.Exit(1)
-			}
-			client, err = api.NewClient(ctx, apiKey, nil, globalCliConfig.Model) // Pass modelName
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Println("Using API key for authentication.")
-		}
-
 		prompt := args[0]
+		if globalCliConfig.JSONSchema != "" {
+			runStructuredOutput(ctx, mustCreateClient(ctx, false), prompt)
+			return
+		}
+		client := mustCreateClient(ctx, true)
 
-		toolRegistry := tool_pkg.NewToolRegistry()
-		toolRegistry.RegisterTool(&tool_pkg.ReadTool{})
-		toolRegistry.RegisterTool(&tool_pkg.ListFilesTool{})
-		toolRegistry.RegisterTool(&tool_pkg.WriteFileTool{}) // WriteFileToolを登録
-
-		currentPrompt := prompt
-		for { // 無限ループで対話を続ける
-			fmt.Printf("Sending prompt to Gemini: \"%s\"\n", currentPrompt)
-			stream, err := client.GenerateContentStream(ctx, currentPrompt, &shared.Tools{FunctionDeclarations: toolRegistry.GetFunctionDeclarations()})
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error generating content: %v\n", err)
-				os.Exit(1)
-			}
-
-			var fullTextResponse string
-			var functionCalls []shared.FunctionCall
-
-			// ストリームから応答を読み込むループ
-			for {
-				resp, err := stream.Next()
-				if err == io.EOF {
-					break // ストリームの終端に達したらループを抜ける
-				}
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error streaming response: %v\n", err)
-					os.Exit(1)
-				}
-
-				if len(resp.Candidates) == 0 {
-					continue
-				}
-
-				candidate := resp.Candidates[0]
-				for _, part := range candidate.Content.Parts {
-					if text, ok := part.(genai.Text); ok {
-							fmt.Print(string(text))
-							fullTextResponse += string(text)
-						} else if functionCall, ok := part.(genai.FunctionCall); ok {
-							functionCalls = append(functionCalls, shared.FunctionCall{
-								Name: functionCall.Name,
-								Args: functionCall.Args,
-							})
-					}
-				}
-			}
-
-			fmt.Println() // ストリーム応答の後に改行を追加
-
-			if len(functionCalls) > 0 {
-				fc := functionCalls[0] // 簡略化のため、一度に1つの関数呼び出しを想定
-				fmt.Printf("\nGemini called tool: %s with args: %v\n", fc.Name, fc.Args)
-
-				calledTool, ok := toolRegistry.GetTool(fc.Name)
-				if !ok {
-					fmt.Fprintf(os.Stderr, "Error: Tool %s not found.\n", fc.Name)
-					os.Exit(1)
-				}
+		toolRegistry := newToolRegistry()
 
-				toolOutput, err := calledTool.Execute(context.Background(), fc.Args)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error executing tool %s: %v\n", fc.Name, err)
-					os.Exit(1)
-				}
+		// 会話履歴を保持し、ツール呼び出しのループはAgentに任せる
+		conversation := api.NewConversation(client)
+		executor, err := api.NewExecutor(globalCliConfig, toolRegistry, newConfirmer(os.Stdout), conversation)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		agent := api.NewAgent(conversation, toolRegistry, executor)
 
-				fmt.Printf("Tool %s output:\n%s\n", fc.Name, toolOutput)
-				currentPrompt = fmt.Sprintf("Tool %s returned: %s", fc.Name, toolOutput) // ツール出力をGeminiに送り返す
-			} else if fullTextResponse != "" {
-				break // Geminiがテキストで応答したら対話ループを終了
-			} else {
-				fmt.Println("No text or function call in Gemini's response.")
-				break // テキストも関数呼び出しもなければループを終了
-			}
+		fmt.Printf("Sending prompt to Gemini: \"%s\"\n", prompt)
+		err = agent.RunTurn(ctx, &api.TextHandler{Out: os.Stdout}, genai.Text(prompt))
+		fmt.Println() // ストリーム応答の後に改行を追加
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
 	},
 }
@@ -181,20 +120,22 @@ var listFilesCmd = &cobra.Command{
 		dirPath := args[0]
 		extensions, _ := cmd.Flags().GetStringSlice("ext")
 
-		files, err := filesystem.WalkDir(dirPath, extensions)
+		found := false
+		err := filesystem.Walk(dirPath, walkOptions(extensions), func(entry filesystem.Entry) error {
+			if !found {
+				fmt.Printf("Files in %s (filtered by %v):\n", dirPath, extensions)
+				found = true
+			}
+			fmt.Printf("- %s (size: %d bytes)\n", entry.Path, entry.Size)
+			return nil
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error listing files: %v\n", err)
 			os.Exit(1)
 		}
 
-		if len(files) == 0 {
+		if !found {
 			fmt.Printf("No files found in %s with extensions %v\n", dirPath, extensions)
-			return
-		}
-
-		fmt.Printf("Files in %s (filtered by %v):\n", dirPath, extensions)
-		for _, file := range files {
-			fmt.Printf("- %s (size: %d bytes)\n", file.Path, len(file.Content))
 		}
 	},
 }
@@ -207,19 +148,28 @@ var contextCmd = &cobra.Command{
 		dirPath := args[0]
 		extensions, _ := cmd.Flags().GetStringSlice("ext")
 
-		files, err := filesystem.WalkDir(dirPath, extensions)
+		files, skipped, err := readContextFiles(cmd.Context(), dirPath, extensions, classifyOptions(cmd))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error walking directory: %v\n", err)
 			os.Exit(1)
 		}
+		reportSkippedFiles(skipped)
 
 		if len(files) == 0 {
 			fmt.Printf("No files found in %s with extensions %v to generate context.\n", dirPath, extensions)
 			return
 		}
 
-		formattedContext := api.FormatFilesForGemini(files)
-		fmt.Println(formattedContext)
+		// Tokens are estimated locally, so that the command works without credentials.
+		budget, _ := cmd.Flags().GetInt("token-budget")
+		packer := &api.ContextPacker{Budget: budget}
+		packed, err := packer.Pack(cmd.Context(), "", files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error packing context: %v\n", err)
+			os.Exit(1)
+		}
+		reportPackedContext(packed)
+		fmt.Println(packed.Format())
 	},
 }
 
@@ -229,39 +179,7 @@ var generateCodeCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1), // プロンプトが1つだけ必要
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
-		var client *api.Client
-		var err error
-		// Use globalCliConfig for authentication and model
-		if globalCliConfig.SelectedAuthType != nil && *globalCliConfig.SelectedAuthType == "oauth" {
-			token, err := auth.LoadToken()
-			if err == nil && token.Valid() {
-				// Use OAuth2 client
-				oauthConfig := auth.GetOAuth2Config(os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET")) // Still using env for client ID/secret for now
-				httpClient := auth.GetHTTPClient(ctx, oauthConfig, token)
-				client, err = api.NewClient(ctx, "", httpClient, globalCliConfig.Model) // Pass modelName
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
-					os.Exit(1)
-				}
-				fmt.Println("Using OAuth2 for authentication.")
-			} else {
-				fmt.Println("Error: OAuth2 selected but no valid token found. Please run 'gemini auth'.")
-				os.Exit(1)
-			}
-		} else { // Fallback to API key if no auth type selected or not oauth
-			apiKey := os.Getenv("GEMINI_API_KEY") // Still using env for API key for now
-			if apiKey == "" {
-				fmt.Println("Error: GEMINI_API_KEY environment variable not set and no valid OAuth2 token found.")
-				fmt.Println("Please get your API key from https://aistudio.google.com/apikey or run 'gemini auth'.")
-				os.Exit(1)
-			}
-			client, err = api.NewClient(ctx, apiKey, nil, globalCliConfig.Model) // Pass modelName
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Println("Using API key for authentication.")
-		}
+		client := mustCreateClient(ctx, true)
 
 		prompt := args[0]
 		contextDir, _ := cmd.Flags().GetString("context-dir")
@@ -269,13 +187,21 @@ var generateCodeCmd = &cobra.Command{
 
 		fullPrompt := prompt
 		if contextDir != "" {
-			files, err := filesystem.WalkDir(contextDir, extensions)
+			files, skipped, err := readContextFiles(ctx, contextDir, extensions, classifyOptions(cmd))
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error reading context directory: %v\n", err)
 				os.Exit(1)
 			}
-			if len(files) > 0 {
-				formattedContext := api.FormatFilesForGemini(files)
+			budget, _ := cmd.Flags().GetInt("token-budget")
+			packer := &api.ContextPacker{Counter: client, Budget: budget}
+			packed, err := packer.Pack(ctx, prompt, files)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error packing context: %v\n", err)
+				os.Exit(1)
+			}
+			reportPackedContext(packed)
+			if len(files) > 0 || len(skipped) > 0 {
+				formattedContext := packed.Format() + api.FormatSkippedFiles(skipped)
 				fullPrompt = fmt.Sprintf("%s\n\nHere is the context:\n%s", prompt, formattedContext)
 			}
 		}
@@ -284,6 +210,10 @@ var generateCodeCmd = &cobra.Command{
         
         // GenerateContentStream を使用
         stream, err := client.GenerateContentStream(ctx, fullPrompt, nil)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
+            os.Exit(1)
+        }
         
         var generatedContent string
         for {
@@ -355,6 +285,79 @@ log in and grant permissions.`,
 	},
 }
 
+var memoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "Inspects the instruction files (GEMINI.md) sent to the model as memory",
+}
+
+var memoryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the instruction files loaded for the current directory and where they came from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(loadMemory().Summary())
+	},
+}
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Works with the Model Context Protocol (MCP)",
+}
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Offers the CLI's tools to MCP clients over stdio",
+	Long: `Runs an MCP server on stdin and stdout that offers the built-in tools and those of the
+toolDiscoveryCommand setting, as far as the coreTools and excludeTools settings allow them.
+Tools run without confirmation; approving calls is up to the client.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := mcp.NewServer(newServedToolRegistry()).Serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [checkpointId]",
+	Short: "Lists checkpoints, or rolls files and conversation back to one",
+	Long: `Without an argument, lists the checkpoints saved before tools changed files (see --checkpointing).
+With a checkpoint ID, restores the files to that checkpoint and, on a terminal, continues
+the conversation as it was before the turn that made the call.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := checkpoint.StoreForConfig(globalCliConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(args) == 0 {
+			if err := command.PrintCheckpoints(os.Stdout, store); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		restored, err := store.Load(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		result, err := store.Restore(context.Background(), restored, globalCliConfig.SessionID, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring checkpoint: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(result.Summary())
+		fmt.Printf("Undo with gemini restore %s\n", result.Backup.ID)
+		if isStdinTTY() {
+			fmt.Printf("Continuing the conversation from before the turn that called %s.\n", restored.ToolCall.Name)
+			runInteractive(result.History)
+		}
+	},
+}
+
 var writeFileCmd = &cobra.Command{
 	Use:   "write-file [filePath] [content]",
 	Short: "Writes content to a specified file",
@@ -380,6 +383,11 @@ func init() {
 	rootCmd.AddCommand(generateCodeCmd)
 	rootCmd.AddCommand(authCmd)
 	rootCmd.AddCommand(writeFileCmd)
+	rootCmd.AddCommand(memoryCmd)
+	memoryCmd.AddCommand(memoryListCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.AddCommand(mcpServeCmd)
 
 	// Add global flags from config.ts to rootCmd
 	rootCmd.PersistentFlags().StringP("model", "m", os.Getenv("GEMINI_MODEL"), "Model") // Default from env or config.go
@@ -395,6 +403,14 @@ func init() {
 	rootCmd.PersistentFlags().String("telemetry-otlp-endpoint", "", "Set the OTLP endpoint for telemetry. Overrides environment variables and settings files.")
 	rootCmd.PersistentFlags().Bool("telemetry-log-prompts", false, "Enable or disable logging of user prompts for telemetry. Overrides settings files.")
 	rootCmd.PersistentFlags().BoolP("checkpointing", "c", false, "Enables checkpointing of file edits")
+	rootCmd.PersistentFlags().String("output-format", "text", "How non-interactive runs print their result: text (the answer only), json (one object at the end) or stream-json (one event per line).")
+	rootCmd.PersistentFlags().String("json-schema", "", "Answer with JSON matching this JSON Schema, given inline or as a file path. Exits with code 3 if the answer does not match.")
+	rootCmd.PersistentFlags().String("system-instruction", "", "System instruction sent before the loaded memory. Overrides the systemInstruction setting.")
+	rootCmd.PersistentFlags().Float32("temperature", 0, "Sampling temperature, 0 to 2. Overrides generationConfig and the model's default.")
+	rootCmd.PersistentFlags().Float32("top-p", 0, "Nucleus sampling probability, 0 to 1. Overrides generationConfig and the model's default.")
+	rootCmd.PersistentFlags().Int32("max-output-tokens", 0, "Most tokens per response. Overrides generationConfig and the model's default.")
+	rootCmd.PersistentFlags().StringSlice("stop-sequences", nil, "Comma-separated sequences that stop generation (at most 5).")
+	rootCmd.PersistentFlags().Int32("candidate-count", 0, "Number of responses to generate, 1 to 8; only the first is shown.")
 
 
 	// list-files コマンドに --ext フラグを追加
@@ -402,34 +418,47 @@ func init() {
 
 	// context コマンドに --ext フラグを追加
 	contextCmd.Flags().StringSliceP("ext", "e", []string{}, "Comma-separated list of file extensions to filter (e.g., .go,.txt)")
+	contextCmd.Flags().Int64("max-file-size", 0, "Skip files larger than this many bytes (default: fileFiltering.maxFileSize, or 1 MiB)")
+	contextCmd.Flags().Int("token-budget", api.DefaultContextTokenBudget, "Most tokens of file content to include")
 
 	// generate-code コマンドに --context-dir と --ext フラグを追加
-	generateCodeCmd.Flags().StringP("context-dir", "c", "", "Directory to use as context for code generation")
+	// -c は --checkpointing のショートハンドと衝突するため、ここでは長い形式のみとする
+	generateCodeCmd.Flags().String("context-dir", "", "Directory to use as context for code generation")
 	generateCodeCmd.Flags().StringSliceP("ext", "e", []string{}, "Comma-separated list of file extensions to filter in context directory (e.g., .go,.txt)")
+	generateCodeCmd.Flags().Int64("max-file-size", 0, "Skip context files larger than this many bytes (default: fileFiltering.maxFileSize, or 1 MiB)")
+	generateCodeCmd.Flags().Int("token-budget", api.DefaultContextTokenBudget, "Most tokens of context files to include; the most relevant files to the prompt are kept")
 }
 
 func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// initializeCli loads the configuration for the command being run, starts telemetry
+// and re-executes the CLI inside the sandbox when one is configured.
+func initializeCli(cmd *cobra.Command) {
 	// Generate a session ID
 	sessionId := uuid.New().String()
 
 	// Load CLI configuration
 	var configErrors []errors.SettingError
-	globalCliConfig, configErrors = config_pkg.LoadCliConfig(os.Getenv("PWD"), sessionId, rootCmd)
+	globalCliConfig, configErrors = config_pkg.LoadCliConfig(os.Getenv("PWD"), sessionId, cmd)
 
 	if len(configErrors) > 0 {
 		for _, err := range configErrors {
 			fmt.Fprintf(os.Stderr, "Error in %s: %s\n", err.Path, err.Message)
 		}
 		fmt.Fprintf(os.Stderr, "Please fix the errors and try again.\n")
-        os.Exit(1)
-    }
+		os.Exit(1)
+	}
 
-    // Initialize Telemetry
-    telemetry.InitializeTelemetry(globalCliConfig)
-    defer telemetry.ShutdownTelemetry(context.Background())
+	// Initialize Telemetry
+	telemetry.InitializeTelemetry(globalCliConfig)
 
-    // Now, globalCliConfig contains all merged settings and command-line arguments.
-    // It can be accessed by other command Run functions.
+	// Now, globalCliConfig contains all merged settings and command-line arguments.
+	// It can be accessed by other command Run functions.
 
 	// Handle sandbox logic
 	if os.Getenv("SANDBOX") == "" && globalCliConfig.Sandbox != nil {
@@ -446,12 +475,12 @@ func main() {
 		}
 
 		if sandboxEnabled {
-			fmt.Println("Entering sandbox...")
+			fmt.Fprintln(os.Stderr, "Entering sandbox...")
 
 			// Validate authentication before entering sandbox if OAuth is selected
 			var err error
 			if globalCliConfig.SelectedAuthType != nil && *globalCliConfig.SelectedAuthType == "oauth" {
-								err = auth.ValidateAuthMethod(*globalCliConfig.SelectedAuthType)
+				err = auth.ValidateAuthMethod(*globalCliConfig.SelectedAuthType)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error validating auth method before sandbox: %v\n", err)
 					os.Exit(1)
@@ -460,7 +489,7 @@ func main() {
 				// This part is tricky as refreshAuth is usually tied to API client creation.
 				// For now, we'll assume the token is valid or will be refreshed upon API client creation.
 				// A more robust solution might involve a dedicated auth refresh function.
-				fmt.Println("OAuth2 authentication validated for sandbox.")
+				fmt.Fprintln(os.Stderr, "OAuth2 authentication validated for sandbox.")
 			}
 
 			cmdArgs := os.Args[1:]
@@ -488,87 +517,344 @@ func main() {
 			os.Exit(0) // Exit current process after sandbox finishes
 		}
 	}
+}
 
-	// Check if stdin is a TTY
-	isTTY := isStdinTTY()
+// runInteractive starts the REPL with the given conversation history, usually none.
+// Input history is kept in ~/.gemini.
+func runInteractive(conversationHistory []*genai.Content) {
+	ctx := context.Background()
+	client := mustCreateClient(ctx, false)
+
+	toolRegistry := newToolRegistry()
+	conversation := api.NewConversation(client)
+	conversation.SetHistory(conversationHistory)
+	executor, err := api.NewExecutor(globalCliConfig, toolRegistry, newConfirmer(os.Stdout), conversation)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	agent := api.NewAgent(conversation, toolRegistry, executor)
+	// Checkpoints can be restored with /restore even when no new ones are saved.
+	checkpoints, err := checkpoint.StoreForConfig(globalCliConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	var history term.History
+	if historyPath, err := ui.DefaultHistoryPath(); err == nil {
+		fileHistory, err := ui.LoadFileHistory(historyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			history = fileHistory
+		}
+	}
+
+	// Ctrl-C cancels the current generation instead of terminating the process.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	session := &command.Session{
+		Out:      os.Stdout,
+		Client:   client,
+		Agent:    agent,
+		Tools:    toolRegistry,
+		Commands: command.NewBuiltinRegistry(),
+		Memory:   loadMemory(),
+		Walk:     walkOptions(nil),
+		Classify: classifyOptions(nil),
+		Pricing:  globalCliConfig.Settings.PriceTable(),
+
+		Checkpoints: checkpoints,
+		SessionID:   globalCliConfig.SessionID,
+	}
+	reader := ui.NewTerminalReader(os.Stdin, os.Stdout, "> ", history)
+	repl := ui.NewREPL(reader, agent, session, interrupts)
+	if err := repl.Run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
 
-	// Get prompt from command-line arguments
+// runNonInteractive answers the prompt given with --prompt and/or on stdin, then exits.
+func runNonInteractive() {
 	input := globalCliConfig.Prompt
 
-	if isTTY && input == "" {
-		// Interactive mode: If no prompt is provided and it's a TTY,
-		// let Cobra handle the default behavior (e.g., showing help or welcome message).
-		// The actual interactive UI (like Ink) is not directly ported here.
-	} else {
-		// Non-interactive mode
-		// If not a TTY, read from stdin
-		if !isTTY {
-			stdinBytes, err := io.ReadAll(os.Stdin)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
-				os.Exit(1)
-			}
-			if len(stdinBytes) > 0 {
-				input += string(stdinBytes)
-			}
+	// If not a TTY, read from stdin
+	if !isStdinTTY() {
+		stdinBytes, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
+			os.Exit(1)
 		}
+		if len(stdinBytes) > 0 {
+			input += string(stdinBytes)
+		}
+	}
+
+	if input == "" {
+		fmt.Fprintf(os.Stderr, "No input provided via stdin or command line.\n")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := mustCreateClient(ctx, false)
+	if globalCliConfig.JSONSchema != "" {
+		runStructuredOutput(ctx, client, input)
+		return
+	}
+	toolRegistry := newToolRegistry()
 
-		if input == "" {
-			fmt.Fprintf(os.Stderr, "No input provided via stdin or command line.\n")
+	// Run non-interactive mode. On a terminal the user is asked about mutating tools
+	// on stderr, which keeps stdout for the answer.
+	if err := api.RunNonInteractive(ctx, globalCliConfig, client, toolRegistry, newConfirmer(os.Stderr), input); err != nil {
+		fmt.Fprintf(os.Stderr, "Non-interactive execution failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// exitSchemaValidation is the exit code when the answer does not match the --json-schema schema,
+// so that scripts can tell it apart from other failures (exit code 1).
+const exitSchemaValidation = 3
+
+// runStructuredOutput prints the model's answer to prompt as JSON matching the --json-schema
+// schema, and nothing else on stdout. An answer that does not match is reported on stderr.
+func runStructuredOutput(ctx context.Context, client *api.Client, prompt string) {
+	schema, err := api.LoadResponseSchema(globalCliConfig.JSONSchema)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	output, err := client.GenerateJSON(ctx, prompt, schema)
+	var validationErr *api.SchemaValidationError
+	if stderrors.As(err, &validationErr) {
+		fmt.Fprintf(os.Stderr, "Error: %v\nResponse:\n%s\n", err, validationErr.Response)
+		os.Exit(exitSchemaValidation)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(output)
+}
+
+// mustCreateClient creates the API client using OAuth2 when selected and the
+// GEMINI_API_KEY environment variable otherwise. It exits the process on failure.
+// When announce is true, the authentication method in use is printed.
+func mustCreateClient(ctx context.Context, announce bool) *api.Client {
+	// Use globalCliConfig for authentication and model
+	if globalCliConfig.SelectedAuthType != nil && *globalCliConfig.SelectedAuthType == "oauth" {
+		token, err := auth.LoadToken()
+		if err != nil || !token.Valid() {
+			fmt.Fprintln(os.Stderr, "Error: OAuth2 selected but no valid token found. Please run 'gemini auth'.")
 			os.Exit(1)
 		}
-
-		ctx := context.Background()
-		var client *api.Client
-		var err error
-
-		// Use globalCliConfig for authentication and model
-		if globalCliConfig.SelectedAuthType != nil && *globalCliConfig.SelectedAuthType == "oauth" {
-			token, err := auth.LoadToken()
-			if err == nil && token.Valid() {
-				// Use OAuth2 client
-				oauthConfig := auth.GetOAuth2Config(os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET"))
-				httpClient := auth.GetHTTPClient(ctx, oauthConfig, token)
-				client, err = api.NewClient(ctx, "", httpClient, globalCliConfig.Model)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
-					os.Exit(1)
-				}
-			} else {
-				fmt.Println("Error: OAuth2 selected but no valid token found. Please run 'gemini auth'.")
-				os.Exit(1)
-			}
-		} else { // Fallback to API key if no auth type selected or not oauth
-			apiKey := os.Getenv("GEMINI_API_KEY")
-			if apiKey == "" {
-				fmt.Println("Error: GEMINI_API_KEY environment variable not set and no valid OAuth2 token found.")
-				fmt.Println("Please get your API key from https://aistudio.google.com/apikey or run 'gemini auth'.")
-				os.Exit(1)
-			}
-			client, err = api.NewClient(ctx, apiKey, nil, globalCliConfig.Model)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
-				os.Exit(1)
-			}
+		// Use OAuth2 client
+		oauthConfig := auth.GetOAuth2Config(os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET")) // Still using env for client ID/secret for now
+		httpClient := auth.GetHTTPClient(ctx, oauthConfig, token)
+		client, err := api.NewClient(ctx, "", httpClient, globalCliConfig.Model)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
+			os.Exit(1)
 		}
+		if announce {
+			fmt.Println("Using OAuth2 for authentication.")
+		}
+		configureClient(client)
+		return client
+	}
 
-		toolRegistry := tool_pkg.NewToolRegistry()
-		toolRegistry.RegisterTool(&tool_pkg.ReadTool{})
-		toolRegistry.RegisterTool(&tool_pkg.ListFilesTool{})
-		toolRegistry.RegisterTool(&tool_pkg.WriteFileTool{})
+	// Fallback to API key if no auth type selected or not oauth
+	apiKey := os.Getenv("GEMINI_API_KEY") // Still using env for API key for now
+	if apiKey == "" {
+		fmt.Fprintln(os.Stderr, "Error: GEMINI_API_KEY environment variable not set and no valid OAuth2 token found.")
+		fmt.Fprintln(os.Stderr, "Please get your API key from https://aistudio.google.com/apikey or run 'gemini auth'.")
+		os.Exit(1)
+	}
+	client, err := api.NewClient(ctx, apiKey, nil, globalCliConfig.Model)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
+		os.Exit(1)
+	}
+	if announce {
+		fmt.Println("Using API key for authentication.")
+	}
+	configureClient(client)
+	return client
+}
 
-		// Run non-interactive mode
-		if err := api.RunNonInteractive(ctx, globalCliConfig, client, toolRegistry, input); err != nil {
-			fmt.Fprintf(os.Stderr, "Non-interactive execution failed: %v\n", err)
-			os.Exit(1)
+// configureClient applies what is sent with every request: the generation parameters, and
+// the configured system instruction followed by the loaded memory.
+func configureClient(client *api.Client) {
+	var instructions []string
+	for _, text := range []string{globalCliConfig.SystemInstruction, loadMemory().Text()} {
+		if strings.TrimSpace(text) != "" {
+			instructions = append(instructions, text)
 		}
-		os.Exit(0) // Exit after non-interactive execution
 	}
+	client.SetOptions(api.Options{
+		SystemInstruction: strings.Join(instructions, "\n\n"),
+		Generation:        globalCliConfig.GenerationConfig,
+	})
+}
 
-	if err := rootCmd.Execute(); err != nil {
+// loadedMemory caches the result of loadMemory.
+var loadedMemory *memory.Memory
+
+// loadMemory loads the instruction files for the working directory once per process.
+// Problems are reported as warnings, since the CLI works without memory.
+func loadMemory() *memory.Memory {
+	if loadedMemory != nil {
+		return loadedMemory
+	}
+	loadedMemory = &memory.Memory{}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot load memory: %v\n", err)
+		return loadedMemory
+	}
+	opts := memory.Options{
+		WorkingDir: workingDir,
+		FileNames:  globalCliConfig.Settings.ContextFileNames(),
+		Walk:       walkOptions(nil),
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		opts.GlobalDir = filepath.Join(home, config_pkg.SettingsDirectoryName)
+	}
+	loaded, err := memory.Load(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot load memory: %v\n", err)
+		return loadedMemory
+	}
+	loadedMemory = loaded
+	return loadedMemory
+}
+
+// newToolRegistry returns a registry with the built-in tools, the tools of the toolDiscoveryCommand
+// setting and those of the MCP servers, as far as the coreTools and excludeTools settings allow them.
+// It exits the process if these settings are invalid.
+func newToolRegistry() *tool_pkg.ToolRegistry {
+	toolRegistry := newServedToolRegistry()
+	for _, mcpTool := range startMcpServers().Tools() {
+		toolRegistry.RegisterTool(mcpTool)
+	}
+	return toolRegistry
+}
+
+// newServedToolRegistry returns the registry offered by gemini mcp serve: that of newToolRegistry
+// without the tools of MCP servers, which their clients can use directly.
+func newServedToolRegistry() *tool_pkg.ToolRegistry {
+	toolRegistry, err := tool_pkg.NewToolRegistryFromSettings(globalCliConfig.Settings)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	discovered, err := tool_pkg.DiscoverToolsFromSettings(context.Background(), globalCliConfig.Settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	for _, discoveredTool := range discovered {
+		// Discovered tools do not replace built-in ones.
+		if _, exists := toolRegistry.GetTool(discoveredTool.Name()); exists {
+			fmt.Fprintf(os.Stderr, "Warning: discovered tool %s has the name of a built-in tool and is ignored\n", discoveredTool.Name())
+			continue
+		}
+		toolRegistry.RegisterTool(discoveredTool)
+	}
+	return toolRegistry
+}
+
+// mcpManager holds the MCP servers started for the session; they are stopped when the command ends.
+var mcpManager *mcp.Manager
+
+// startMcpServers connects to the servers of the mcpServers setting. Servers that fail
+// are reported and left out, so that the session can run without their tools.
+// Their stderr is only shown in debug mode.
+func startMcpServers() *mcp.Manager {
+	if mcpManager != nil {
+		return mcpManager
+	}
+	var opts mcp.Options
+	if globalCliConfig.DebugMode {
+		opts.Stderr = os.Stderr
+	}
+	manager, err := mcp.Start(context.Background(), globalCliConfig.Settings.McpServers, opts)
+	if err != nil {
+		// One line per failed server.
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", line)
+		}
+	}
+	mcpManager = manager
+	return manager
+}
+
+// newConfirmer returns how mutating tools are approved without --yolo: by asking on
+// the terminal, writing to out, when stdin is a TTY, and never otherwise.
+func newConfirmer(out io.Writer) tool_pkg.Confirmer {
+	if isStdinTTY() {
+		return ui.NewPromptConfirmer(ui.NewTerminalReader(os.Stdin, out, ui.ConfirmPrompt, nil), out)
+	}
+	return tool_pkg.NonInteractiveConfirmer{}
+}
+
+// walkOptions selects the files with the given extensions, honoring the fileFiltering settings.
+func walkOptions(extensions []string) filesystem.WalkOptions {
+	return filesystem.WalkOptions{
+		Extensions:       extensions,
+		DisableGitIgnore: !globalCliConfig.Settings.RespectsGitIgnore(),
+	}
+}
+
+// classifyOptions returns the size limit for context files: the --max-file-size flag of cmd
+// if it is set, and the fileFiltering.maxFileSize setting otherwise.
+func classifyOptions(cmd *cobra.Command) filesystem.ClassifyOptions {
+	if cmd != nil {
+		if maxFileSize, err := cmd.Flags().GetInt64("max-file-size"); err == nil && maxFileSize > 0 {
+			return filesystem.ClassifyOptions{MaxFileSize: maxFileSize}
+		}
+	}
+	return filesystem.ClassifyOptions{MaxFileSize: globalCliConfig.Settings.MaxFileSize()}
+}
+
+// readContextFiles reads the text files under dir with the given extensions, walking the
+// tree first and then reading the contents concurrently. Binary, generated and
+// too large files are returned as skipped.
+func readContextFiles(ctx context.Context, dir string, extensions []string, opts filesystem.ClassifyOptions) ([]filesystem.FileContent, []filesystem.SkippedFile, error) {
+	entries, err := filesystem.Entries(dir, walkOptions(extensions))
+	if err != nil {
+		return nil, nil, err
+	}
+	return filesystem.ReadTextFiles(ctx, entries, 0, opts)
+}
+
+// reportSkippedFiles tells the user on stderr which files were left out of the context and why.
+func reportSkippedFiles(skipped []filesystem.SkippedFile) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Skipped %d files:\n", len(skipped))
+	for _, file := range skipped {
+		fmt.Fprintf(os.Stderr, "- %s: %s\n", file.Path, file.Classification)
+	}
+}
+
+// reportPackedContext tells the user on stderr how much context was packed and which files did not fit.
+func reportPackedContext(packed *api.PackedContext) {
+	estimated := ""
+	if packed.Estimated {
+		estimated = " (estimated)"
+	}
+	fmt.Fprintf(os.Stderr, "Context: %d files, %d tokens%s\n", len(packed.Files), packed.Tokens, estimated)
+	if len(packed.Excluded) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Left out %d files to fit the token budget:\n", len(packed.Excluded))
+	for _, file := range packed.Excluded {
+		fmt.Fprintf(os.Stderr, "- %s (%d tokens)\n", file.Path, file.Tokens)
+	}
 }
 
 // isStdinTTY checks if os.Stdin is connected to a terminal.
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"gemini-cli-go/internal/shared"
+	"gemini-cli-go/internal/tool"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// TurnHandler receives the events of a turn as they happen.
+type TurnHandler interface {
+	// OnText is called for every chunk of text streamed by the model.
+	OnText(text string)
+	// OnToolCall is called for every function call before the tools run.
+	OnToolCall(call shared.FunctionCall)
+	// OnToolResult is called with the result of every function call.
+	OnToolResult(response shared.FunctionResponse)
+}
+
+// SessionStats summarizes the activity of an Agent.
+type SessionStats struct {
+	StartTime time.Time
+	// Turns counts the user turns that completed successfully.
+	Turns int
+	// ToolCalls counts every function call executed, including failed ones.
+	ToolCalls int
+	// FailedToolCalls counts the function calls whose response reported an error.
+	FailedToolCalls int
+	// Requests counts the requests sent to the model, including those of failed turns.
+	Requests int
+	// Usage is the token usage of all requests. Failed turns count, since their tokens are billed too.
+	Usage TokenUsage
+	// UsageByModel splits Usage by the model the requests were sent to.
+	UsageByModel map[string]TokenUsage
+	// LastTurn is the token usage of the most recent turn.
+	LastTurn TurnUsage
+}
+
+// Agent drives the tool-calling loop of a conversation: it sends user input,
+// executes the function calls the model asks for and feeds the results back
+// until the model answers in text.
+type Agent struct {
+	conversation *Conversation
+	registry     shared.ToolRegistryInterface
+	executor     *tool.Executor
+	stats        SessionStats
+}
+
+// NewAgent creates an Agent that runs the tools in registry with executor.
+func NewAgent(conversation *Conversation, registry shared.ToolRegistryInterface, executor *tool.Executor) *Agent {
+	return &Agent{
+		conversation: conversation,
+		registry:     registry,
+		executor:     executor,
+		stats:        SessionStats{StartTime: time.Now(), UsageByModel: make(map[string]TokenUsage)},
+	}
+}
+
+// Conversation returns the conversation the agent appends to.
+func (a *Agent) Conversation() *Conversation {
+	return a.conversation
+}
+
+// Stats returns the activity recorded since the agent was created.
+func (a *Agent) Stats() SessionStats {
+	stats := a.stats
+	stats.UsageByModel = make(map[string]TokenUsage, len(a.stats.UsageByModel))
+	for model, usage := range a.stats.UsageByModel {
+		stats.UsageByModel[model] = usage
+	}
+	stats.LastTurn.Requests = append([]TokenUsage(nil), a.stats.LastTurn.Requests...)
+	return stats
+}
+
+// recordUsage adds the usage of a request to the turn and the session.
+func (a *Agent) recordUsage(usage TokenUsage) {
+	a.stats.Requests++
+	a.stats.Usage.Add(usage)
+	model := a.conversation.client.ModelName()
+	modelUsage := a.stats.UsageByModel[model]
+	modelUsage.Add(usage)
+	a.stats.UsageByModel[model] = modelUsage
+	a.stats.LastTurn.AddRequest(usage)
+}
+
+// RunTurn sends parts to the model and keeps executing tools until the model replies without function calls.
+// If the turn fails or ctx is cancelled, the conversation is restored to its state before the turn,
+// so an interrupted turn never leaves unanswered function calls in the history.
+func (a *Agent) RunTurn(ctx context.Context, handler TurnHandler, parts ...genai.Part) error {
+	historyLen := len(a.conversation.History())
+	a.stats.LastTurn = TurnUsage{}
+	if err := a.runTurn(ctx, handler, parts); err != nil {
+		a.conversation.SetHistory(a.conversation.History()[:historyLen])
+		return err
+	}
+	a.stats.Turns++
+	return nil
+}
+
+func (a *Agent) runTurn(ctx context.Context, handler TurnHandler, parts []genai.Part) error {
+	for {
+		tools := &shared.Tools{FunctionDeclarations: a.registry.GetFunctionDeclarations()}
+		stream, err := a.conversation.SendMessageStream(ctx, tools, parts...)
+		if err != nil {
+			return fmt.Errorf("error generating content: %w", err)
+		}
+
+		var fullTextResponse string
+		var functionCalls []shared.FunctionCall
+		var usage *genai.UsageMetadata
+
+		for {
+			resp, err := stream.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("error streaming response: %w", err)
+			}
+			// Every chunk reports the usage of the request so far, so the last one counts.
+			if resp.UsageMetadata != nil {
+				usage = resp.UsageMetadata
+			}
+
+			if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+				continue
+			}
+
+			candidate := resp.Candidates[0]
+			for _, part := range candidate.Content.Parts {
+				if text, ok := part.(genai.Text); ok {
+					handler.OnText(string(text))
+					fullTextResponse += string(text)
+				} else if functionCall, ok := part.(genai.FunctionCall); ok {
+					functionCalls = append(functionCalls, shared.FunctionCall{
+						Name: functionCall.Name,
+						Args: functionCall.Args,
+					})
+				}
+			}
+		}
+
+		if usage != nil {
+			requestUsage := usageFromMetadata(usage)
+			a.recordUsage(requestUsage)
+			if usageHandler, ok := handler.(UsageHandler); ok {
+				usageHandler.OnUsage(requestUsage)
+			}
+		}
+
+		if len(functionCalls) == 0 {
+			if fullTextResponse == "" {
+				return fmt.Errorf("no text or function call in Gemini's response")
+			}
+			return nil
+		}
+
+		for _, fc := range functionCalls {
+			handler.OnToolCall(fc)
+		}
+		responses := a.executor.Execute(ctx, functionCalls)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, response := range responses {
+			a.stats.ToolCalls++
+			if _, failed := response.Response["error"]; failed {
+				a.stats.FailedToolCalls++
+			}
+			handler.OnToolResult(response)
+		}
+		parts = FunctionResponseParts(responses) // Feed all tool outputs back to Gemini in one turn
+	}
+}
+
+// TextHandler prints a turn as plain text.
+type TextHandler struct {
+	Out io.Writer
+	// Status receives the tool activity, so that Out has the answer alone. Nil selects Out.
+	Status io.Writer
+}
+
+func (h *TextHandler) status() io.Writer {
+	if h.Status != nil {
+		return h.Status
+	}
+	return h.Out
+}
+
+// OnText prints the streamed text as is.
+func (h *TextHandler) OnText(text string) {
+	fmt.Fprint(h.Out, text)
+}
+
+// OnToolCall prints the tool name and arguments.
+func (h *TextHandler) OnToolCall(call shared.FunctionCall) {
+	fmt.Fprintf(h.status(), "\nGemini called tool: %s with args: %v\n", call.Name, call.Args)
+}
+
+// OnToolResult prints the structured tool result.
+func (h *TextHandler) OnToolResult(response shared.FunctionResponse) {
+	fmt.Fprintf(h.status(), "Tool %s returned: %v\n", response.Name, response.Response)
+}
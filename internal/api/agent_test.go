@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/tool"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestAgentRunTurnKeepsHistoryAcrossTurns(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"echo","args":{"text":"hi"}}}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Said hi."}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"You asked me to say hi."}]}}]}]`,
+	)
+
+	registry := tool.NewToolRegistry()
+	registry.RegisterTool(&echoTool{name: "echo"})
+	agent := NewAgent(NewConversation(client), registry, tool.NewExecutor(registry, 1))
+
+	var out bytes.Buffer
+	handler := &TextHandler{Out: &out}
+	if err := agent.RunTurn(context.Background(), handler, genai.Text("say hi")); err != nil {
+		t.Fatalf("RunTurn failed: %v", err)
+	}
+	if err := agent.RunTurn(context.Background(), handler, genai.Text("what did I ask?")); err != nil {
+		t.Fatalf("RunTurn failed: %v", err)
+	}
+
+	// user, model(call), user(response), model(text), user
+	if got := len(fake.contents(t, 2)); got != 5 {
+		t.Errorf("Expected 5 turns in the third request, got %d", got)
+	}
+	for _, want := range []string{"Gemini called tool: echo", "Tool echo returned:", "Said hi.", "You asked me to say hi."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	if stats := agent.Stats(); stats.Turns != 2 || stats.ToolCalls != 1 || stats.FailedToolCalls != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestAgentRunTurnRollsBackFailedTurn(t *testing.T) {
+	// The model asks for a tool, but the follow-up request fails.
+	_, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"echo","args":{"text":"hi"}}}]}}]}]`,
+	)
+
+	registry := tool.NewToolRegistry()
+	registry.RegisterTool(&echoTool{name: "echo"})
+	agent := NewAgent(NewConversation(client), registry, tool.NewExecutor(registry, 1))
+
+	var out bytes.Buffer
+	if err := agent.RunTurn(context.Background(), &TextHandler{Out: &out}, genai.Text("say hi")); err == nil {
+		t.Fatal("Expected RunTurn to fail")
+	}
+	if got := len(agent.Conversation().History()); got != 0 {
+		t.Errorf("Expected the failed turn to be rolled back, history has %d turns", got)
+	}
+}
+
+func TestAgentRunTurnCancelled(t *testing.T) {
+	_, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"never seen"}]}}]}]`,
+	)
+	registry := tool.NewToolRegistry()
+	agent := NewAgent(NewConversation(client), registry, tool.NewExecutor(registry, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	if err := agent.RunTurn(ctx, &TextHandler{Out: &out}, genai.Text("hello")); err == nil {
+		t.Fatal("Expected RunTurn to fail for a cancelled context")
+	}
+	if got := len(agent.Conversation().History()); got != 0 {
+		t.Errorf("Expected no history after a cancelled turn, got %d turns", got)
+	}
+}
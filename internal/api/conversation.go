@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Conversation holds the turns exchanged with the model during a session.
+// Every request sends the full history, so earlier user messages, model replies
+// and tool results stay in context.
+type Conversation struct {
+	client  *Client
+	history []*genai.Content
+}
+
+// NewConversation starts an empty conversation using the given client.
+func NewConversation(client *Client) *Conversation {
+	return &Conversation{client: client}
+}
+
+// SendMessageStream sends the history plus parts as a new user turn and streams the model's reply.
+// The user turn and the reply are recorded in the history once the stream has been read to io.EOF;
+// a stream that fails leaves the history unchanged.
+// Each call uses the client's current model, so switching models keeps the history.
+func (cv *Conversation) SendMessageStream(ctx context.Context, tools *shared.Tools, parts ...genai.Part) (*ResponseStream, error) {
+	cv.client.model.Tools = toGenaiTools(tools)
+
+	session := cv.client.model.StartChat()
+	session.History = append([]*genai.Content(nil), cv.history...)
+	iter := session.SendMessageStream(ctx, parts...)
+	return &ResponseStream{
+		iter: iter,
+		onDone: func() {
+			cv.history = session.History
+		},
+	}, nil
+}
+
+// History returns the turns recorded so far, oldest first.
+func (cv *Conversation) History() []*genai.Content {
+	return cv.history
+}
+
+// SetHistory replaces the recorded turns.
+func (cv *Conversation) SetHistory(history []*genai.Content) {
+	cv.history = history
+}
+
+// Clear drops all recorded turns.
+func (cv *Conversation) Clear() {
+	cv.history = nil
+}
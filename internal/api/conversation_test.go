@@ -0,0 +1,280 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gemini-cli-go/internal/config"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// fakeGemini is a fake streamGenerateContent endpoint that replies with one
+// canned response per request and records the decoded request bodies.
+type fakeGemini struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []map[string]interface{}
+	paths     []string
+}
+
+func newFakeGemini(t *testing.T, responses ...string) (*fakeGemini, *Client) {
+	t.Helper()
+	fake := &fakeGemini{responses: responses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read request body: %v", err)
+		}
+		var req map[string]interface{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		fake.mu.Lock()
+		index := len(fake.requests)
+		fake.requests = append(fake.requests, req)
+		fake.paths = append(fake.paths, r.URL.Path)
+		fake.mu.Unlock()
+
+		if index >= len(fake.responses) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":{"message":"unexpected request"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fake.responses[index]))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(context.Background(), "test-api-key", server.Client(), "gemini-pro", option.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return fake, client
+}
+
+// contents returns the "contents" array of the i-th recorded request.
+func (f *fakeGemini) contents(t *testing.T, i int) []map[string]interface{} {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i >= len(f.requests) {
+		t.Fatalf("Expected at least %d requests, got %d", i+1, len(f.requests))
+	}
+	raw, _ := f.requests[i]["contents"].([]interface{})
+	contents := make([]map[string]interface{}, len(raw))
+	for j, c := range raw {
+		contents[j], _ = c.(map[string]interface{})
+	}
+	return contents
+}
+
+func drainStream(t *testing.T, stream *ResponseStream) string {
+	t.Helper()
+	var text string
+	for {
+		resp, err := stream.Next()
+		if err == io.EOF {
+			return text
+		}
+		if err != nil {
+			t.Fatalf("Error streaming response: %v", err)
+		}
+		for _, part := range resp.Candidates[0].Content.Parts {
+			if t, ok := part.(genai.Text); ok {
+				text += string(t)
+			}
+		}
+	}
+}
+
+func TestConversationSendsHistory(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello, "}]}}]},
+		  {"candidates":[{"content":{"role":"model","parts":[{"text":"Alice."}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Your name is Alice."}]}}]}]`,
+	)
+
+	ctx := context.Background()
+	conversation := NewConversation(client)
+
+	stream, err := conversation.SendMessageStream(ctx, nil, genai.Text("My name is Alice."))
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+	if got := drainStream(t, stream); got != "Hello, Alice." {
+		t.Errorf("Expected 'Hello, Alice.', got %q", got)
+	}
+
+	stream, err = conversation.SendMessageStream(ctx, nil, genai.Text("What is my name?"))
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+	drainStream(t, stream)
+
+	contents := fake.contents(t, 1)
+	if len(contents) != 3 {
+		t.Fatalf("Expected 3 turns in second request, got %d: %v", len(contents), contents)
+	}
+	wantRoles := []string{"user", "model", "user"}
+	for i, want := range wantRoles {
+		if contents[i]["role"] != want {
+			t.Errorf("Turn %d: expected role %q, got %v", i, want, contents[i]["role"])
+		}
+	}
+	modelParts, _ := contents[1]["parts"].([]interface{})
+	if len(modelParts) != 1 || modelParts[0].(map[string]interface{})["text"] != "Hello, Alice." {
+		t.Errorf("Expected merged model turn 'Hello, Alice.', got %v", modelParts)
+	}
+
+	if len(conversation.History()) != 4 {
+		t.Errorf("Expected 4 turns in history, got %d", len(conversation.History()))
+	}
+
+	conversation.Clear()
+	if len(conversation.History()) != 0 {
+		t.Errorf("Expected empty history after Clear, got %d turns", len(conversation.History()))
+	}
+}
+
+func TestConversationDropsFailedTurn(t *testing.T) {
+	// No canned responses: every request fails.
+	_, client := newFakeGemini(t)
+
+	conversation := NewConversation(client)
+	stream, err := conversation.SendMessageStream(context.Background(), nil, genai.Text("hello"))
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+	if _, err := stream.Next(); err == nil {
+		t.Fatal("Expected an error from the failing server")
+	}
+	if len(conversation.History()) != 0 {
+		t.Errorf("Expected failed turn to be dropped, got %d turns", len(conversation.History()))
+	}
+}
+
+func TestConversationKeepsHistoryAcrossModelSwitch(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi."}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Still here."}]}}]}]`,
+	)
+
+	ctx := context.Background()
+	conversation := NewConversation(client)
+	stream, err := conversation.SendMessageStream(ctx, nil, genai.Text("hello"))
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+	drainStream(t, stream)
+
+	client.SetModel("gemini-other")
+	if client.ModelName() != "gemini-other" {
+		t.Errorf("Expected model name 'gemini-other', got %q", client.ModelName())
+	}
+	stream, err = conversation.SendMessageStream(ctx, nil, genai.Text("are you there?"))
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+	drainStream(t, stream)
+
+	if !strings.Contains(fake.paths[1], "gemini-other") {
+		t.Errorf("Expected second request to use the new model, got path %q", fake.paths[1])
+	}
+	if got := len(fake.contents(t, 1)); got != 3 {
+		t.Errorf("Expected 3 turns in second request, got %d", got)
+	}
+}
+
+func TestClientSystemInstruction(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi."}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi again."}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Bye."}]}}]}]`,
+	)
+	ctx := context.Background()
+	conversation := NewConversation(client)
+	send := func(text string) {
+		t.Helper()
+		stream, err := conversation.SendMessageStream(ctx, nil, genai.Text(text))
+		if err != nil {
+			t.Fatalf("SendMessageStream failed: %v", err)
+		}
+		drainStream(t, stream)
+	}
+	instruction := func(i int) string {
+		content, _ := fake.requests[i]["systemInstruction"].(map[string]interface{})
+		parts, _ := content["parts"].([]interface{})
+		if len(parts) == 0 {
+			return ""
+		}
+		part, _ := parts[0].(map[string]interface{})
+		text, _ := part["text"].(string)
+		return text
+	}
+
+	client.SetSystemInstruction("Always answer in French.")
+	send("hello")
+	// The instruction outlives a model switch.
+	client.SetModel("gemini-other")
+	send("hello again")
+	client.SetSystemInstruction("")
+	send("bye")
+
+	if got := instruction(0); got != "Always answer in French." {
+		t.Errorf("Expected the system instruction in the first request, got %q", got)
+	}
+	if got := instruction(1); got != "Always answer in French." {
+		t.Errorf("Expected the system instruction after switching models, got %q", got)
+	}
+	if _, ok := fake.requests[2]["systemInstruction"]; ok {
+		t.Errorf("Expected no system instruction after clearing it, got %v", fake.requests[2]["systemInstruction"])
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi."}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi again."}]}}]}]`,
+	)
+	temperature := float32(0.5)
+	client.SetOptions(Options{
+		SystemInstruction: "Be brief.",
+		Generation:        config.GenerationSettings{Temperature: &temperature, StopSequences: []string{"END"}},
+	})
+
+	ctx := context.Background()
+	conversation := NewConversation(client)
+	for _, text := range []string{"hello", "hello again"} {
+		stream, err := conversation.SendMessageStream(ctx, nil, genai.Text(text))
+		if err != nil {
+			t.Fatalf("SendMessageStream failed: %v", err)
+		}
+		drainStream(t, stream)
+		// The second request goes to a model with other defaults.
+		client.SetModel("gemini-2.5-flash")
+	}
+
+	first, _ := fake.requests[0]["generationConfig"].(map[string]interface{})
+	if first["temperature"] != 0.5 || first["topP"] != 1.0 || first["maxOutputTokens"] != nil {
+		t.Errorf("Expected the temperature option over the defaults of gemini-pro, got %v", first)
+	}
+	if stops, _ := first["stopSequences"].([]interface{}); len(stops) != 1 || stops[0] != "END" {
+		t.Errorf("Expected the stop sequence option, got %v", first["stopSequences"])
+	}
+	second, _ := fake.requests[1]["generationConfig"].(map[string]interface{})
+	if second["temperature"] != 0.5 || second["maxOutputTokens"] != 65536.0 {
+		t.Errorf("Expected the options over the defaults of gemini-2.5-flash, got %v", second)
+	}
+	if client.SystemInstruction() != "Be brief." || *client.GenerationSettings().MaxOutputTokens != 65536 {
+		t.Errorf("Expected the options to be kept across model switches, got %+v", client.Options())
+	}
+}
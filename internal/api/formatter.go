@@ -19,3 +19,18 @@ func FormatFilesForGemini(files []filesystem.FileContent) string {
 	}
 	return builder.String()
 }
+
+// FormatSkippedFiles lists the files left out of the context and why, so that the model
+// knows they exist. It returns "" if no files were skipped.
+func FormatSkippedFiles(skipped []filesystem.SkippedFile) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString("--- Skipped files ---\n")
+	for _, file := range skipped {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", file.Path, file.Classification))
+	}
+	builder.WriteString("--- End of skipped files ---\n\n")
+	return builder.String()
+}
@@ -50,4 +50,19 @@ func TestFormatFilesForGemini(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestFormatSkippedFiles(t *testing.T) {
+	if got := FormatSkippedFiles(nil); got != "" {
+		t.Errorf("Expected no output for no skipped files, got %q", got)
+	}
+
+	skipped := []filesystem.SkippedFile{
+		{Path: "logo.png", Classification: filesystem.Classification{Class: filesystem.ClassBinary, Size: 5120, Detail: "image/png"}},
+		{Path: "go.sum", Classification: filesystem.Classification{Class: filesystem.ClassGenerated, Size: 80, Detail: "lockfile"}},
+	}
+	want := "--- Skipped files ---\nlogo.png: binary (image/png, 5120 bytes)\ngo.sum: generated (lockfile, 80 bytes)\n--- End of skipped files ---\n\n"
+	if got := FormatSkippedFiles(skipped); got != want {
+		t.Errorf("FormatSkippedFiles() got = %q, want %q", got, want)
+	}
+}
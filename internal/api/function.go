@@ -0,0 +1,20 @@
+package api
+
+import (
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// FunctionResponseParts converts tool results into parts that can be sent back to the model.
+// The genai SDK has no field for call IDs, so responses are matched to calls by name and order.
+func FunctionResponseParts(responses []shared.FunctionResponse) []genai.Part {
+	parts := make([]genai.Part, len(responses))
+	for i, fr := range responses {
+		parts[i] = genai.FunctionResponse{
+			Name:     fr.Name,
+			Response: fr.Response,
+		}
+	}
+	return parts
+}
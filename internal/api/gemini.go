@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 
+	"gemini-cli-go/internal/checkpoint"
 	"gemini-cli-go/internal/config"
 	"gemini-cli-go/internal/shared"
+	"gemini-cli-go/internal/tool"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/iterator"
@@ -17,7 +20,18 @@ import (
 
 // Client is a client for the Gemini API.
 type Client struct {
-	model *genai.GenerativeModel
+	genaiClient *genai.Client
+	modelName   string
+	model       *genai.GenerativeModel
+	options     Options
+}
+
+// Options configure what a Client sends with every request besides the prompt.
+type Options struct {
+	// SystemInstruction is sent as the system instruction; "" sends none.
+	SystemInstruction string
+	// Generation overrides the default generation parameters of the model, see config.DefaultGenerationSettings.
+	Generation config.GenerationSettings
 }
 
 // NewClient creates a new Gemini API client.
@@ -40,40 +54,69 @@ func NewClient(ctx context.Context, apiKey string, httpClient *http.Client, mode
 		return nil, fmt.Errorf("failed to create genai client: %w", err)
 	}
 
-	model := genaiClient.GenerativeModel(modelName)
-	return &Client{model: model}, nil
+	client := &Client{genaiClient: genaiClient}
+	client.SetModel(modelName)
+	return client, nil
+}
+
+// ModelName returns the name of the model requests are sent to.
+func (c *Client) ModelName() string {
+	return c.modelName
+}
+
+// SetModel switches subsequent requests to the named model. The options are kept, on top
+// of the defaults of the new model.
+func (c *Client) SetModel(modelName string) {
+	c.modelName = modelName
+	c.model = c.genaiClient.GenerativeModel(modelName)
+	c.SetOptions(c.options)
+}
+
+// SetOptions sets what is sent with every subsequent request.
+func (c *Client) SetOptions(opts Options) {
+	c.options = opts
+	if opts.SystemInstruction == "" {
+		c.model.SystemInstruction = nil
+	} else {
+		c.model.SystemInstruction = genai.NewUserContent(genai.Text(opts.SystemInstruction))
+	}
+
+	generation := c.GenerationSettings()
+	c.model.GenerationConfig = genai.GenerationConfig{
+		Temperature:     generation.Temperature,
+		TopP:            generation.TopP,
+		MaxOutputTokens: generation.MaxOutputTokens,
+		StopSequences:   generation.StopSequences,
+		CandidateCount:  generation.CandidateCount,
+	}
+}
+
+// Options returns the options set with SetOptions.
+func (c *Client) Options() Options {
+	return c.options
+}
+
+// GenerationSettings returns the generation parameters requests are sent with:
+// the defaults of the model overridden by the options.
+func (c *Client) GenerationSettings() config.GenerationSettings {
+	return config.DefaultGenerationSettings(c.modelName).Merge(c.options.Generation)
+}
+
+// SetSystemInstruction sets the system instruction sent with every request; "" removes it.
+func (c *Client) SetSystemInstruction(text string) {
+	opts := c.options
+	opts.SystemInstruction = text
+	c.SetOptions(opts)
+}
+
+// SystemInstruction returns the system instruction sent with every request.
+func (c *Client) SystemInstruction() string {
+	return c.options.SystemInstruction
 }
 
 // GenerateContentStream sends a request to the Gemini API to generate content and streams the response.
 func (c *Client) GenerateContentStream(ctx context.Context, prompt string, tools *shared.Tools) (*ResponseStream, error) {
-	var genaiTools []*genai.Tool
-
-	if tools != nil && len(tools.FunctionDeclarations) > 0 {
-		genaiTools = make([]*genai.Tool, len(tools.FunctionDeclarations))
-		for i, fd := range tools.FunctionDeclarations {
-			// Convert map[string]interface{} to *genai.Schema
-			paramsJSON, err := json.Marshal(fd.Parameters)
-			if err != nil {
-				return nil, fmt.Errorf("failed to marshal parameters: %w", err)
-			}
-			var schema genai.Schema
-			if err := json.Unmarshal(paramsJSON, &schema); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal parameters to schema: %w", err)
-			}
-
-			genaiTools[i] = &genai.Tool{
-				FunctionDeclarations: []*genai.FunctionDeclaration{
-					{
-						Name:        fd.Name,
-						Description: fd.Description,
-						Parameters:  &schema,
-					},
-				},
-			}
-		}
-		// Set tools on the model
-		c.model.Tools = genaiTools
-	}
+	c.model.Tools = toGenaiTools(tools)
 
 	iter := c.model.GenerateContentStream(ctx, genai.Text(prompt))
 	return &ResponseStream{iter: iter}, nil
@@ -82,12 +125,19 @@ func (c *Client) GenerateContentStream(ctx context.Context, prompt string, tools
 // ResponseStream wraps the genai.GenerateContentResponseIterator.
 type ResponseStream struct {
 	iter *genai.GenerateContentResponseIterator
+	// onDone is called once the stream has been read to the end,
+	// so that a Conversation can record the completed turn.
+	onDone func()
 }
 
 // Next returns the next part of the streamed response.
 func (rs *ResponseStream) Next() (*genai.GenerateContentResponse, error) {
 	resp, err := rs.iter.Next()
 	if err == iterator.Done {
+		if rs.onDone != nil {
+			rs.onDone()
+			rs.onDone = nil
+		}
 		return nil, io.EOF
 	}
 	if err != nil {
@@ -97,67 +147,74 @@ func (rs *ResponseStream) Next() (*genai.GenerateContentResponse, error) {
 }
 
 // RunNonInteractive handles the non-interactive CLI interaction with Gemini.
-func RunNonInteractive(ctx context.Context, cfg *config.CliConfig, client *Client, toolRegistry shared.ToolRegistryInterface, initialPrompt string) error {
-	currentPrompt := initialPrompt
-	for {
-		fmt.Printf("Sending prompt to Gemini: \"%s\"\n", currentPrompt)
-		stream, err := client.GenerateContentStream(ctx, currentPrompt, &shared.Tools{FunctionDeclarations: toolRegistry.GetFunctionDeclarations()})
-		if err != nil {
-			return fmt.Errorf("error generating content: %w", err)
-		}
+// The whole exchange, including tool results, is kept in a single Conversation
+// so the model retains context across tool calls.
+// Mutating tools are approved by confirmer unless cfg.Yolo is set; a tool.NonInteractiveConfirmer
+// denies them where nobody can be asked.
+// The result goes to stdout in the format of cfg.OutputFormat, and status messages to stderr.
+func RunNonInteractive(ctx context.Context, cfg *config.CliConfig, client *Client, toolRegistry shared.ToolRegistryInterface, confirmer tool.Confirmer, initialPrompt string) error {
+	return runNonInteractive(ctx, cfg, client, toolRegistry, confirmer, initialPrompt, os.Stdout, os.Stderr)
+}
 
-		var fullTextResponse string
-		var functionCalls []shared.FunctionCall
-
-		for {
-			resp, err := stream.Next()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return fmt.Errorf("error streaming response: %w", err)
-			}
-
-			if len(resp.Candidates) == 0 {
-				continue
-			}
-
-			candidate := resp.Candidates[0]
-			for _, part := range candidate.Content.Parts {
-				if text, ok := part.(genai.Text); ok {
-					fmt.Print(string(text))
-					fullTextResponse += string(text)
-				} else if functionCall, ok := part.(genai.FunctionCall); ok {
-					functionCalls = append(functionCalls, shared.FunctionCall{
-						Name: functionCall.Name,
-						Args: functionCall.Args,
-					})
-				}
-			}
+func runNonInteractive(ctx context.Context, cfg *config.CliConfig, client *Client, toolRegistry shared.ToolRegistryInterface, confirmer tool.Confirmer, initialPrompt string, stdout, stderr io.Writer) error {
+	format, err := ParseOutputFormat(cfg.OutputFormat)
+	if err != nil {
+		return err
+	}
+	conversation := NewConversation(client)
+	executor, err := NewExecutor(cfg, toolRegistry, confirmer, conversation)
+	if err != nil {
+		return err
+	}
+	agent := NewAgent(conversation, toolRegistry, executor)
+
+	switch format {
+	case OutputJSON:
+		handler := NewJSONHandler()
+		err := agent.RunTurn(ctx, handler, genai.Text(initialPrompt))
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(handler.Result(err, sessionCost(agent, cfg))); encodeErr != nil && err == nil {
+			err = encodeErr
 		}
+		return err
+	case OutputStreamJSON:
+		handler := NewStreamJSONHandler(stdout)
+		err := agent.RunTurn(ctx, handler, genai.Text(initialPrompt))
+		handler.Finish(err, sessionCost(agent, cfg))
+		return err
+	default:
+		fmt.Fprintf(stderr, "Sending prompt to Gemini: \"%s\"\n", initialPrompt)
+		err := agent.RunTurn(ctx, &TextHandler{Out: stdout, Status: stderr}, genai.Text(initialPrompt))
+		fmt.Fprintln(stdout) // Newline after streamed response
+		return err
+	}
+}
 
-		fmt.Println() // Newline after streamed response
-
-		if len(functionCalls) > 0 {
-			fc := functionCalls[0] // Simplified: assuming one function call at a time
-			fmt.Printf("\nGemini called tool: %s with args: %v\n", fc.Name, fc.Args)
-
-			calledTool, ok := toolRegistry.GetTool(fc.Name)
-			if !ok {
-				return fmt.Errorf("tool %s not found", fc.Name)
-			}
-
-			toolOutput, err := calledTool.Execute(ctx, fc.Args)
-			if err != nil {
-				return fmt.Errorf("error executing tool %s: %w", fc.Name, err)
-			}
-
-			fmt.Printf("Tool %s output:\n%s\n", fc.Name, toolOutput)
-			currentPrompt = fmt.Sprintf("Tool %s returned: %s", fc.Name, toolOutput) // Feed tool output back to Gemini
-		} else if fullTextResponse != "" {
-			return nil // Gemini responded with text, end interaction
-		} else {
-			return fmt.Errorf("no text or function call in Gemini's response")
+// NewExecutor creates the executor of the tool calls in conversation as cfg asks: mutating
+// tools are approved with confirmer unless cfg.Yolo is set, and checkpointed before they
+// run when checkpointing is enabled. Every command that runs tools builds its executor here.
+func NewExecutor(cfg *config.CliConfig, toolRegistry shared.ToolRegistryInterface, confirmer tool.Confirmer, conversation *Conversation) (*tool.Executor, error) {
+	executor := tool.NewExecutor(toolRegistry, cfg.MaxToolWorkers())
+	if !cfg.Yolo {
+		executor.SetConfirmer(confirmer)
+	}
+	if cfg.CheckpointingEnabled {
+		store, err := checkpoint.StoreForConfig(cfg)
+		if err != nil {
+			return nil, err
 		}
+		executor.SetCheckpointer(&checkpoint.Checkpointer{Store: store, SessionID: cfg.SessionID, History: conversation.History})
+	}
+	return executor, nil
+}
+
+// sessionCost estimates the cost of the agent's requests with the price table of cfg.
+// It returns nil if a model used has no price.
+func sessionCost(agent *Agent, cfg *config.CliConfig) *float64 {
+	estimate := EstimateCost(agent.Stats().UsageByModel, cfg.Settings.PriceTable())
+	if !estimate.Complete {
+		return nil
 	}
+	return &estimate.Total
 }
@@ -1,12 +1,23 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
+	"gemini-cli-go/internal/checkpoint"
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/shared"
+	"gemini-cli-go/internal/tool"
+
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
@@ -89,4 +100,320 @@ func TestGenerateContentStream(t *testing.T) {
 	if err.Error() != expectedErrorMsg {
 		t.Errorf("Expected error message %q, got %q", expectedErrorMsg, err.Error())
 	}
-}
\ No newline at end of file
+}
+// echoTool is a minimal shared.Tool that records its arguments.
+type echoTool struct {
+	name     string
+	mutating bool
+	mu       sync.Mutex
+	args     []map[string]interface{}
+	err      error
+}
+
+func (e *echoTool) Name() string        { return e.name }
+func (e *echoTool) Description() string { return "Echoes its input." }
+func (e *echoTool) FunctionDeclaration() shared.FunctionDeclaration {
+	return shared.FunctionDeclaration{
+		Name:        e.name,
+		Description: e.Description(),
+		Parameters: shared.Schema{
+			Type:       shared.TypeObject,
+			Properties: map[string]shared.Schema{"text": {Type: shared.TypeString}},
+		},
+	}
+}
+func (e *echoTool) IsReadOnly() bool { return !e.mutating }
+func (e *echoTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
+	e.mu.Lock()
+	e.args = append(e.args, args)
+	e.mu.Unlock()
+	if e.err != nil {
+		return "", e.err
+	}
+	return fmt.Sprintf("echo: %v", args["text"]), nil
+}
+
+func TestRunNonInteractiveSendsFunctionResponse(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"echo","args":{"text":"hi"}}}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Done."}]}}]}]`,
+	)
+
+	echo := &echoTool{name: "echo"}
+	registry := tool.NewToolRegistry()
+	registry.RegisterTool(echo)
+
+	if err := RunNonInteractive(context.Background(), &config.CliConfig{}, client, registry, tool.NonInteractiveConfirmer{}, "say hi"); err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+
+	if len(echo.args) != 1 || echo.args[0]["text"] != "hi" {
+		t.Fatalf("Expected echo to be called once with text 'hi', got %v", echo.args)
+	}
+
+	// The first request must declare the tool.
+	declared, _ := fake.requests[0]["tools"].([]interface{})
+	if len(declared) != 1 {
+		t.Errorf("Expected tools to be declared in the first request, got %v", fake.requests[0]["tools"])
+	}
+
+	contents := fake.contents(t, 1)
+	if len(contents) != 3 {
+		t.Fatalf("Expected 3 turns in second request, got %d: %v", len(contents), contents)
+	}
+	modelParts, _ := contents[1]["parts"].([]interface{})
+	if len(modelParts) != 1 || modelParts[0].(map[string]interface{})["functionCall"] == nil {
+		t.Errorf("Expected model turn with a functionCall, got %v", modelParts)
+	}
+
+	parts, _ := contents[2]["parts"].([]interface{})
+	if len(parts) != 1 {
+		t.Fatalf("Expected one function response part, got %v", parts)
+	}
+	fr, _ := parts[0].(map[string]interface{})["functionResponse"].(map[string]interface{})
+	if fr == nil {
+		t.Fatalf("Expected a functionResponse part, got %v", parts[0])
+	}
+	if fr["name"] != "echo" {
+		t.Errorf("Expected function response for 'echo', got %v", fr["name"])
+	}
+	response, _ := fr["response"].(map[string]interface{})
+	if response["output"] != "echo: hi" {
+		t.Errorf("Expected structured output 'echo: hi', got %v", response)
+	}
+}
+
+func TestRunNonInteractiveReportsToolErrors(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"missing","args":{}}}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"That tool does not exist."}]}}]}]`,
+	)
+
+	if err := RunNonInteractive(context.Background(), &config.CliConfig{}, client, tool.NewToolRegistry(), tool.NonInteractiveConfirmer{}, "use a tool"); err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+
+	parts, _ := fake.contents(t, 1)[2]["parts"].([]interface{})
+	fr, _ := parts[0].(map[string]interface{})["functionResponse"].(map[string]interface{})
+	response, _ := fr["response"].(map[string]interface{})
+	if response["error"] != "tool missing not found" {
+		t.Errorf("Expected error response for missing tool, got %v", response)
+	}
+}
+
+func TestRunNonInteractiveExecutesAllFunctionCalls(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[
+			{"functionCall":{"name":"echo","args":{"text":"one"}}},
+			{"functionCall":{"name":"echo","args":{"text":"two"}}}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Done."}]}}]}]`,
+	)
+
+	echo := &echoTool{name: "echo"}
+	registry := tool.NewToolRegistry()
+	registry.RegisterTool(echo)
+
+	if err := RunNonInteractive(context.Background(), &config.CliConfig{}, client, registry, tool.NonInteractiveConfirmer{}, "echo twice"); err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+	if len(echo.args) != 2 {
+		t.Fatalf("Expected both calls to be executed, got %d", len(echo.args))
+	}
+
+	parts, _ := fake.contents(t, 1)[2]["parts"].([]interface{})
+	if len(parts) != 2 {
+		t.Fatalf("Expected two function responses in one turn, got %v", parts)
+	}
+	for i, want := range []string{"echo: one", "echo: two"} {
+		fr, _ := parts[i].(map[string]interface{})["functionResponse"].(map[string]interface{})
+		response, _ := fr["response"].(map[string]interface{})
+		if response["output"] != want {
+			t.Errorf("Response %d: expected %q, got %v", i, want, response)
+		}
+	}
+}
+
+func TestRunNonInteractiveDeniesMutatingToolsWithoutYolo(t *testing.T) {
+	for _, yolo := range []bool{false, true} {
+		fake, client := newFakeGemini(t,
+			`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"echo","args":{"text":"hi"}}}]}}]}]`,
+			`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Done."}]}}]}]`,
+		)
+		echo := &echoTool{name: "echo", mutating: true}
+		registry := tool.NewToolRegistry()
+		registry.RegisterTool(echo)
+
+		if err := RunNonInteractive(context.Background(), &config.CliConfig{Yolo: yolo}, client, registry, tool.NonInteractiveConfirmer{}, "say hi"); err != nil {
+			t.Fatalf("RunNonInteractive failed: %v", err)
+		}
+
+		parts, _ := fake.contents(t, 1)[2]["parts"].([]interface{})
+		fr, _ := parts[0].(map[string]interface{})["functionResponse"].(map[string]interface{})
+		response, _ := fr["response"].(map[string]interface{})
+		if yolo {
+			if len(echo.args) != 1 || response["output"] != "echo: hi" {
+				t.Errorf("Expected the tool to run with --yolo, got %v", response)
+			}
+			continue
+		}
+		errMsg, _ := response["error"].(string)
+		if len(echo.args) != 0 || !strings.Contains(errMsg, "non-interactive mode") {
+			t.Errorf("Expected the tool to be denied without --yolo, got %v", response)
+		}
+	}
+}
+
+// approvingConfirmer approves every call and records the tools it was asked about.
+type approvingConfirmer struct {
+	asked []string
+}
+
+func (c *approvingConfirmer) Confirm(_ context.Context, call shared.FunctionCall, _ shared.ConfirmationDetails) (tool.Decision, error) {
+	c.asked = append(c.asked, call.Name)
+	return tool.Approve, nil
+}
+
+func TestRunNonInteractiveAsksTheConfirmer(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"echo","args":{"text":"hi"}}}]}}]}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Done."}]}}]}]`,
+	)
+	echo := &echoTool{name: "echo", mutating: true}
+	registry := tool.NewToolRegistry()
+	registry.RegisterTool(echo)
+
+	// As with -p on a terminal, where the user can be asked.
+	confirmer := &approvingConfirmer{}
+	if err := RunNonInteractive(context.Background(), &config.CliConfig{}, client, registry, confirmer, "say hi"); err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+
+	parts, _ := fake.contents(t, 1)[2]["parts"].([]interface{})
+	fr, _ := parts[0].(map[string]interface{})["functionResponse"].(map[string]interface{})
+	response, _ := fr["response"].(map[string]interface{})
+	if len(confirmer.asked) != 1 || len(echo.args) != 1 || response["output"] != "echo: hi" {
+		t.Errorf("Expected the tool to run once approved, asked %v, got %v", confirmer.asked, response)
+	}
+}
+
+func TestNewExecutorCheckpointsWhenEnabled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, enabled := range []bool{false, true} {
+		cfg := &config.CliConfig{Yolo: true, CheckpointingEnabled: enabled, TargetDir: t.TempDir(), SessionID: "session"}
+		echo := &echoTool{name: "echo", mutating: true}
+		registry := tool.NewToolRegistry()
+		registry.RegisterTool(echo)
+
+		executor, err := NewExecutor(cfg, registry, tool.NonInteractiveConfirmer{}, NewConversation(nil))
+		if err != nil {
+			t.Fatalf("NewExecutor failed: %v", err)
+		}
+		responses := executor.Execute(context.Background(), []shared.FunctionCall{{Name: "echo", Args: map[string]interface{}{"text": "hi"}}})
+		if responses[0].Response["output"] != "echo: hi" {
+			t.Errorf("Expected the tool to run with --yolo, got %v", responses[0].Response)
+		}
+
+		store, err := checkpoint.StoreForConfig(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkpoints, err := store.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]int{false: 0, true: 1}[enabled]; len(checkpoints) != want {
+			t.Errorf("With checkpointing enabled: %v, expected %d checkpoints, got %d", enabled, want, len(checkpoints))
+		}
+	}
+}
+
+func TestRunNonInteractiveOutputFormats(t *testing.T) {
+	responses := []string{
+		`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"echo","args":{"text":"hi"}}}]}}],
+			"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":2,"totalTokenCount":12}}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Said "}]}}]},
+			{"candidates":[{"content":{"role":"model","parts":[{"text":"hi."}]}}],
+			"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":3,"totalTokenCount":23}}]`,
+	}
+	run := func(format string, responses ...string) (string, string, error) {
+		t.Helper()
+		_, client := newFakeGemini(t, responses...)
+		registry := tool.NewToolRegistry()
+		registry.RegisterTool(&echoTool{name: "echo"})
+		var stdout, stderr bytes.Buffer
+		cfg := &config.CliConfig{OutputFormat: format}
+		cfg.ModelPricing = map[string]config.ModelPrice{"gemini-pro": {InputPerMillion: 1, OutputPerMillion: 2}}
+		err := runNonInteractive(context.Background(), cfg, client, registry, tool.NonInteractiveConfirmer{}, "say hi", &stdout, &stderr)
+		return stdout.String(), stderr.String(), err
+	}
+
+	// Text prints the answer alone on stdout.
+	stdout, stderr, err := run("text", responses...)
+	if err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+	if stdout != "Said hi.\n" {
+		t.Errorf("Expected only the answer on stdout, got %q", stdout)
+	}
+	if !strings.Contains(stderr, "Sending prompt to Gemini") || !strings.Contains(stderr, "Gemini called tool: echo") {
+		t.Errorf("Expected status messages on stderr, got %q", stderr)
+	}
+
+	// JSON prints one object with the response, the tool calls and the usage.
+	stdout, stderr, err = run("json", responses...)
+	if err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+	var result JSONResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Expected a JSON object on stdout, got %q: %v", stdout, err)
+	}
+	if result.Response != "Said hi." || result.Error != "" || stderr != "" {
+		t.Errorf("Unexpected result %+v, stderr %q", result, stderr)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].Args["text"] != "hi" || result.ToolCalls[0].Response["output"] != "echo: hi" {
+		t.Errorf("Unexpected tool calls: %+v", result.ToolCalls)
+	}
+	if result.Usage.TokenUsage != (TokenUsage{PromptTokens: 30, CandidateTokens: 5, TotalTokens: 35}) || len(result.Usage.Requests) != 2 {
+		t.Errorf("Expected the usage of both requests, got %+v", result.Usage)
+	}
+	// 30 prompt tokens at $1 and 5 output tokens at $2 per million.
+	if result.EstimatedCost == nil || math.Abs(*result.EstimatedCost-40e-6) > 1e-12 {
+		t.Errorf("Expected an estimated cost of $0.00004, got %v", result.EstimatedCost)
+	}
+
+	// A failed run still prints an object, with the error.
+	stdout, _, err = run("json", responses[0])
+	if err == nil {
+		t.Fatal("Expected the second request to fail")
+	}
+	result = JSONResult{}
+	if json.Unmarshal([]byte(stdout), &result) != nil || result.Error == "" || len(result.ToolCalls) != 1 {
+		t.Errorf("Expected an object with the error, got %q", stdout)
+	}
+
+	// Stream JSON prints one event per line as they happen.
+	stdout, _, err = run("stream-json", responses...)
+	if err != nil {
+		t.Fatalf("RunNonInteractive failed: %v", err)
+	}
+	var types []string
+	var last StreamEvent
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if err := json.Unmarshal([]byte(line), &last); err != nil {
+			t.Fatalf("Expected one JSON event per line, got %q: %v", line, err)
+		}
+		types = append(types, last.Type)
+	}
+	want := "usage tool_call tool_result text text usage result"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("Expected events %q, got %q", want, got)
+	}
+	if last.Response != "Said hi." || last.Usage == nil || last.Usage.TotalTokens != 35 || last.EstimatedCost == nil {
+		t.Errorf("Unexpected result event: %+v", last)
+	}
+
+	if _, _, err := run("yaml"); err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("Expected an error for an unknown format, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gemini-cli-go/internal/shared"
+)
+
+// OutputFormat selects how a non-interactive run prints its result.
+type OutputFormat string
+
+const (
+	// OutputText prints the answer as plain text.
+	OutputText OutputFormat = "text"
+	// OutputJSON prints one JSONResult once the run is over.
+	OutputJSON OutputFormat = "json"
+	// OutputStreamJSON prints one StreamEvent per line as the run progresses.
+	OutputStreamJSON OutputFormat = "stream-json"
+)
+
+// ParseOutputFormat parses the value of --output-format. "" selects OutputText.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch format := OutputFormat(s); format {
+	case "":
+		return OutputText, nil
+	case OutputText, OutputJSON, OutputStreamJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (expected text, json or stream-json)", s)
+	}
+}
+
+// ToolCallRecord is a tool call and its result, as reported by the JSON output formats.
+type ToolCallRecord struct {
+	Name     string                 `json:"name"`
+	Args     map[string]interface{} `json:"args"`
+	Response map[string]interface{} `json:"response,omitempty"`
+}
+
+// JSONResult is the object printed by --output-format json.
+type JSONResult struct {
+	Response  string           `json:"response"`
+	ToolCalls []ToolCallRecord `json:"toolCalls"`
+	Usage     TurnUsage        `json:"usage"`
+	// EstimatedCost is in US dollars. It is left out if a model used has no price.
+	EstimatedCost *float64 `json:"estimatedCost,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// JSONHandler collects a turn into a JSONResult.
+type JSONHandler struct {
+	result JSONResult
+}
+
+// NewJSONHandler creates an empty JSONHandler.
+func NewJSONHandler() *JSONHandler {
+	return &JSONHandler{result: JSONResult{ToolCalls: []ToolCallRecord{}, Usage: TurnUsage{Requests: []TokenUsage{}}}}
+}
+
+// OnText appends the streamed text to the response.
+func (h *JSONHandler) OnText(text string) {
+	h.result.Response += text
+}
+
+// OnToolCall records the call.
+func (h *JSONHandler) OnToolCall(call shared.FunctionCall) {
+	h.result.ToolCalls = append(h.result.ToolCalls, ToolCallRecord{Name: call.Name, Args: call.Args})
+}
+
+// OnToolResult records the result with the first call of the same name that has none yet.
+func (h *JSONHandler) OnToolResult(response shared.FunctionResponse) {
+	for i := range h.result.ToolCalls {
+		if call := &h.result.ToolCalls[i]; call.Response == nil && call.Name == response.Name {
+			call.Response = response.Response
+			return
+		}
+	}
+}
+
+// OnUsage records the tokens of a request.
+func (h *JSONHandler) OnUsage(usage TokenUsage) {
+	h.result.Usage.AddRequest(usage)
+}
+
+// Result returns the collected result, with err as the error of the run if it is not nil
+// and the estimated cost if it is known.
+func (h *JSONHandler) Result(err error, cost *float64) JSONResult {
+	result := h.result
+	result.EstimatedCost = cost
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// StreamEvent is one line printed by --output-format stream-json. Type is "text",
+// "tool_call", "tool_result", "usage" or, last, "result"; the other fields are set
+// according to it.
+type StreamEvent struct {
+	Type     string                 `json:"type"`
+	Text     string                 `json:"text,omitempty"`
+	Name     string                 `json:"name,omitempty"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+	Response interface{}            `json:"response,omitempty"`
+	Usage    *TokenUsage            `json:"usage,omitempty"`
+	// EstimatedCost is set on the "result" event if the cost is known, in US dollars.
+	EstimatedCost *float64 `json:"estimatedCost,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// StreamJSONHandler writes the events of a turn to Out as newline-delimited JSON.
+type StreamJSONHandler struct {
+	encoder  *json.Encoder
+	response strings.Builder
+	usage    TokenUsage
+}
+
+// NewStreamJSONHandler creates a StreamJSONHandler writing to out.
+func NewStreamJSONHandler(out io.Writer) *StreamJSONHandler {
+	return &StreamJSONHandler{encoder: json.NewEncoder(out)}
+}
+
+// OnText writes a "text" event.
+func (h *StreamJSONHandler) OnText(text string) {
+	h.response.WriteString(text)
+	h.write(StreamEvent{Type: "text", Text: text})
+}
+
+// OnToolCall writes a "tool_call" event.
+func (h *StreamJSONHandler) OnToolCall(call shared.FunctionCall) {
+	h.write(StreamEvent{Type: "tool_call", Name: call.Name, Args: call.Args})
+}
+
+// OnToolResult writes a "tool_result" event.
+func (h *StreamJSONHandler) OnToolResult(response shared.FunctionResponse) {
+	h.write(StreamEvent{Type: "tool_result", Name: response.Name, Response: response.Response})
+}
+
+// OnUsage writes a "usage" event with the tokens of a request.
+func (h *StreamJSONHandler) OnUsage(usage TokenUsage) {
+	h.usage.Add(usage)
+	h.write(StreamEvent{Type: "usage", Usage: &usage})
+}
+
+// Finish writes the "result" event: the whole response, the total usage, the estimated cost
+// if it is known, and err if it is not nil.
+func (h *StreamJSONHandler) Finish(err error, cost *float64) {
+	event := StreamEvent{Type: "result", Response: h.response.String(), Usage: &h.usage, EstimatedCost: cost}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	h.write(event)
+}
+
+func (h *StreamJSONHandler) write(event StreamEvent) {
+	// Encoding these types cannot fail, and a broken pipe has nowhere to be reported.
+	h.encoder.Encode(event)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gemini-cli-go/internal/filesystem"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// DefaultContextTokenBudget is how many tokens of file context are packed when ContextPacker.Budget is not set.
+const DefaultContextTokenBudget = 100000
+
+// TokenCounter counts the tokens text takes up in a request.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, text string) (int, error)
+}
+
+// CountTokens counts the tokens text takes up for the client's model, using the API's countTokens endpoint.
+// The system instruction and tools are left out of the request, so only text is counted.
+func (c *Client) CountTokens(ctx context.Context, text string) (int, error) {
+	model := *c.model
+	model.SystemInstruction = nil
+	model.Tools = nil
+	resp, err := model.CountTokens(ctx, genai.Text(text))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return int(resp.TotalTokens), nil
+}
+
+// EstimateTokens estimates the tokens text takes up without calling the API,
+// at roughly four bytes per token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// ExcludedFile is a file left out of a packed context because it did not fit the token budget.
+type ExcludedFile struct {
+	Path string
+	// Tokens is estimated, corrected by how far the estimate of the included files was off.
+	Tokens int
+}
+
+// PackedContext is the result of packing files into a token budget.
+type PackedContext struct {
+	// Files are the included files, most relevant first.
+	Files []filesystem.FileContent
+	// Excluded are the files that did not fit, most relevant first.
+	Excluded []ExcludedFile
+	// Tokens is the number of tokens the included files take up.
+	Tokens int
+	// Estimated is set if Tokens was estimated locally instead of counted by the API.
+	Estimated bool
+}
+
+// Format formats the included files for the model, followed by a list of the excluded ones.
+func (p *PackedContext) Format() string {
+	text := FormatFilesForGemini(p.Files)
+	if len(p.Excluded) == 0 {
+		return text
+	}
+	var builder strings.Builder
+	builder.WriteString(text)
+	builder.WriteString("--- Files left out to fit the token budget ---\n")
+	for _, file := range p.Excluded {
+		builder.WriteString(fmt.Sprintf("%s (%d tokens)\n", file.Path, file.Tokens))
+	}
+	builder.WriteString("--- End of files left out ---\n\n")
+	return builder.String()
+}
+
+// maxCountRequests bounds the countTokens requests of a Pack.
+const maxCountRequests = 3
+
+// ContextPacker selects the files that are sent as context: it ranks them by relevance
+// to the prompt, estimates the tokens of each formatted file, and includes files in order
+// of relevance for as long as they fit the budget. The API then counts the tokens of the
+// selection in a single request; if they exceed the budget, the estimates are corrected
+// by how far they were off and the files packed again.
+type ContextPacker struct {
+	// Counter counts tokens. If it is nil or fails, tokens are estimated with EstimateTokens.
+	Counter TokenCounter
+	// Budget is the most tokens the included files may take up. Zero selects DefaultContextTokenBudget.
+	Budget int
+}
+
+// Pack selects the files that fit the budget, most relevant to prompt first.
+// With an empty prompt, files keep their order.
+func (p *ContextPacker) Pack(ctx context.Context, prompt string, files []filesystem.FileContent) (*PackedContext, error) {
+	budget := p.Budget
+	if budget <= 0 {
+		budget = DefaultContextTokenBudget
+	}
+	ranked := RankFiles(prompt, files)
+	estimates := make([]int, len(ranked))
+	for i, file := range ranked {
+		estimates[i] = EstimateTokens(FormatFilesForGemini([]filesystem.FileContent{file}))
+	}
+
+	scale := 1.0
+	for request := 0; p.Counter != nil && request < maxCountRequests; request++ {
+		packed := packEstimated(ranked, estimates, scale, budget)
+		if len(packed.Files) == 0 {
+			break
+		}
+		count, err := p.Counter.CountTokens(ctx, FormatFilesForGemini(packed.Files))
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+		if count <= budget {
+			packed.Tokens = count
+			return packed, nil
+		}
+		scale *= float64(count) / float64(packed.Tokens)
+	}
+	packed := packEstimated(ranked, estimates, scale, budget)
+	packed.Estimated = true
+	return packed, nil
+}
+
+// packEstimated includes the ranked files in order for as long as their estimates,
+// multiplied by scale, fit the budget.
+func packEstimated(ranked []filesystem.FileContent, estimates []int, scale float64, budget int) *PackedContext {
+	packed := &PackedContext{}
+	for i, file := range ranked {
+		tokens := int(math.Ceil(float64(estimates[i]) * scale))
+		if packed.Tokens+tokens > budget {
+			packed.Excluded = append(packed.Excluded, ExcludedFile{Path: file.Path, Tokens: tokens})
+			continue
+		}
+		packed.Files = append(packed.Files, file)
+		packed.Tokens += tokens
+	}
+	return packed
+}
+
+// RankFiles orders files by relevance to prompt: files whose path contains words of the
+// prompt come first, then files whose content mentions them more often. Ties and an
+// empty prompt keep the original order.
+func RankFiles(prompt string, files []filesystem.FileContent) []filesystem.FileContent {
+	ranked := append([]filesystem.FileContent(nil), files...)
+	terms := promptTerms(prompt)
+	if len(terms) == 0 {
+		return ranked
+	}
+
+	scores := make(map[string]float64, len(files))
+	for _, file := range files {
+		scores[file.Path] = relevance(terms, file)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i].Path] > scores[ranked[j].Path]
+	})
+	return ranked
+}
+
+// relevance scores how relevant file is to the prompt terms.
+func relevance(terms []string, file filesystem.FileContent) float64 {
+	path := strings.ToLower(filepath.ToSlash(file.Path))
+	content := strings.ToLower(string(file.Content))
+	score := 0.0
+	for _, term := range terms {
+		if strings.Contains(path, term) {
+			score += 10
+		}
+		// Mentions count with diminishing returns, so that one long file cannot dominate.
+		if count := strings.Count(content, term); count > 0 {
+			score += 1 + float64(min(count, 20))/4
+		}
+	}
+	return score
+}
+
+// stopWords are common words that say nothing about which files are relevant.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true,
+	"from": true, "into": true, "are": true, "was": true, "use": true, "add": true,
+	"all": true, "can": true, "should": true, "code": true, "file": true, "files": true,
+	"make": true, "write": true, "please": true, "new": true, "how": true, "what": true,
+}
+
+// promptTerms returns the distinct lowercase words of prompt that are worth searching for.
+func promptTerms(prompt string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	words := strings.FieldsFunc(strings.ToLower(prompt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, word := range words {
+		if len(word) < 3 || stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/filesystem"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// fakeCounter counts one token per byte and fails after failAfter calls, if set.
+type fakeCounter struct {
+	calls     int
+	failAfter int
+}
+
+func (f *fakeCounter) CountTokens(_ context.Context, text string) (int, error) {
+	f.calls++
+	if f.failAfter > 0 && f.calls > f.failAfter {
+		return 0, errors.New("quota exceeded")
+	}
+	return len(text), nil
+}
+
+func packedPaths(packed *PackedContext) string {
+	var paths []string
+	for _, file := range packed.Files {
+		paths = append(paths, file.Path)
+	}
+	return strings.Join(paths, " ")
+}
+
+func TestRankFiles(t *testing.T) {
+	files := []filesystem.FileContent{
+		{Path: "README.md", Content: []byte("A CLI.")},
+		{Path: "internal/ui/repl.go", Content: []byte("package ui // reads input")},
+		{Path: "internal/auth/oauth.go", Content: []byte("package auth // token refresh, token cache")},
+		{Path: "cmd/main.go", Content: []byte("auth.Login()")},
+	}
+
+	var got []string
+	for _, file := range RankFiles("Fix the OAuth token refresh", files) {
+		got = append(got, file.Path)
+	}
+	want := "internal/auth/oauth.go README.md internal/ui/repl.go cmd/main.go"
+	if strings.Join(got, " ") != want {
+		t.Errorf("RankFiles() = %v, want %s", got, want)
+	}
+
+	// Without useful words, the order is kept.
+	got = got[:0]
+	for _, file := range RankFiles("add it", files) {
+		got = append(got, file.Path)
+	}
+	if strings.Join(got, " ") != "README.md internal/ui/repl.go internal/auth/oauth.go cmd/main.go" {
+		t.Errorf("Expected the original order, got %v", got)
+	}
+}
+
+func TestContextPackerPack(t *testing.T) {
+	files := []filesystem.FileContent{
+		{Path: "a.txt", Content: []byte(strings.Repeat("a", 100))},
+		{Path: "parser.go", Content: []byte("package parser")},
+		{Path: "b.txt", Content: []byte(strings.Repeat("b", 10))},
+	}
+	size := func(file filesystem.FileContent) int {
+		return len(FormatFilesForGemini([]filesystem.FileContent{file}))
+	}
+
+	// The budget fits the relevant file and the small one, but not the large one.
+	counter := &fakeCounter{}
+	packer := &ContextPacker{Counter: counter, Budget: size(files[1]) + size(files[2]) + 5}
+	packed, err := packer.Pack(context.Background(), "fix the parser", files)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if got := packedPaths(packed); got != "parser.go b.txt" {
+		t.Errorf("Expected parser.go and b.txt to be packed, got %q", got)
+	}
+	// The estimates of the first selection were a quarter of the count, so a.txt is left out
+	// with its corrected estimate.
+	if len(packed.Excluded) != 1 || packed.Excluded[0].Path != "a.txt" || packed.Excluded[0].Tokens < size(files[0])-4 {
+		t.Errorf("Expected a.txt to be excluded with about its token count, got %+v", packed.Excluded)
+	}
+	if packed.Tokens != size(files[1])+size(files[2]) || packed.Estimated {
+		t.Errorf("Unexpected token total %d (estimated: %v)", packed.Tokens, packed.Estimated)
+	}
+	if counter.calls != 2 {
+		t.Errorf("Expected one count of every selection, got %d calls", counter.calls)
+	}
+
+	formatted := packed.Format()
+	if !strings.HasPrefix(formatted, "--- File: parser.go ---") || !strings.Contains(formatted, "--- Files left out to fit the token budget ---\na.txt (") {
+		t.Errorf("Unexpected formatted context:\n%s", formatted)
+	}
+
+	// A failing counter falls back to local estimates.
+	counter = &fakeCounter{failAfter: 1}
+	packer = &ContextPacker{Counter: counter, Budget: 1000}
+	packed, err = packer.Pack(context.Background(), "", files)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if got := packedPaths(packed); got != "a.txt parser.go b.txt" || packed.Estimated || counter.calls != 1 {
+		t.Errorf("Expected all files counted in one request, got %q (estimated: %v, %d calls)", got, packed.Estimated, counter.calls)
+	}
+	packer.Budget = size(files[1]) + size(files[2]) + 5
+	packed, err = packer.Pack(context.Background(), "fix the parser", files)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if got := packedPaths(packed); got != "parser.go a.txt b.txt" || !packed.Estimated || counter.calls != 2 {
+		t.Errorf("Expected estimates after the counter failed, got %q (estimated: %v, %d calls)", got, packed.Estimated, counter.calls)
+	}
+
+	// Without a counter, every file is estimated.
+	packed, err = (&ContextPacker{Budget: 1000}).Pack(context.Background(), "", files)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	wantTokens := 0
+	for i := range files {
+		wantTokens += EstimateTokens(FormatFilesForGemini(files[i : i+1]))
+	}
+	if packed.Tokens != wantTokens || !packed.Estimated {
+		t.Errorf("Expected %d estimated tokens, got %d (estimated: %v)", wantTokens, packed.Tokens, packed.Estimated)
+	}
+}
+
+func TestContextPackerPackCountsOnce(t *testing.T) {
+	var files []filesystem.FileContent
+	for i := 0; i < 200; i++ {
+		files = append(files, filesystem.FileContent{Path: fmt.Sprintf("file%d.go", i), Content: []byte("package main")})
+	}
+	counter := &fakeCounter{}
+	packed, err := (&ContextPacker{Counter: counter}).Pack(context.Background(), "", files)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if len(packed.Files) != len(files) || packed.Estimated || counter.calls != 1 {
+		t.Errorf("Expected all files counted in one request, got %d files (estimated: %v) in %d calls", len(packed.Files), packed.Estimated, counter.calls)
+	}
+
+	// Tokens the estimates badly underrate still end up within the budget after a few requests.
+	counter = &fakeCounter{}
+	packed, err = (&ContextPacker{Counter: counter, Budget: 2000}).Pack(context.Background(), "", files)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if packed.Tokens > 2000 || len(packed.Files) == 0 || counter.calls > maxCountRequests {
+		t.Errorf("Expected files within the budget, got %d tokens of %d files in %d calls", packed.Tokens, len(packed.Files), counter.calls)
+	}
+}
+
+func TestClientCountTokens(t *testing.T) {
+	fake, client := newFakeGemini(t, `{"totalTokens": 42}`)
+
+	tokens, err := client.CountTokens(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+	if tokens != 42 {
+		t.Errorf("Expected 42 tokens, got %d", tokens)
+	}
+	if len(fake.paths) != 1 || !strings.HasSuffix(fake.paths[0], "gemini-pro:countTokens") {
+		t.Errorf("Expected a countTokens request, got %v", fake.paths)
+	}
+
+	// The fake has no more responses, so the next count fails.
+	if _, err := client.CountTokens(context.Background(), "hello"); err == nil {
+		t.Error("Expected an error from the API")
+	}
+}
+
+func TestClientCountTokensOnlyCountsText(t *testing.T) {
+	fake, client := newFakeGemini(t, `{"totalTokens": 5}`, `{"totalTokens": 5}`)
+	ctx := context.Background()
+
+	if _, err := client.CountTokens(ctx, "package main"); err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+	// A system instruction and tools add the same tokens to every count, so they are not sent.
+	client.SetSystemInstruction("Always answer in French.")
+	client.model.Tools = []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "read_file"}}}}
+	if _, err := client.CountTokens(ctx, "package main"); err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(fake.requests[1], fake.requests[0]) {
+		t.Errorf("Expected the same request with a system instruction and tools, got\n%v\nwant\n%v", fake.requests[1], fake.requests[0])
+	}
+	if client.SystemInstruction() != "Always answer in French." || len(client.model.Tools) != 1 {
+		t.Error("Expected the client's system instruction and tools to be kept")
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// genaiTypes maps the string types used by shared.Schema to the genai enum values.
+var genaiTypes = map[shared.Type]genai.Type{
+	shared.TypeString:  genai.TypeString,
+	shared.TypeNumber:  genai.TypeNumber,
+	shared.TypeInteger: genai.TypeInteger,
+	shared.TypeBoolean: genai.TypeBoolean,
+	shared.TypeArray:   genai.TypeArray,
+	shared.TypeObject:  genai.TypeObject,
+}
+
+// toGenaiSchema converts a shared.Schema into the schema type expected by the genai SDK.
+// genai.Type is an integer enum, so the schema cannot simply be round-tripped through JSON.
+func toGenaiSchema(s shared.Schema) *genai.Schema {
+	schema := &genai.Schema{
+		Type:        genaiTypes[s.Type],
+		Description: s.Description,
+		Required:    s.Required,
+		Nullable:    s.Nullable,
+	}
+	if len(s.Enum) > 0 {
+		schema.Format = "enum"
+		schema.Enum = s.Enum
+	}
+	if s.Items != nil {
+		schema.Items = toGenaiSchema(*s.Items)
+	}
+	if len(s.Properties) > 0 {
+		schema.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			schema.Properties[name] = toGenaiSchema(prop)
+		}
+	}
+	return schema
+}
+
+// toGenaiTools converts the shared tool declarations into genai tools.
+// It returns nil when there is nothing to declare.
+func toGenaiTools(tools *shared.Tools) []*genai.Tool {
+	if tools == nil || len(tools.FunctionDeclarations) == 0 {
+		return nil
+	}
+	declarations := make([]*genai.FunctionDeclaration, len(tools.FunctionDeclarations))
+	for i, fd := range tools.FunctionDeclarations {
+		declarations[i] = &genai.FunctionDeclaration{
+			Name:        fd.Name,
+			Description: fd.Description,
+			Parameters:  toGenaiSchema(fd.Parameters),
+		}
+	}
+	return []*genai.Tool{{FunctionDeclarations: declarations}}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"testing"
+
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestToGenaiSchema(t *testing.T) {
+	schema := toGenaiSchema(shared.Schema{
+		Type: shared.TypeObject,
+		Properties: map[string]shared.Schema{
+			"path": {Type: shared.TypeString, Description: "A path."},
+			"ext": {
+				Type:  shared.TypeArray,
+				Items: &shared.Schema{Type: shared.TypeString},
+			},
+			"mode": {Type: shared.TypeString, Enum: []string{"fast", "slow"}, Nullable: true},
+		},
+		Required: []string{"path"},
+	})
+
+	if schema.Type != genai.TypeObject {
+		t.Errorf("Expected TypeObject, got %v", schema.Type)
+	}
+	if schema.Properties["path"].Type != genai.TypeString || schema.Properties["path"].Description != "A path." {
+		t.Errorf("Unexpected 'path' property: %+v", schema.Properties["path"])
+	}
+	if schema.Properties["ext"].Type != genai.TypeArray || schema.Properties["ext"].Items.Type != genai.TypeString {
+		t.Errorf("Unexpected 'ext' property: %+v", schema.Properties["ext"])
+	}
+	if mode := schema.Properties["mode"]; mode.Format != "enum" || len(mode.Enum) != 2 || !mode.Nullable {
+		t.Errorf("Unexpected 'mode' property: %+v", mode)
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "path" {
+		t.Errorf("Expected required ['path'], got %v", schema.Required)
+	}
+}
+
+func TestToGenaiTools(t *testing.T) {
+	if tools := toGenaiTools(nil); tools != nil {
+		t.Errorf("Expected nil tools for nil input, got %v", tools)
+	}
+	if tools := toGenaiTools(&shared.Tools{}); tools != nil {
+		t.Errorf("Expected nil tools for empty declarations, got %v", tools)
+	}
+
+	tools := toGenaiTools(&shared.Tools{FunctionDeclarations: []shared.FunctionDeclaration{
+		{Name: "read_file", Parameters: shared.Schema{Type: shared.TypeObject}},
+		{Name: "list_files", Parameters: shared.Schema{Type: shared.TypeObject}},
+	}})
+	if len(tools) != 1 || len(tools[0].FunctionDeclarations) != 2 {
+		t.Fatalf("Expected one tool with 2 declarations, got %v", tools)
+	}
+	if tools[0].FunctionDeclarations[1].Name != "list_files" {
+		t.Errorf("Expected second declaration 'list_files', got %q", tools[0].FunctionDeclarations[1].Name)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// SchemaValidationError reports a response that is not JSON matching the requested schema.
+type SchemaValidationError struct {
+	// Response is the text the model returned.
+	Response string
+	Err      error
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("response does not match the schema: %v", e.Err)
+}
+
+func (e *SchemaValidationError) Unwrap() error {
+	return e.Err
+}
+
+// LoadResponseSchema reads a JSON Schema given inline, when spec starts with "{", or as the path of a file.
+func LoadResponseSchema(spec string) (shared.Schema, error) {
+	data := []byte(spec)
+	if !strings.HasPrefix(strings.TrimSpace(spec), "{") {
+		var err error
+		data, err = os.ReadFile(spec)
+		if err != nil {
+			return shared.Schema{}, fmt.Errorf("failed to read schema: %w", err)
+		}
+	}
+	return ParseResponseSchema(data)
+}
+
+// ParseResponseSchema parses a JSON Schema into a shared.Schema. Only the keywords
+// shared.Schema has are supported (type, properties, required, items, enum, nullable,
+// description); others are ignored. The conversion is strict, since the model should get
+// the schema that was asked for: every schema must have a type and arrays must have items.
+func ParseResponseSchema(data []byte) (shared.Schema, error) {
+	schema, err := shared.ParseJSONSchema(data, shared.JSONSchemaOptions{})
+	if err != nil {
+		return shared.Schema{}, fmt.Errorf("invalid schema: %w", err)
+	}
+	return schema, nil
+}
+
+// GenerateJSON asks the model for a JSON response matching schema and validates it locally.
+// Tools are not declared, since the API does not combine them with JSON responses.
+// A response that is not valid JSON or does not match the schema is returned with
+// a *SchemaValidationError.
+func (c *Client) GenerateJSON(ctx context.Context, prompt string, schema shared.Schema) (string, error) {
+	// A copy, so that the client's other requests keep their text responses.
+	model := *c.model
+	model.Tools = nil
+	model.ResponseMIMEType = "application/json"
+	model.ResponseSchema = toGenaiSchema(schema)
+
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate content: %w", err)
+	}
+	var builder strings.Builder
+	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+		for _, part := range resp.Candidates[0].Content.Parts {
+			if text, ok := part.(genai.Text); ok {
+				builder.WriteString(string(text))
+			}
+		}
+	}
+	text := strings.TrimSpace(builder.String())
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return text, &SchemaValidationError{Response: text, Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+	if err := schema.ValidateValue(value); err != nil {
+		return text, &SchemaValidationError{Response: text, Err: err}
+	}
+	return text, nil
+}
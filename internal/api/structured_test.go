@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/shared"
+)
+
+const recipeSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"steps": {"type": "array", "items": {"type": "string"}},
+		"difficulty": {"type": "string", "enum": ["easy", "hard"]}
+	},
+	"required": ["name", "steps"]
+}`
+
+func TestParseResponseSchema(t *testing.T) {
+	schema, err := ParseResponseSchema([]byte(recipeSchema))
+	if err != nil {
+		t.Fatalf("ParseResponseSchema failed: %v", err)
+	}
+	if schema.Type != shared.TypeObject || schema.Properties["steps"].Items.Type != shared.TypeString {
+		t.Errorf("Unexpected schema: %+v", schema)
+	}
+	if enum := schema.Properties["difficulty"].Enum; len(enum) != 2 {
+		t.Errorf("Expected the enum to be parsed, got %v", enum)
+	}
+
+	invalid := map[string]string{
+		`{"type": "object", "properties": {"a": {"type": "date"}}}`: `$.a: unsupported type "date"`,
+		`{"type": "array"}`:                     "$: array without items",
+		`{"type": "object", "required": ["a"]}`: `$: required property "a" is not defined`,
+		`{"type": "integer", "enum": ["1"]}`:    "$: enum is only supported for strings",
+		`{"properties": {}}`:                    `$: unsupported type ""`,
+		`{"type": "object", "properties": [1]}`: "$: properties must be an object",
+		`{"type": ["string", "null"]}`:          "$: type must be a single type",
+		`{"type": "string", "enum": ["a", 1]}`:  "$: enum values must be strings",
+		`{"type": "array", "items": {}}`:        `$[]: unsupported type ""`,
+		`[1]`:                                   "invalid JSON",
+	}
+	for input, want := range invalid {
+		if _, err := ParseResponseSchema([]byte(input)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseResponseSchema(%s) = %v, want an error containing %q", input, err, want)
+		}
+	}
+}
+
+func TestLoadResponseSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(recipeSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []string{path, recipeSchema} {
+		if schema, err := LoadResponseSchema(spec); err != nil || schema.Type != shared.TypeObject {
+			t.Errorf("LoadResponseSchema(%.20q) = %+v, %v", spec, schema, err)
+		}
+	}
+	if _, err := LoadResponseSchema(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestClientGenerateJSON(t *testing.T) {
+	fake, client := newFakeGemini(t,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"name\": \"Soup\", \"steps\": [\"boil\"]}"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"name\": \"Soup\"}"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Here is a recipe"}]}}]}`,
+	)
+	schema, err := ParseResponseSchema([]byte(recipeSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	output, err := client.GenerateJSON(ctx, "A soup recipe", schema)
+	if err != nil {
+		t.Fatalf("GenerateJSON failed: %v", err)
+	}
+	if output != `{"name": "Soup", "steps": ["boil"]}` {
+		t.Errorf("Unexpected output: %s", output)
+	}
+	config, _ := fake.requests[0]["generationConfig"].(map[string]interface{})
+	if config["responseMimeType"] != "application/json" {
+		t.Errorf("Expected a JSON response MIME type, got %v", config["responseMimeType"])
+	}
+	responseSchema, _ := config["responseSchema"].(map[string]interface{})
+	if required, _ := responseSchema["required"].([]interface{}); len(required) != 2 {
+		t.Errorf("Expected the response schema to be sent, got %v", config["responseSchema"])
+	}
+	if !strings.HasSuffix(fake.paths[0], ":generateContent") {
+		t.Errorf("Expected a generateContent request, got %s", fake.paths[0])
+	}
+
+	// Missing properties and text that is not JSON fail validation.
+	for _, want := range []string{`missing required property "steps"`, "invalid JSON"} {
+		_, err = client.GenerateJSON(ctx, "A soup recipe", schema)
+		var validationErr *SchemaValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected a validation error containing %q, got %v", want, err)
+		}
+	}
+
+	// The client's other requests are not affected.
+	if client.model.ResponseMIMEType != "" || client.model.ResponseSchema != nil {
+		t.Error("Expected GenerateJSON not to change the client's model")
+	}
+}
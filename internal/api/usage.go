@@ -0,0 +1,108 @@
+package api
+
+import (
+	"sort"
+
+	"gemini-cli-go/internal/config"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// UsageHandler is implemented by TurnHandlers that want the token usage of the turn.
+type UsageHandler interface {
+	// OnUsage is called after every response of the model with the tokens of that request.
+	OnUsage(usage TokenUsage)
+}
+
+// TokenUsage counts the tokens of one or more requests.
+type TokenUsage struct {
+	// PromptTokens includes CachedTokens.
+	PromptTokens    int `json:"promptTokens"`
+	CachedTokens    int `json:"cachedTokens"`
+	CandidateTokens int `json:"candidateTokens"`
+	// TotalTokens includes tokens the model spent thinking, which are billed as output.
+	TotalTokens int `json:"totalTokens"`
+}
+
+// Add adds the tokens of other to u.
+func (u *TokenUsage) Add(other TokenUsage) {
+	u.PromptTokens += other.PromptTokens
+	u.CachedTokens += other.CachedTokens
+	u.CandidateTokens += other.CandidateTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// OutputTokens returns the tokens billed as output: the candidates and any thinking.
+func (u TokenUsage) OutputTokens() int {
+	return max(u.CandidateTokens, u.TotalTokens-u.PromptTokens)
+}
+
+// Cost returns what the tokens cost, in US dollars, at price.
+func (u TokenUsage) Cost(price config.ModelPrice) float64 {
+	cachedPrice := price.CachedInputPerMillion
+	if cachedPrice == 0 {
+		cachedPrice = price.InputPerMillion
+	}
+	return (float64(u.PromptTokens-u.CachedTokens)*price.InputPerMillion +
+		float64(u.CachedTokens)*cachedPrice +
+		float64(u.OutputTokens())*price.OutputPerMillion) / 1e6
+}
+
+// usageFromMetadata converts the usage reported by the API.
+func usageFromMetadata(metadata *genai.UsageMetadata) TokenUsage {
+	return TokenUsage{
+		PromptTokens:    int(metadata.PromptTokenCount),
+		CachedTokens:    int(metadata.CachedContentTokenCount),
+		CandidateTokens: int(metadata.CandidatesTokenCount),
+		TotalTokens:     int(metadata.TotalTokenCount),
+	}
+}
+
+// TurnUsage is the token usage of a user turn, which makes one request per step of its tool loop.
+type TurnUsage struct {
+	TokenUsage
+	// Requests has the usage of every request of the turn, in order.
+	Requests []TokenUsage `json:"requests"`
+}
+
+// AddRequest records the usage of the next request of the turn.
+func (u *TurnUsage) AddRequest(usage TokenUsage) {
+	u.Requests = append(u.Requests, usage)
+	u.Add(usage)
+}
+
+// ModelCost is the cost of the tokens sent to one model.
+type ModelCost struct {
+	Model string
+	Usage TokenUsage
+	// Cost is in US dollars; it is only meaningful if Priced is set.
+	Cost   float64
+	Priced bool
+}
+
+// CostEstimate estimates what a session cost from the price table.
+type CostEstimate struct {
+	// Models are sorted by name.
+	Models []ModelCost
+	// Total is the cost of the priced models, in US dollars.
+	Total float64
+	// Complete is false if some model has no price, so that Total is too low.
+	Complete bool
+}
+
+// EstimateCost prices the usage of each model with prices.
+func EstimateCost(usageByModel map[string]TokenUsage, prices config.PriceTable) CostEstimate {
+	estimate := CostEstimate{Complete: true}
+	for model, usage := range usageByModel {
+		modelCost := ModelCost{Model: model, Usage: usage}
+		if price, ok := prices.Price(model); ok {
+			modelCost.Cost, modelCost.Priced = usage.Cost(price), true
+			estimate.Total += modelCost.Cost
+		} else {
+			estimate.Complete = false
+		}
+		estimate.Models = append(estimate.Models, modelCost)
+	}
+	sort.Slice(estimate.Models, func(i, j int) bool { return estimate.Models[i].Model < estimate.Models[j].Model })
+	return estimate
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/tool"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestTokenUsageCost(t *testing.T) {
+	price := config.ModelPrice{InputPerMillion: 2, CachedInputPerMillion: 0.5, OutputPerMillion: 8}
+
+	// Thinking tokens are in the total but not in the candidates, and are billed as output.
+	usage := TokenUsage{PromptTokens: 1000, CachedTokens: 200, CandidateTokens: 50, TotalTokens: 1150}
+	if got := usage.OutputTokens(); got != 150 {
+		t.Errorf("OutputTokens() = %d, want 150", got)
+	}
+	want := (800*2 + 200*0.5 + 150*8) / 1e6
+	if got := usage.Cost(price); math.Abs(got-want) > 1e-12 {
+		t.Errorf("Cost() = %g, want %g", got, want)
+	}
+
+	// Without a cached price, cached tokens cost as much as other prompt tokens.
+	price.CachedInputPerMillion = 0
+	want = (1000*2 + 150*8) / 1e6
+	if got := usage.Cost(price); math.Abs(got-want) > 1e-12 {
+		t.Errorf("Cost() without a cached price = %g, want %g", got, want)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	prices := config.PriceTable{"gemini-a": {InputPerMillion: 1, OutputPerMillion: 1}}
+	usageByModel := map[string]TokenUsage{
+		"gemini-a-001": {PromptTokens: 1e6, CandidateTokens: 1e6, TotalTokens: 2e6},
+		"other":        {PromptTokens: 10, TotalTokens: 10},
+	}
+
+	estimate := EstimateCost(usageByModel, prices)
+	if estimate.Complete || estimate.Total != 2 {
+		t.Errorf("Expected an incomplete total of $2, got %+v", estimate)
+	}
+	if len(estimate.Models) != 2 || estimate.Models[0].Model != "gemini-a-001" || !estimate.Models[0].Priced || estimate.Models[1].Priced {
+		t.Errorf("Unexpected models: %+v", estimate.Models)
+	}
+
+	if estimate := EstimateCost(map[string]TokenUsage{}, prices); !estimate.Complete || estimate.Total != 0 {
+		t.Errorf("Expected a complete zero estimate without usage, got %+v", estimate)
+	}
+}
+
+func TestAgentRecordsTokenUsage(t *testing.T) {
+	_, client := newFakeGemini(t,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"echo","args":{"text":"hi"}}}]}}],
+			"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":2,"totalTokenCount":12}}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Said hi."}]}}],
+			"usageMetadata":{"promptTokenCount":20,"cachedContentTokenCount":5,"candidatesTokenCount":3,"totalTokenCount":23}}]`,
+		`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Bye."}]}}],
+			"usageMetadata":{"promptTokenCount":30,"candidatesTokenCount":1,"totalTokenCount":31}}]`,
+	)
+	registry := tool.NewToolRegistry()
+	registry.RegisterTool(&echoTool{name: "echo"})
+	agent := NewAgent(NewConversation(client), registry, tool.NewExecutor(registry, 1))
+	handler := &TextHandler{Out: &bytes.Buffer{}}
+
+	if err := agent.RunTurn(context.Background(), handler, genai.Text("say hi")); err != nil {
+		t.Fatalf("RunTurn failed: %v", err)
+	}
+	stats := agent.Stats()
+	if len(stats.LastTurn.Requests) != 2 || stats.LastTurn.TokenUsage != (TokenUsage{PromptTokens: 30, CachedTokens: 5, CandidateTokens: 5, TotalTokens: 35}) {
+		t.Errorf("Expected both requests of the tool loop in the turn, got %+v", stats.LastTurn)
+	}
+
+	client.SetModel("gemini-other")
+	if err := agent.RunTurn(context.Background(), handler, genai.Text("bye")); err != nil {
+		t.Fatalf("RunTurn failed: %v", err)
+	}
+	stats = agent.Stats()
+	if len(stats.LastTurn.Requests) != 1 || stats.LastTurn.TotalTokens != 31 {
+		t.Errorf("Expected the last turn alone, got %+v", stats.LastTurn)
+	}
+	if stats.Requests != 3 || stats.Usage.TotalTokens != 66 || stats.Usage.CachedTokens != 5 {
+		t.Errorf("Expected the usage of the session, got %d requests, %+v", stats.Requests, stats.Usage)
+	}
+	if stats.UsageByModel["gemini-pro"].TotalTokens != 35 || stats.UsageByModel["gemini-other"].TotalTokens != 31 {
+		t.Errorf("Expected the usage split by model, got %+v", stats.UsageByModel)
+	}
+}
@@ -0,0 +1,533 @@
+// Package checkpoint saves the files of a project before tools change them, so that the
+// files and the conversation can be rolled back to the state before a tool call.
+//
+// Checkpoints are kept in a content-addressed store: every file content is saved once,
+// under its SHA-256, and a checkpoint maps the project's paths to contents.
+package checkpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/filesystem"
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// MaxFileSize is the size above which files are left out of checkpoints.
+// A restore neither writes nor removes them, since it could not be undone.
+const MaxFileSize = 10 << 20
+
+// RestoreToolName is the tool name of the checkpoint saved before a restore,
+// so that a restore can be undone.
+const RestoreToolName = "restore"
+
+// Checkpoint is the state of a project before a tool call.
+type Checkpoint struct {
+	ID        string              `json:"id"`
+	SessionID string              `json:"sessionId"`
+	Time      time.Time           `json:"time"`
+	ToolCall  shared.FunctionCall `json:"toolCall"`
+	// Files maps the slash-separated paths of the files, relative to the project root, to their content.
+	Files map[string]File `json:"files"`
+	// Skipped lists the files larger than MaxFileSize, which were not saved.
+	Skipped []string `json:"skipped,omitempty"`
+	// History is the hash of the conversation before the turn that made the call.
+	History string `json:"history"`
+}
+
+// File is a saved file.
+type File struct {
+	Hash string      `json:"hash"`
+	Mode fs.FileMode `json:"mode"`
+}
+
+// Describe summarizes the checkpoint on one line: the tool, the file or command it was
+// called with, if any, and the session.
+func (c *Checkpoint) Describe() string {
+	description := c.ToolCall.Name
+	for _, key := range []string{"filePath", "path", "command", "checkpoint"} {
+		if value, ok := c.ToolCall.Args[key].(string); ok && value != "" {
+			description += " " + value
+			break
+		}
+	}
+	session := c.SessionID
+	if len(session) > 8 {
+		session = session[:8]
+	}
+	return fmt.Sprintf("%s (%s, session %s)", description, c.Time.Local().Format("2006-01-02 15:04:05"), session)
+}
+
+// Store saves and restores the checkpoints of the project under root.
+type Store struct {
+	dir  string
+	root string
+	walk filesystem.WalkOptions
+
+	mu sync.Mutex
+	// hashes caches the hashes of the files by path, so that unchanged files are not read again.
+	hashes map[string]cachedHash
+}
+
+type cachedHash struct {
+	size     int64
+	modTime  time.Time
+	hashedAt time.Time
+	hash     string
+}
+
+// NewStore returns a store in dir for the files under root selected by walk.
+// Ignored files are neither saved nor restored.
+func NewStore(dir, root string, walk filesystem.WalkOptions) *Store {
+	return &Store{dir: dir, root: root, walk: walk, hashes: make(map[string]cachedHash)}
+}
+
+// DefaultDir returns where the checkpoints of the project under root are kept:
+// a directory of ~/.gemini/checkpoints named after the project's path.
+func DefaultDir(root string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(absRoot))
+	return filepath.Join(home, config.SettingsDirectoryName, "checkpoints", hex.EncodeToString(sum[:8])), nil
+}
+
+// StoreForConfig returns the store of the project cfg runs in, honoring the fileFiltering settings.
+func StoreForConfig(cfg *config.CliConfig) (*Store, error) {
+	root := cfg.TargetDir
+	if root == "" {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		root = workingDir
+	}
+	dir, err := DefaultDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot locate the checkpoint directory: %w", err)
+	}
+	return NewStore(dir, root, filesystem.WalkOptions{DisableGitIgnore: !cfg.Settings.RespectsGitIgnore()}), nil
+}
+
+// checkpointID is what a checkpoint ID looks like, so that it can name a file.
+var checkpointID = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}\.[0-9]{3}-[a-zA-Z0-9_.-]+$`)
+
+// unsafeIDChars are the characters of tool names that are replaced in checkpoint IDs.
+var unsafeIDChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// Create saves the project's files and history as a checkpoint for call, made in the given session.
+func (s *Store) Create(ctx context.Context, sessionID string, call shared.FunctionCall, history []*genai.Content) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.objectsDir(), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create the checkpoint directory: %w", err)
+	}
+
+	files, skipped, err := s.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := encodeHistory(history)
+	if err != nil {
+		return nil, err
+	}
+	historyHash, err := s.saveObject(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id := now.UTC().Format("20060102-150405.000") + "-" + unsafeIDChars.ReplaceAllString(call.Name, "_")
+	checkpoint := &Checkpoint{
+		ID:        id,
+		SessionID: sessionID,
+		Time:      now,
+		ToolCall:  call,
+		Files:     files,
+		Skipped:   skipped,
+		History:   historyHash,
+	}
+	for n := 2; ; n++ {
+		if _, err := os.Stat(s.checkpointPath(checkpoint.ID)); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		checkpoint.ID = fmt.Sprintf("%s-%d", id, n)
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(s.checkpointPath(checkpoint.ID), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// List returns the project's checkpoints, oldest first.
+func (s *Store) List() ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+	var checkpoints []*Checkpoint
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !checkpointID.MatchString(id) {
+			continue
+		}
+		checkpoint, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	sort.SliceStable(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Time.Before(checkpoints[j].Time)
+	})
+	return checkpoints, nil
+}
+
+// Load returns the checkpoint with the given ID.
+func (s *Store) Load(id string) (*Checkpoint, error) {
+	if !checkpointID.MatchString(id) {
+		return nil, fmt.Errorf("invalid checkpoint ID %q", id)
+	}
+	data, err := os.ReadFile(s.checkpointPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no checkpoint %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", id, err)
+	}
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", id, err)
+	}
+	return &checkpoint, nil
+}
+
+// RestoreResult reports what a restore changed.
+type RestoreResult struct {
+	// Backup is the checkpoint saved before the restore, to undo it.
+	Backup *Checkpoint
+	// History is the conversation of the restored checkpoint.
+	History []*genai.Content
+	// Restored, Recreated and Removed list the files whose content was written back,
+	// the deleted files written back and the files created since the checkpoint.
+	Restored, Recreated, Removed []string
+	// Kept lists the files that differ from the checkpoint but were left alone because
+	// they are larger than MaxFileSize, so the backup could not save them.
+	Kept []string
+}
+
+// Summary describes the changes on one line.
+func (r *RestoreResult) Summary() string {
+	if len(r.Restored)+len(r.Recreated)+len(r.Removed)+len(r.Kept) == 0 {
+		return "No files changed."
+	}
+	var parts []string
+	for _, change := range []struct {
+		verb  string
+		paths []string
+	}{{"Restored", r.Restored}, {"Recreated", r.Recreated}, {"Removed", r.Removed}} {
+		if len(change.paths) > 0 {
+			parts = append(parts, change.verb+" "+strings.Join(change.paths, ", "))
+		}
+	}
+	if len(r.Kept) > 0 {
+		parts = append(parts, "Kept "+strings.Join(r.Kept, ", ")+" (too large to save for undo)")
+	}
+	return strings.Join(parts, ". ") + "."
+}
+
+// Restore rolls the project's files back to checkpoint: changed and deleted files get
+// their content back, and files created since are removed. The current files and history
+// are saved first as a checkpoint of the given session, so the restore can be undone.
+// Files now larger than MaxFileSize are not in that backup, so they are kept as they are.
+func (s *Store) Restore(ctx context.Context, checkpoint *Checkpoint, sessionID string, history []*genai.Content) (*RestoreResult, error) {
+	restoredHistory, err := s.loadHistory(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := s.Create(ctx, sessionID, shared.FunctionCall{
+		Name: RestoreToolName,
+		Args: map[string]interface{}{"checkpoint": checkpoint.ID},
+	}, history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save the current files before restoring: %w", err)
+	}
+	result := &RestoreResult{Backup: backup, History: restoredHistory}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Files larger than MaxFileSize now are left alone, and reported unless they were
+	// too large at the checkpoint as well; the others exist now, or have been removed
+	// since the checkpoint.
+	untracked := make(map[string]bool, len(checkpoint.Skipped))
+	for _, path := range checkpoint.Skipped {
+		untracked[path] = true
+	}
+	kept := make(map[string]bool, len(backup.Skipped))
+	for _, path := range backup.Skipped {
+		kept[path] = true
+		if !untracked[path] {
+			result.Kept = append(result.Kept, path)
+		}
+	}
+
+	for _, path := range sortedPaths(backup.Files) {
+		if _, saved := checkpoint.Files[path]; saved || untracked[path] {
+			continue
+		}
+		if err := os.Remove(s.localPath(path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		result.Removed = append(result.Removed, path)
+	}
+	for _, path := range sortedPaths(checkpoint.Files) {
+		saved := checkpoint.Files[path]
+		current, existing := backup.Files[path]
+		if kept[path] || existing && current == saved {
+			continue
+		}
+		if err := s.restoreFile(path, saved); err != nil {
+			return result, err
+		}
+		if existing {
+			result.Restored = append(result.Restored, path)
+		} else {
+			result.Recreated = append(result.Recreated, path)
+		}
+	}
+	sort.Strings(result.Removed)
+	sort.Strings(result.Kept)
+	return result, nil
+}
+
+// restoreFile writes a saved file back to the project.
+func (s *Store) restoreFile(path string, saved File) error {
+	content, err := os.ReadFile(s.objectPath(saved.Hash))
+	if err != nil {
+		return fmt.Errorf("failed to read the saved content of %s: %w", path, err)
+	}
+	local := s.localPath(path)
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+	if err := os.WriteFile(local, content, saved.Mode.Perm()); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+	// WriteFile leaves the mode of an existing file alone.
+	if err := os.Chmod(local, saved.Mode.Perm()); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+	delete(s.hashes, path)
+	return nil
+}
+
+// loadHistory returns the conversation saved with checkpoint.
+func (s *Store) loadHistory(checkpoint *Checkpoint) ([]*genai.Content, error) {
+	data, err := os.ReadFile(s.objectPath(checkpoint.History))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the conversation of checkpoint %s: %w", checkpoint.ID, err)
+	}
+	return decodeHistory(data)
+}
+
+// scan saves the contents of the project's files. It returns the files and, separately,
+// the paths of those larger than MaxFileSize. It stops when ctx is done.
+func (s *Store) scan(ctx context.Context) (map[string]File, []string, error) {
+	files := make(map[string]File)
+	var skipped []string
+	err := filesystem.Walk(s.root, s.walk, func(entry filesystem.Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, entry.Path)
+		if err != nil {
+			return err
+		}
+		if s.isStorePath(entry.Path) {
+			return nil
+		}
+		info, err := os.Lstat(entry.Path)
+		if err != nil || !info.Mode().IsRegular() {
+			// Removed during the walk, or a link or device that is not saved.
+			return nil
+		}
+		path := filepath.ToSlash(rel)
+		if info.Size() > MaxFileSize {
+			skipped = append(skipped, path)
+			return nil
+		}
+		hash, err := s.saveFile(path, info)
+		if err != nil {
+			return err
+		}
+		files[path] = File{Hash: hash, Mode: info.Mode().Perm()}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, skipped, nil
+}
+
+// racyWindow is how long after a file was modified its modification time is not trusted,
+// since a change within the timestamp granularity of the file system would not alter it.
+const racyWindow = 2 * time.Second
+
+// saveFile saves the content of the file at path and returns its hash. Files whose size and
+// modification time did not change since they were saved are not read again.
+func (s *Store) saveFile(path string, info fs.FileInfo) (string, error) {
+	if cached, ok := s.hashes[path]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) &&
+		info.ModTime().Add(racyWindow).Before(cached.hashedAt) {
+		if _, err := os.Stat(s.objectPath(cached.hash)); err == nil {
+			return cached.hash, nil
+		}
+	}
+	hashedAt := time.Now()
+	content, err := os.ReadFile(s.localPath(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	hash, err := s.saveObject(content)
+	if err != nil {
+		return "", err
+	}
+	s.hashes[path] = cachedHash{size: info.Size(), modTime: info.ModTime(), hashedAt: hashedAt, hash: hash}
+	return hash, nil
+}
+
+// saveObject saves content under its hash, unless it is already saved, and returns the hash.
+func (s *Store) saveObject(content []byte) (string, error) {
+	hash := hashContent(content)
+	path := s.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := writeFileAtomic(path, content, 0600); err != nil {
+		return "", fmt.Errorf("failed to save checkpoint content: %w", err)
+	}
+	return hash, nil
+}
+
+// isStorePath reports whether path is in the store, for a store inside the project.
+func (s *Store) isStorePath(path string) bool {
+	rel, err := filepath.Rel(s.dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (s *Store) localPath(path string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path))
+}
+
+func (s *Store) objectsDir() string {
+	return filepath.Join(s.dir, "objects")
+}
+
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.objectsDir(), hash)
+}
+
+func (s *Store) checkpointPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic writes data to a temporary file that replaces path once complete,
+// so that an interrupted write never leaves a partial file behind.
+func writeFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+func sortedPaths(files map[string]File) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Checkpointer saves a checkpoint before every mutating tool call of a session, with the
+// conversation as it was before the turn that made the call. It implements tool.Checkpointer.
+type Checkpointer struct {
+	Store     *Store
+	SessionID string
+	// History returns the conversation so far; nil saves no conversation.
+	History func() []*genai.Content
+}
+
+// Checkpoint saves the project's files before call runs.
+func (c *Checkpointer) Checkpoint(ctx context.Context, call shared.FunctionCall) error {
+	var history []*genai.Content
+	if c.History != nil {
+		history = BeforeTurn(c.History())
+	}
+	_, err := c.Store.Create(ctx, c.SessionID, call, history)
+	return err
+}
+
+// BeforeTurn returns history without the current turn: the last user message that is not
+// a tool result, and everything after it.
+func BeforeTurn(history []*genai.Content) []*genai.Content {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" && !hasFunctionResponse(history[i]) {
+			return history[:i]
+		}
+	}
+	return nil
+}
+
+func hasFunctionResponse(content *genai.Content) bool {
+	for _, part := range content.Parts {
+		if _, ok := part.(genai.FunctionResponse); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/filesystem"
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// writeFiles writes files, keyed by their slash-separated path, under root.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		local := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(local, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFiles returns the contents of the files under root, keyed by their slash-separated path.
+func readFiles(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	root := t.TempDir()
+	return NewStore(filepath.Join(t.TempDir(), "checkpoints"), root, filesystem.WalkOptions{}), root
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	store, root := newTestStore(t)
+	before := map[string]string{
+		".gitignore":       "build/\n",
+		"main.go":          "package main\n",
+		"docs/notes.txt":   "some notes\n",
+		"build/output.bin": "ignored",
+	}
+	writeFiles(t, root, before)
+	history := []*genai.Content{
+		{Role: "user", Parts: []genai.Part{genai.Text("hello")}},
+		{Role: "model", Parts: []genai.Part{genai.Text("hi")}},
+	}
+	call := shared.FunctionCall{Name: "write_file", Args: map[string]interface{}{"filePath": "main.go"}}
+	checkpoint, err := store.Create(ctx, "session-1", call, history)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !strings.HasSuffix(checkpoint.ID, "-write_file") || len(checkpoint.Files) != 3 {
+		t.Fatalf("Unexpected checkpoint %s with files %v", checkpoint.ID, checkpoint.Files)
+	}
+
+	// A tool modifies a file, deletes one and creates others; ignored files change too.
+	writeFiles(t, root, map[string]string{
+		"main.go":          "package main\n\nfunc main() {}\n",
+		"new/created.go":   "package created\n",
+		"build/output.bin": "rebuilt",
+	})
+	if err := os.Remove(filepath.Join(root, "docs", "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(root, ".gitignore"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	changed := readFiles(t, root)
+
+	result, err := store.Restore(ctx, checkpoint, "session-2", []*genai.Content{{Role: "user", Parts: []genai.Part{genai.Text("later")}}})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if strings.Join(result.Restored, " ") != ".gitignore main.go" ||
+		strings.Join(result.Recreated, " ") != "docs/notes.txt" ||
+		strings.Join(result.Removed, " ") != "new/created.go" {
+		t.Errorf("Unexpected changes: %s", result.Summary())
+	}
+	want := map[string]string{}
+	for path, content := range before {
+		want[path] = content
+	}
+	want["build/output.bin"] = "rebuilt" // Ignored files are left alone.
+	if got := readFiles(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("Restored files =\n%v\nwant\n%v", got, want)
+	}
+	if info, err := os.Stat(filepath.Join(root, ".gitignore")); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the mode to be restored, got %v, %v", info.Mode(), err)
+	}
+	if !reflect.DeepEqual(result.History, history) {
+		t.Errorf("Restored history = %v, want %v", result.History, history)
+	}
+
+	// The restore saved the state before it, so it can be undone.
+	if result.Backup.ToolCall.Name != RestoreToolName || result.Backup.SessionID != "session-2" {
+		t.Fatalf("Unexpected backup %+v", result.Backup)
+	}
+	undo, err := store.Restore(ctx, result.Backup, "session-2", result.History)
+	if err != nil {
+		t.Fatalf("Undoing the restore failed: %v", err)
+	}
+	if got := readFiles(t, root); !reflect.DeepEqual(got, changed) {
+		t.Errorf("Files after undoing the restore =\n%v\nwant\n%v", got, changed)
+	}
+	if len(undo.History) != 1 || undo.History[0].Parts[0] != genai.Text("later") {
+		t.Errorf("Expected the history of the backup, got %v", undo.History)
+	}
+
+	// Nothing changed since the latest checkpoint.
+	latest, err := store.Create(ctx, "session-2", call, nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	again, err := store.Restore(ctx, latest, "session-2", nil)
+	if err != nil || again.Summary() != "No files changed." {
+		t.Errorf("Expected no changes, got %v, %v", again.Summary(), err)
+	}
+}
+
+func TestRestoreKeepsLargeFiles(t *testing.T) {
+	ctx := context.Background()
+	store, root := newTestStore(t)
+	writeFiles(t, root, map[string]string{"a.txt": "a", "grown.txt": "small"})
+	checkpoint, err := store.Create(ctx, "session", shared.FunctionCall{Name: "write_file"}, nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Files too large for the backup can be neither removed nor overwritten.
+	large := strings.Repeat("x", MaxFileSize+1)
+	writeFiles(t, root, map[string]string{"a.txt": "changed", "large.bin": large, "grown.txt": large})
+	result, err := store.Restore(ctx, checkpoint, "session", nil)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	want := map[string]string{"a.txt": "a", "large.bin": large, "grown.txt": large}
+	if got := readFiles(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the large files to be kept, got %d files", len(got))
+	}
+	if strings.Join(result.Kept, " ") != "grown.txt large.bin" || len(result.Removed) != 0 {
+		t.Errorf("Unexpected changes: %s", result.Summary())
+	}
+	if summary := result.Summary(); !strings.Contains(summary, "Kept grown.txt, large.bin (too large to save for undo)") {
+		t.Errorf("Expected the kept files in the summary, got %q", summary)
+	}
+}
+
+func TestCheckpointStopsWhenCancelled(t *testing.T) {
+	store, root := newTestStore(t)
+	writeFiles(t, root, map[string]string{"a.txt": "a"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	checkpointer := &Checkpointer{Store: store, SessionID: "session"}
+	if err := checkpointer.Checkpoint(ctx, shared.FunctionCall{Name: "write_file"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if checkpoints, err := store.List(); err != nil || len(checkpoints) != 0 {
+		t.Errorf("Expected no checkpoint, got %v, %v", checkpoints, err)
+	}
+}
+
+func TestListAndLoad(t *testing.T) {
+	ctx := context.Background()
+	store, root := newTestStore(t)
+	if checkpoints, err := store.List(); err != nil || len(checkpoints) != 0 {
+		t.Fatalf("Expected no checkpoints in a new store, got %v, %v", checkpoints, err)
+	}
+	writeFiles(t, root, map[string]string{"a.txt": "a"})
+
+	var ids []string
+	for _, name := range []string{"edit_file", "edit_file", "server__tool/with:odd chars"} {
+		checkpoint, err := store.Create(ctx, "session", shared.FunctionCall{Name: name}, nil)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		ids = append(ids, checkpoint.ID)
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("Expected unique IDs, got %v", ids)
+	}
+	checkpoints, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var listed []string
+	for _, checkpoint := range checkpoints {
+		listed = append(listed, checkpoint.ID)
+	}
+	if !reflect.DeepEqual(listed, ids) {
+		t.Errorf("List() = %v, want %v", listed, ids)
+	}
+	if !strings.HasSuffix(ids[2], "-server__tool_with_odd_chars") {
+		t.Errorf("Expected the tool name to be made safe, got %s", ids[2])
+	}
+
+	if _, err := store.Load("../../etc/passwd"); err == nil {
+		t.Error("Expected an error for an invalid ID")
+	}
+	if _, err := store.Load("20200101-000000.000-missing"); err == nil {
+		t.Error("Expected an error for a missing checkpoint")
+	}
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	history := []*genai.Content{
+		{Role: "user", Parts: []genai.Part{genai.Text("look at this"), genai.Blob{MIMEType: "image/png", Data: []byte{1, 2}}}},
+		{Role: "model", Parts: []genai.Part{genai.FunctionCall{Name: "read_file", Args: map[string]interface{}{"path": "a.txt"}}}},
+		{Role: "user", Parts: []genai.Part{genai.FunctionResponse{Name: "read_file", Response: map[string]interface{}{"output": "a"}}}},
+		{Role: "model", Parts: []genai.Part{genai.FileData{MIMEType: "text/plain", URI: "https://example.com/a.txt"}}},
+	}
+	data, err := encodeHistory(history)
+	if err != nil {
+		t.Fatalf("encodeHistory failed: %v", err)
+	}
+	got, err := decodeHistory(data)
+	if err != nil {
+		t.Fatalf("decodeHistory failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, history) {
+		t.Errorf("Round trip gave %v, want %v", got, history)
+	}
+}
+
+func TestCheckpointerSavesHistoryBeforeTurn(t *testing.T) {
+	store, _ := newTestStore(t)
+	earlier := []*genai.Content{
+		{Role: "user", Parts: []genai.Part{genai.Text("first")}},
+		{Role: "model", Parts: []genai.Part{genai.Text("answer")}},
+	}
+	history := append(append([]*genai.Content(nil), earlier...),
+		&genai.Content{Role: "user", Parts: []genai.Part{genai.Text("write a file")}},
+		&genai.Content{Role: "model", Parts: []genai.Part{genai.FunctionCall{Name: "list_files"}}},
+		&genai.Content{Role: "user", Parts: []genai.Part{genai.FunctionResponse{Name: "list_files"}}},
+		&genai.Content{Role: "model", Parts: []genai.Part{genai.FunctionCall{Name: "write_file"}}},
+	)
+	checkpointer := &Checkpointer{Store: store, SessionID: "session", History: func() []*genai.Content { return history }}
+	if err := checkpointer.Checkpoint(context.Background(), shared.FunctionCall{Name: "write_file"}); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	checkpoints, err := store.List()
+	if err != nil || len(checkpoints) != 1 {
+		t.Fatalf("Expected one checkpoint, got %v, %v", checkpoints, err)
+	}
+	saved, err := store.loadHistory(checkpoints[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(saved, earlier) {
+		t.Errorf("Expected the history before the turn, got %v", saved)
+	}
+	if description := checkpoints[0].Describe(); !strings.HasPrefix(description, "write_file (") || !strings.HasSuffix(description, "session session)") {
+		t.Errorf("Unexpected description %q", description)
+	}
+}
@@ -0,0 +1,82 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// savedContent is a turn of the conversation as saved in a checkpoint.
+type savedContent struct {
+	Role  string      `json:"role"`
+	Parts []savedPart `json:"parts"`
+}
+
+// savedPart holds one of the kinds of parts a conversation is made of.
+type savedPart struct {
+	Text             *string                 `json:"text,omitempty"`
+	InlineData       *genai.Blob             `json:"inlineData,omitempty"`
+	FileData         *genai.FileData         `json:"fileData,omitempty"`
+	FunctionCall     *genai.FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *genai.FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// encodeHistory encodes a conversation as JSON.
+func encodeHistory(history []*genai.Content) ([]byte, error) {
+	saved := make([]savedContent, 0, len(history))
+	for _, content := range history {
+		turn := savedContent{Role: content.Role}
+		for _, part := range content.Parts {
+			var savedPart savedPart
+			switch part := part.(type) {
+			case genai.Text:
+				text := string(part)
+				savedPart.Text = &text
+			case genai.Blob:
+				savedPart.InlineData = &part
+			case genai.FileData:
+				savedPart.FileData = &part
+			case genai.FunctionCall:
+				savedPart.FunctionCall = &part
+			case genai.FunctionResponse:
+				savedPart.FunctionResponse = &part
+			default:
+				return nil, fmt.Errorf("cannot save a conversation part of type %T", part)
+			}
+			turn.Parts = append(turn.Parts, savedPart)
+		}
+		saved = append(saved, turn)
+	}
+	return json.Marshal(saved)
+}
+
+// decodeHistory decodes a conversation encoded by encodeHistory.
+func decodeHistory(data []byte) ([]*genai.Content, error) {
+	var saved []savedContent
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid saved conversation: %w", err)
+	}
+	history := make([]*genai.Content, 0, len(saved))
+	for _, turn := range saved {
+		content := &genai.Content{Role: turn.Role}
+		for _, part := range turn.Parts {
+			switch {
+			case part.Text != nil:
+				content.Parts = append(content.Parts, genai.Text(*part.Text))
+			case part.InlineData != nil:
+				content.Parts = append(content.Parts, *part.InlineData)
+			case part.FileData != nil:
+				content.Parts = append(content.Parts, *part.FileData)
+			case part.FunctionCall != nil:
+				content.Parts = append(content.Parts, *part.FunctionCall)
+			case part.FunctionResponse != nil:
+				content.Parts = append(content.Parts, *part.FunctionResponse)
+			default:
+				return nil, fmt.Errorf("invalid saved conversation: empty part")
+			}
+		}
+		history = append(history, content)
+	}
+	return history, nil
+}
@@ -0,0 +1,233 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gemini-cli-go/internal/api"
+	"gemini-cli-go/internal/checkpoint"
+	"gemini-cli-go/internal/config"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// HelpCommand lists the available commands and input prefixes.
+type HelpCommand struct{}
+
+func (c *HelpCommand) Name() string        { return "help" }
+func (c *HelpCommand) Description() string { return "Show available commands." }
+
+func (c *HelpCommand) Execute(ctx context.Context, session *Session, args string) error {
+	fmt.Fprintln(session.Out, "Commands:")
+	if session.Commands != nil {
+		for _, command := range session.Commands.Commands() {
+			fmt.Fprintf(session.Out, "  /%-10s %s\n", command.Name(), command.Description())
+		}
+	}
+	fmt.Fprintln(session.Out, "Input:")
+	fmt.Fprintf(session.Out, "  %-11s %s\n", "@path", "Include the file or directory at path in the prompt.")
+	fmt.Fprintf(session.Out, "  %-11s %s\n", "!command", "Run a shell command without sending it to the model.")
+	return nil
+}
+
+// ClearCommand starts a new conversation.
+type ClearCommand struct{}
+
+func (c *ClearCommand) Name() string        { return "clear" }
+func (c *ClearCommand) Description() string { return "Clear the conversation history." }
+
+func (c *ClearCommand) Execute(ctx context.Context, session *Session, args string) error {
+	if session.Agent == nil {
+		return fmt.Errorf("no conversation to clear")
+	}
+	session.Agent.Conversation().Clear()
+	fmt.Fprintln(session.Out, "Conversation cleared.")
+	return nil
+}
+
+// ModelCommand shows or switches the model.
+type ModelCommand struct{}
+
+func (c *ModelCommand) Name() string        { return "model" }
+func (c *ModelCommand) Description() string { return "Show or switch the model (/model <name>)." }
+
+func (c *ModelCommand) Execute(ctx context.Context, session *Session, args string) error {
+	if session.Client == nil {
+		return fmt.Errorf("no model client available")
+	}
+	if args == "" {
+		fmt.Fprintf(session.Out, "Current model: %s\n", session.Client.ModelName())
+		return nil
+	}
+	session.Client.SetModel(args)
+	fmt.Fprintf(session.Out, "Switched model to %s.\n", args)
+	return nil
+}
+
+// ToolsCommand lists the tools available to the model.
+type ToolsCommand struct{}
+
+func (c *ToolsCommand) Name() string        { return "tools" }
+func (c *ToolsCommand) Description() string { return "List the tools available to the model." }
+
+func (c *ToolsCommand) Execute(ctx context.Context, session *Session, args string) error {
+	if session.Tools == nil {
+		fmt.Fprintln(session.Out, "No tools available.")
+		return nil
+	}
+	declarations := session.Tools.GetFunctionDeclarations()
+	if len(declarations) == 0 {
+		fmt.Fprintln(session.Out, "No tools available.")
+		return nil
+	}
+	sort.Slice(declarations, func(i, j int) bool {
+		return declarations[i].Name < declarations[j].Name
+	})
+	fmt.Fprintln(session.Out, "Available tools:")
+	for _, declaration := range declarations {
+		description, _, _ := strings.Cut(declaration.Description, "\n")
+		fmt.Fprintf(session.Out, "  %s - %s\n", declaration.Name, description)
+	}
+	return nil
+}
+
+// MemoryCommand shows the instruction files loaded for the session and where they came from.
+type MemoryCommand struct{}
+
+func (c *MemoryCommand) Name() string { return "memory" }
+func (c *MemoryCommand) Description() string {
+	return "Show the loaded memory (/memory show, /memory list)."
+}
+
+func (c *MemoryCommand) Execute(ctx context.Context, session *Session, args string) error {
+	switch args {
+	case "", "show":
+		fmt.Fprintln(session.Out, session.Memory.Summary())
+		if text := session.Memory.Text(); text != "" {
+			fmt.Fprintf(session.Out, "\n%s\n", text)
+		}
+		return nil
+	case "list":
+		fmt.Fprintln(session.Out, session.Memory.Summary())
+		return nil
+	default:
+		return fmt.Errorf("unknown /memory subcommand %q", args)
+	}
+}
+
+// StatsCommand shows the activity of the session.
+type StatsCommand struct{}
+
+func (c *StatsCommand) Name() string        { return "stats" }
+func (c *StatsCommand) Description() string { return "Show session statistics." }
+
+func (c *StatsCommand) Execute(ctx context.Context, session *Session, args string) error {
+	if session.Agent == nil {
+		return fmt.Errorf("no session statistics available")
+	}
+	stats := session.Agent.Stats()
+	fmt.Fprintf(session.Out, "Session duration: %s\n", time.Since(stats.StartTime).Round(time.Second))
+	fmt.Fprintf(session.Out, "Turns:            %d\n", stats.Turns)
+	fmt.Fprintf(session.Out, "Tool calls:       %d (%d failed)\n", stats.ToolCalls, stats.FailedToolCalls)
+	fmt.Fprintf(session.Out, "Requests:         %d\n", stats.Requests)
+	fmt.Fprintf(session.Out, "Tokens:           %s\n", formatUsage(stats.Usage))
+	if len(stats.LastTurn.Requests) > 0 {
+		fmt.Fprintf(session.Out, "Last turn:        %d requests, %s\n", len(stats.LastTurn.Requests), formatUsage(stats.LastTurn.TokenUsage))
+	}
+	if len(stats.UsageByModel) == 0 {
+		return nil
+	}
+
+	prices := session.Pricing
+	if prices == nil {
+		prices = config.Settings{}.PriceTable()
+	}
+	estimate := api.EstimateCost(stats.UsageByModel, prices)
+	incomplete := ""
+	if !estimate.Complete {
+		incomplete = " (some models have no price; set modelPricing in settings.json)"
+	}
+	fmt.Fprintf(session.Out, "Estimated cost:   $%.4f%s\n", estimate.Total, incomplete)
+	for _, model := range estimate.Models {
+		cost := "no price"
+		if model.Priced {
+			cost = fmt.Sprintf("$%.4f", model.Cost)
+		}
+		fmt.Fprintf(session.Out, "- %s: %d tokens, %s\n", model.Model, model.Usage.TotalTokens, cost)
+	}
+	return nil
+}
+
+// formatUsage summarizes token counts on one line.
+func formatUsage(usage api.TokenUsage) string {
+	return fmt.Sprintf("%d prompt (%d cached), %d candidates, %d total",
+		usage.PromptTokens, usage.CachedTokens, usage.CandidateTokens, usage.TotalTokens)
+}
+
+// RestoreCommand lists the checkpoints saved before tools changed files, or rolls the files
+// and the conversation back to one of them.
+type RestoreCommand struct{}
+
+func (c *RestoreCommand) Name() string { return "restore" }
+func (c *RestoreCommand) Description() string {
+	return "List checkpoints, or roll files and conversation back to one (/restore <id>)."
+}
+
+func (c *RestoreCommand) Execute(ctx context.Context, session *Session, args string) error {
+	if session.Checkpoints == nil {
+		return fmt.Errorf("no checkpoints available")
+	}
+	if args == "" {
+		return PrintCheckpoints(session.Out, session.Checkpoints)
+	}
+	restored, err := session.Checkpoints.Load(args)
+	if err != nil {
+		return err
+	}
+	var history []*genai.Content
+	if session.Agent != nil {
+		history = session.Agent.Conversation().History()
+	}
+	result, err := session.Checkpoints.Restore(ctx, restored, session.SessionID, history)
+	if err != nil {
+		return err
+	}
+	if session.Agent != nil {
+		session.Agent.Conversation().SetHistory(result.History)
+	}
+	fmt.Fprintln(session.Out, result.Summary())
+	fmt.Fprintf(session.Out, "Conversation rolled back to before the turn that called %s. Undo with /restore %s\n",
+		restored.ToolCall.Name, result.Backup.ID)
+	return nil
+}
+
+// PrintCheckpoints lists the checkpoints of store, oldest first.
+func PrintCheckpoints(out io.Writer, store *checkpoint.Store) error {
+	checkpoints, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		fmt.Fprintln(out, "No checkpoints. They are saved before tools change files when checkpointing is enabled (--checkpointing or the checkpointing.enabled setting).")
+		return nil
+	}
+	fmt.Fprintln(out, "Checkpoints, oldest first:")
+	for _, saved := range checkpoints {
+		fmt.Fprintf(out, "  %s  %s\n", saved.ID, saved.Describe())
+	}
+	return nil
+}
+
+// QuitCommand ends the session.
+type QuitCommand struct{}
+
+func (c *QuitCommand) Name() string        { return "quit" }
+func (c *QuitCommand) Description() string { return "Exit the interactive session." }
+
+func (c *QuitCommand) Execute(ctx context.Context, session *Session, args string) error {
+	return ErrQuit
+}
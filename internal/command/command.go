@@ -0,0 +1,108 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gemini-cli-go/internal/api"
+	"gemini-cli-go/internal/checkpoint"
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/filesystem"
+	"gemini-cli-go/internal/memory"
+	"gemini-cli-go/internal/shared"
+)
+
+// ErrQuit is returned by a command that ends the interactive session.
+var ErrQuit = errors.New("quit")
+
+// Session is the state of the interactive session that commands act on.
+type Session struct {
+	Out      io.Writer
+	Client   *api.Client
+	Agent    *api.Agent
+	Tools    shared.ToolRegistryInterface
+	Commands *CommandRegistry
+	// Memory is the instruction files loaded for the session, if any.
+	Memory *memory.Memory
+	// Walk selects the files included from directories referenced with @path.
+	Walk filesystem.WalkOptions
+	// Classify sets the size limit for files referenced with @path.
+	Classify filesystem.ClassifyOptions
+	// Pricing prices the session's tokens in /stats. Nil selects config.DefaultModelPricing.
+	Pricing config.PriceTable
+	// Checkpoints holds the project's checkpoints for /restore, if any.
+	Checkpoints *checkpoint.Store
+	// SessionID tags the checkpoint saved before a restore.
+	SessionID string
+}
+
+// Command is a slash command of the interactive session, invoked as /name.
+type Command interface {
+	Name() string
+	Description() string
+	// Execute runs the command. args is the rest of the input line after the command name.
+	Execute(ctx context.Context, session *Session, args string) error
+}
+
+// CommandRegistry manages available slash commands.
+type CommandRegistry struct {
+	commands map[string]Command
+}
+
+// NewCommandRegistry creates a new CommandRegistry.
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands: make(map[string]Command),
+	}
+}
+
+// RegisterCommand registers a command with the registry.
+func (cr *CommandRegistry) RegisterCommand(command Command) {
+	cr.commands[command.Name()] = command
+}
+
+// GetCommand retrieves a command by its name, without the leading slash.
+func (cr *CommandRegistry) GetCommand(name string) (Command, bool) {
+	command, ok := cr.commands[name]
+	return command, ok
+}
+
+// Commands returns all registered commands sorted by name.
+func (cr *CommandRegistry) Commands() []Command {
+	commands := make([]Command, 0, len(cr.commands))
+	for _, command := range cr.commands {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name() < commands[j].Name()
+	})
+	return commands
+}
+
+// Execute parses a "/name args" input line and runs the named command.
+func (cr *CommandRegistry) Execute(ctx context.Context, session *Session, input string) error {
+	name, args, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(input), "/"), " ")
+	command, ok := cr.GetCommand(name)
+	if !ok {
+		return fmt.Errorf("unknown command /%s; type /help for a list of commands", name)
+	}
+	return command.Execute(ctx, session, strings.TrimSpace(args))
+}
+
+// NewBuiltinRegistry returns a registry with the built-in commands.
+func NewBuiltinRegistry() *CommandRegistry {
+	registry := NewCommandRegistry()
+	registry.RegisterCommand(&HelpCommand{})
+	registry.RegisterCommand(&ClearCommand{})
+	registry.RegisterCommand(&ModelCommand{})
+	registry.RegisterCommand(&ToolsCommand{})
+	registry.RegisterCommand(&MemoryCommand{})
+	registry.RegisterCommand(&StatsCommand{})
+	registry.RegisterCommand(&RestoreCommand{})
+	registry.RegisterCommand(&QuitCommand{})
+	return registry
+}
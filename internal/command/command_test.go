@@ -0,0 +1,387 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/api"
+	"gemini-cli-go/internal/checkpoint"
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/filesystem"
+	"gemini-cli-go/internal/memory"
+	"gemini-cli-go/internal/shared"
+	"gemini-cli-go/internal/tool"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// newTestSession returns a session backed by a client that is never called.
+func newTestSession(t *testing.T) (*Session, *bytes.Buffer) {
+	t.Helper()
+	client, err := api.NewClient(context.Background(), "test-api-key", nil, "gemini-pro")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	registry := tool.NewToolRegistry()
+	registry.RegisterTool(&tool.ReadTool{})
+	registry.RegisterTool(&tool.ListFilesTool{})
+
+	var out bytes.Buffer
+	session := &Session{
+		Out:      &out,
+		Client:   client,
+		Agent:    api.NewAgent(api.NewConversation(client), registry, tool.NewExecutor(registry, 1)),
+		Tools:    registry,
+		Commands: NewBuiltinRegistry(),
+	}
+	return session, &out
+}
+
+type testCommand struct {
+	args string
+}
+
+func (c *testCommand) Name() string        { return "test" }
+func (c *testCommand) Description() string { return "A test command." }
+func (c *testCommand) Execute(ctx context.Context, session *Session, args string) error {
+	c.args = args
+	return nil
+}
+
+func TestCommandRegistry(t *testing.T) {
+	registry := NewCommandRegistry()
+	command := &testCommand{}
+	registry.RegisterCommand(command)
+
+	if _, ok := registry.GetCommand("test"); !ok {
+		t.Error("Expected to find 'test' command")
+	}
+	if err := registry.Execute(context.Background(), &Session{}, "/test  some args "); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if command.args != "some args" {
+		t.Errorf("Expected args 'some args', got %q", command.args)
+	}
+	if err := registry.Execute(context.Background(), &Session{}, "/missing"); err == nil {
+		t.Error("Expected an error for an unknown command")
+	}
+}
+
+func TestBuiltinRegistryIsSorted(t *testing.T) {
+	var names []string
+	for _, command := range NewBuiltinRegistry().Commands() {
+		names = append(names, command.Name())
+	}
+	want := "clear help memory model quit restore stats tools"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("Expected commands %q, got %q", want, got)
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	session, out := newTestSession(t)
+	if err := session.Commands.Execute(context.Background(), session, "/help"); err != nil {
+		t.Fatalf("/help failed: %v", err)
+	}
+	for _, want := range []string{"/clear", "/model", "/quit", "@path", "!command"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected help to mention %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestClearCommand(t *testing.T) {
+	session, out := newTestSession(t)
+	conversation := session.Agent.Conversation()
+	conversation.SetHistory([]*genai.Content{{Role: "user", Parts: []genai.Part{genai.Text("hi")}}})
+
+	if err := session.Commands.Execute(context.Background(), session, "/clear"); err != nil {
+		t.Fatalf("/clear failed: %v", err)
+	}
+	if len(conversation.History()) != 0 {
+		t.Errorf("Expected empty history, got %d turns", len(conversation.History()))
+	}
+	if !strings.Contains(out.String(), "Conversation cleared.") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
+
+func TestModelCommand(t *testing.T) {
+	session, out := newTestSession(t)
+	ctx := context.Background()
+
+	if err := session.Commands.Execute(ctx, session, "/model"); err != nil {
+		t.Fatalf("/model failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Current model: gemini-pro") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+
+	if err := session.Commands.Execute(ctx, session, "/model gemini-1.5-flash"); err != nil {
+		t.Fatalf("/model failed: %v", err)
+	}
+	if session.Client.ModelName() != "gemini-1.5-flash" {
+		t.Errorf("Expected model to be switched, got %q", session.Client.ModelName())
+	}
+}
+
+func TestToolsCommand(t *testing.T) {
+	session, out := newTestSession(t)
+	if err := session.Commands.Execute(context.Background(), session, "/tools"); err != nil {
+		t.Fatalf("/tools failed: %v", err)
+	}
+	output := out.String()
+	listIndex := strings.Index(output, "list_files")
+	readIndex := strings.Index(output, "read_file")
+	if listIndex < 0 || readIndex < 0 || listIndex > readIndex {
+		t.Errorf("Expected tools listed in order, got:\n%s", output)
+	}
+}
+
+func TestMemoryCommand(t *testing.T) {
+	session, out := newTestSession(t)
+	ctx := context.Background()
+
+	if err := session.Commands.Execute(ctx, session, "/memory"); err != nil {
+		t.Fatalf("/memory failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No memory loaded.") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+
+	session.Memory = &memory.Memory{Files: []memory.File{
+		{Path: "/home/me/.gemini/GEMINI.md", Source: memory.SourceGlobal, Content: "Always answer in haiku."},
+	}}
+	out.Reset()
+	if err := session.Commands.Execute(ctx, session, "/memory show"); err != nil {
+		t.Fatalf("/memory show failed: %v", err)
+	}
+	for _, want := range []string{"- /home/me/.gemini/GEMINI.md (global, 23 bytes)", "--- Context from: /home/me/.gemini/GEMINI.md (global) ---", "Always answer in haiku."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output, got: %s", want, out.String())
+		}
+	}
+	out.Reset()
+	if err := session.Commands.Execute(ctx, session, "/memory list"); err != nil {
+		t.Fatalf("/memory list failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Loaded 1 instruction files") || strings.Contains(out.String(), "haiku") {
+		t.Errorf("Expected only the list of files, got: %s", out.String())
+	}
+	if err := session.Commands.Execute(ctx, session, "/memory forget"); err == nil {
+		t.Error("Expected an error for an unknown subcommand")
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	session, out := newTestSession(t)
+	if err := session.Commands.Execute(context.Background(), session, "/stats"); err != nil {
+		t.Fatalf("/stats failed: %v", err)
+	}
+	for _, want := range []string{"Turns:            0", "Tool calls:       0 (0 failed)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestStatsCommandShowsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi."}]}}],
+			"usageMetadata":{"promptTokenCount":1000,"cachedContentTokenCount":400,"candidatesTokenCount":100,"totalTokenCount":1100}}]`))
+	}))
+	defer server.Close()
+	client, err := api.NewClient(context.Background(), "test-api-key", server.Client(), "gemini-pro", option.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := tool.NewToolRegistry()
+	var out bytes.Buffer
+	session := &Session{
+		Out:      &out,
+		Client:   client,
+		Agent:    api.NewAgent(api.NewConversation(client), registry, tool.NewExecutor(registry, 1)),
+		Commands: NewBuiltinRegistry(),
+		Pricing:  config.PriceTable{"gemini-pro": {InputPerMillion: 1, CachedInputPerMillion: 0.5, OutputPerMillion: 10}},
+	}
+	if err := session.Agent.RunTurn(context.Background(), &api.TextHandler{Out: io.Discard}, genai.Text("hello")); err != nil {
+		t.Fatalf("RunTurn failed: %v", err)
+	}
+
+	if err := session.Commands.Execute(context.Background(), session, "/stats"); err != nil {
+		t.Fatalf("/stats failed: %v", err)
+	}
+	// 600 prompt tokens at $1, 400 cached at $0.50 and 100 output at $10 per million.
+	for _, want := range []string{
+		"Requests:         1",
+		"Tokens:           1000 prompt (400 cached), 100 candidates, 1100 total",
+		"Last turn:        1 requests,",
+		"Estimated cost:   $0.0018\n",
+		"- gemini-pro: 1100 tokens, $0.0018",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	// Without a price, the cost is reported as incomplete.
+	out.Reset()
+	session.Pricing = config.PriceTable{}
+	if err := session.Commands.Execute(context.Background(), session, "/stats"); err != nil {
+		t.Fatalf("/stats failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "some models have no price") || !strings.Contains(out.String(), "gemini-pro: 1100 tokens, no price") {
+		t.Errorf("Expected an incomplete estimate, got:\n%s", out.String())
+	}
+}
+
+func TestRestoreCommand(t *testing.T) {
+	session, out := newTestSession(t)
+	ctx := context.Background()
+	if err := session.Commands.Execute(ctx, session, "/restore"); err == nil {
+		t.Error("Expected an error without checkpoints")
+	}
+
+	root := t.TempDir()
+	path := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(path, []byte("before"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	session.Checkpoints = checkpoint.NewStore(filepath.Join(t.TempDir(), "checkpoints"), root, filesystem.WalkOptions{})
+	session.SessionID = "session"
+	if err := session.Commands.Execute(ctx, session, "/restore"); err != nil || !strings.Contains(out.String(), "No checkpoints.") {
+		t.Fatalf("Expected no checkpoints, got %q, %v", out.String(), err)
+	}
+
+	earlier := []*genai.Content{{Role: "user", Parts: []genai.Part{genai.Text("hi")}}, {Role: "model", Parts: []genai.Part{genai.Text("hello")}}}
+	saved, err := session.Checkpoints.Create(ctx, "session", shared.FunctionCall{Name: "write_file", Args: map[string]interface{}{"filePath": path}}, earlier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("after"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conversation := session.Agent.Conversation()
+	conversation.SetHistory(append(earlier, &genai.Content{Role: "user", Parts: []genai.Part{genai.Text("write it")}}))
+
+	out.Reset()
+	if err := session.Commands.Execute(ctx, session, "/restore"); err != nil {
+		t.Fatalf("/restore failed: %v", err)
+	}
+	if !strings.Contains(out.String(), saved.ID+"  write_file "+path) {
+		t.Errorf("Expected the checkpoint to be listed, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := session.Commands.Execute(ctx, session, "/restore "+saved.ID); err != nil {
+		t.Fatalf("/restore %s failed: %v", saved.ID, err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "before" {
+		t.Errorf("Expected the file to be restored, got %q", content)
+	}
+	if len(conversation.History()) != 2 {
+		t.Errorf("Expected the conversation to be rolled back, got %d turns", len(conversation.History()))
+	}
+	if !strings.Contains(out.String(), "Restored notes.txt.") || !strings.Contains(out.String(), "Undo with /restore ") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+	if err := session.Commands.Execute(ctx, session, "/restore 20200101-000000.000-missing"); err == nil {
+		t.Error("Expected an error for an unknown checkpoint")
+	}
+}
+
+func TestQuitCommand(t *testing.T) {
+	session, _ := newTestSession(t)
+	if err := session.Commands.Execute(context.Background(), session, "/quit"); !errors.Is(err, ErrQuit) {
+		t.Errorf("Expected ErrQuit, got %v", err)
+	}
+}
+
+func TestExpandFileReferences(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("beta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Input without references is unchanged, and a lone "@" is not a reference.
+	if got, err := ExpandFileReferences(context.Background(), "mail me @ home", filesystem.WalkOptions{}, filesystem.ClassifyOptions{}, io.Discard); err != nil || got != "mail me @ home" {
+		t.Errorf("Expected input unchanged, got %q, %v", got, err)
+	}
+
+	got, err := ExpandFileReferences(context.Background(), "compare @"+filepath.Join(dir, "a.txt")+" and @"+filepath.Join(dir, "sub"), filesystem.WalkOptions{}, filesystem.ClassifyOptions{}, io.Discard)
+	if err != nil {
+		t.Fatalf("ExpandFileReferences failed: %v", err)
+	}
+	for _, want := range []string{"alpha", "beta", "--- File: " + filepath.Join(dir, "sub", "b.txt") + " ---"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected prompt to contain %q, got:\n%s", want, got)
+		}
+	}
+
+	// Words that name no file, such as mentions, are sent as they are with a warning.
+	var warnings bytes.Buffer
+	input := "ask @alice to read @" + filepath.Join(dir, "a.txt") + " and @" + filepath.Join(dir, "missing.txt")
+	got, err = ExpandFileReferences(context.Background(), input, filesystem.WalkOptions{}, filesystem.ClassifyOptions{}, &warnings)
+	if err != nil {
+		t.Fatalf("ExpandFileReferences failed: %v", err)
+	}
+	if !strings.HasPrefix(got, input+"\n") || !strings.Contains(got, "alpha") || strings.Contains(got, "--- File: alice") {
+		t.Errorf("Expected the unresolved references kept as text, got:\n%s", got)
+	}
+	if w := warnings.String(); !strings.Contains(w, "Warning: no file named alice, @alice is sent as text") || !strings.Contains(w, "missing.txt") {
+		t.Errorf("Expected a warning for each unresolved reference, got %q", w)
+	}
+	if got, err := ExpandFileReferences(context.Background(), "thanks @alice", filesystem.WalkOptions{}, filesystem.ClassifyOptions{}, io.Discard); err != nil || got != "thanks @alice" {
+		t.Errorf("Expected input with only unresolved references unchanged, got %q, %v", got, err)
+	}
+
+	// Binary and too large files are listed instead of included.
+	if err := os.WriteFile(filepath.Join(dir, "sub", "c.bin"), []byte("\x00\x01\x02"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ExpandFileReferences(context.Background(), "look at @"+filepath.Join(dir, "sub")+" @"+filepath.Join(dir, "a.txt"), filesystem.WalkOptions{}, filesystem.ClassifyOptions{MaxFileSize: 4}, io.Discard)
+	if err != nil {
+		t.Fatalf("ExpandFileReferences failed: %v", err)
+	}
+	for _, want := range []string{
+		"beta",
+		filepath.Join(dir, "sub", "c.bin") + ": binary (application/octet-stream, 3 bytes)",
+		filepath.Join(dir, "a.txt") + ": too large (limit is 4 bytes, 5 bytes)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected prompt to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "alpha") {
+		t.Errorf("Expected the too large file to be left out, got:\n%s", got)
+	}
+}
+
+func TestRunShellCommand(t *testing.T) {
+	var out bytes.Buffer
+	if err := RunShellCommand(context.Background(), "echo hello", &out); err != nil {
+		t.Fatalf("RunShellCommand failed: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != "hello" {
+		t.Errorf("Expected 'hello', got %q", out.String())
+	}
+	if err := RunShellCommand(context.Background(), "exit 3", &out); err == nil {
+		t.Error("Expected an error for a failing command")
+	}
+}
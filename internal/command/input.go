@@ -0,0 +1,89 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gemini-cli-go/internal/api"
+	"gemini-cli-go/internal/filesystem"
+	"gemini-cli-go/internal/process"
+)
+
+// ExpandFileReferences appends the content of every file or directory referenced
+// as @path in input to the prompt. Input without references is returned unchanged.
+// Directories are walked with walk, and their text files are read concurrently until
+// ctx is done. Binary and too large files, and generated files in directories, are
+// listed as skipped instead. A word such as @someone that names no file is left as
+// it is, with a warning written to out.
+func ExpandFileReferences(ctx context.Context, input string, walk filesystem.WalkOptions, classify filesystem.ClassifyOptions, out io.Writer) (string, error) {
+	var (
+		files   []filesystem.FileContent
+		skipped []filesystem.SkippedFile
+	)
+	for _, word := range strings.Fields(input) {
+		if len(word) < 2 || word[0] != '@' {
+			continue
+		}
+		path := word[1:]
+
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(out, "Warning: no file named %s, @%s is sent as text\n", path, path)
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot include @%s: %w", path, err)
+		}
+		if info.IsDir() {
+			entries, err := filesystem.Entries(path, walk)
+			if err != nil {
+				return "", fmt.Errorf("cannot include @%s: %w", path, err)
+			}
+			dirFiles, dirSkipped, err := filesystem.ReadTextFiles(ctx, entries, 0, classify)
+			if err != nil {
+				return "", fmt.Errorf("cannot include @%s: %w", path, err)
+			}
+			files = append(files, dirFiles...)
+			skipped = append(skipped, dirSkipped...)
+			continue
+		}
+
+		// A file named explicitly is included even if it is generated.
+		classification, err := filesystem.Classify(filesystem.Entry{Path: path, Size: info.Size(), ModTime: info.ModTime()}, classify)
+		if err != nil {
+			return "", fmt.Errorf("cannot include @%s: %w", path, err)
+		}
+		if classification.Class == filesystem.ClassBinary || classification.Class == filesystem.ClassTooLarge {
+			skipped = append(skipped, filesystem.SkippedFile{Path: path, Classification: classification})
+			continue
+		}
+		content, err := filesystem.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot include @%s: %w", path, err)
+		}
+		files = append(files, filesystem.FileContent{Path: path, Content: content})
+	}
+
+	if len(files) == 0 && len(skipped) == 0 {
+		return input, nil
+	}
+	return input + "\n\nContent from referenced files:\n" + api.FormatFilesForGemini(files) + api.FormatSkippedFiles(skipped), nil
+}
+
+// RunShellCommand runs command with the system shell, streaming its output to out.
+// The command and its output are not sent to the model. Cancelling ctx kills the
+// command together with the processes it started.
+func RunShellCommand(ctx context.Context, command string, out io.Writer) error {
+	cmd := process.Shell(ctx, command)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("shell command failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package command
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunShellCommandCancelKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	// The background child would keep the output pipe open if only the shell were killed.
+	if err := RunShellCommand(ctx, "sleep 30 & echo $! > "+pidFile+"; wait", io.Discard); err == nil {
+		t.Fatal("Expected an error for a cancelled command")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the command to be killed promptly, took %s", elapsed)
+	}
+
+	pid, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("Failed to read child pid: %v", err)
+	}
+	// Give init a moment to reap the killed child.
+	time.Sleep(100 * time.Millisecond)
+	status, err := os.ReadFile(filepath.Join("/proc", strings.TrimSpace(string(pid)), "status"))
+	if err == nil && !strings.Contains(string(status), "zombie") {
+		t.Errorf("Expected background child %s to be killed", strings.TrimSpace(string(pid)))
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/joho/godotenv" // For .env file loading
 	"github.com/mitchellh/go-homedir"
@@ -18,6 +20,33 @@ const (
 	DEFAULT_OTLP_ENDPOINT        = "http://localhost:4317" // From JS/TS reference
 )
 
+// modelGenerationDefaults are the generation parameters of models whose name starts with
+// the key. All matching entries apply, longer prefixes overriding shorter ones.
+var modelGenerationDefaults = map[string]GenerationSettings{
+	"gemini-":    {Temperature: float32Ptr(0), TopP: float32Ptr(1)},
+	"gemini-1.5": {MaxOutputTokens: int32Ptr(8192)},
+	"gemini-2.0": {MaxOutputTokens: int32Ptr(8192)},
+	"gemini-2.5": {MaxOutputTokens: int32Ptr(65536)},
+}
+
+// DefaultGenerationSettings returns the generation parameters used with modelName
+// unless settings or flags override them.
+func DefaultGenerationSettings(modelName string) GenerationSettings {
+	var prefixes []string
+	for prefix := range modelGenerationDefaults {
+		if strings.HasPrefix(modelName, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) < len(prefixes[j]) })
+
+	var defaults GenerationSettings
+	for _, prefix := range prefixes {
+		defaults = defaults.Merge(modelGenerationDefaults[prefix])
+	}
+	return defaults
+}
+
 // CliConfig holds the merged configuration from settings files and command-line arguments.
 type CliConfig struct {
 	// Fields from settings.json
@@ -37,6 +66,14 @@ type CliConfig struct {
 	TelemetryOtlpEndpoint        string
 	TelemetryLogPrompts          *bool
 	CheckpointingEnabled         bool
+	// SystemInstruction and GenerationConfig are the settings of the same name
+	// overridden by their flags.
+	SystemInstruction            string
+	GenerationConfig             GenerationSettings
+	// JSONSchema is the JSON Schema, inline or as a file path, that answers must match; "" for text answers.
+	JSONSchema                   string
+	// OutputFormat is how non-interactive runs print their result: text, json or stream-json.
+	OutputFormat                 string
 
 	// Other runtime configurations
 	SessionID string
@@ -78,6 +115,8 @@ func LoadCliConfig(workspaceDir string, sessionId string, cmd *cobra.Command) (*
 	telemetryOtlpEndpoint, _ := cmd.Flags().GetString("telemetry-otlp-endpoint")
 	telemetryLogPrompts, _ := cmd.Flags().GetBool("telemetry-log-prompts")
 	checkpointingEnabled, _ := cmd.Flags().GetBool("checkpointing")
+	jsonSchema, _ := cmd.Flags().GetString("json-schema")
+	outputFormat, _ := cmd.Flags().GetString("output-format")
 
 
 	// 4. Merge settings and command-line arguments
@@ -93,6 +132,8 @@ func LoadCliConfig(workspaceDir string, sessionId string, cmd *cobra.Command) (*
 		ShowMemoryUsage: showMemoryUsage,
 		Yolo: yolo,
 		CheckpointingEnabled: checkpointingEnabled,
+		JSONSchema: jsonSchema,
+		OutputFormat: outputFormat,
 
 		// Telemetry flags
 		TelemetryTarget: telemetryTarget,
@@ -136,6 +177,11 @@ func LoadCliConfig(workspaceDir string, sessionId string, cmd *cobra.Command) (*
 		cliConfig.TelemetryLogPrompts = &defaultLogPrompts
 	}
 
+	// Checkpointing is off unless the flag or the checkpointing.enabled setting turns it on.
+	if !cmd.Flags().Changed("checkpointing") && loadedSettings.Merged.Checkpointing != nil && loadedSettings.Merged.Checkpointing.Enabled != nil {
+		cliConfig.CheckpointingEnabled = *loadedSettings.Merged.Checkpointing.Enabled
+	}
+
 	// Handle sandbox flag: can be boolean or string
 	if cmd.Flags().Changed("sandbox") {
 		cliConfig.Sandbox = sandboxFlag
@@ -144,6 +190,13 @@ func LoadCliConfig(workspaceDir string, sessionId string, cmd *cobra.Command) (*
 	}
 
 
+	// Generation parameters: flags override settings, which override the model's defaults
+	// (applied by the API client, since the model can change during a session).
+	cliConfig.SystemInstruction, cliConfig.GenerationConfig = generationFromFlags(cmd, loadedSettings.Merged)
+	if err := cliConfig.GenerationConfig.Validate(); err != nil {
+		return nil, []errors.SettingError{{Message: err.Error(), Path: "generationConfig"}}
+	}
+
 	// If model is not set by flag or settings, use default
 	if cliConfig.Model == "" {
 		cliConfig.Model = DEFAULT_GEMINI_MODEL
@@ -152,6 +205,61 @@ func LoadCliConfig(workspaceDir string, sessionId string, cmd *cobra.Command) (*
 	return cliConfig, nil
 }
 
+// generationFromFlags returns the system instruction and generation parameters of settings,
+// overridden by the flags set on cmd.
+func generationFromFlags(cmd *cobra.Command, settings Settings) (string, GenerationSettings) {
+	systemInstruction := ""
+	if settings.SystemInstruction != nil {
+		systemInstruction = *settings.SystemInstruction
+	}
+	var generation GenerationSettings
+	if settings.GenerationConfig != nil {
+		generation = *settings.GenerationConfig
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("system-instruction") {
+		systemInstruction, _ = flags.GetString("system-instruction")
+	}
+	if flags.Changed("temperature") {
+		temperature, _ := flags.GetFloat32("temperature")
+		generation.Temperature = &temperature
+	}
+	if flags.Changed("top-p") {
+		topP, _ := flags.GetFloat32("top-p")
+		generation.TopP = &topP
+	}
+	if flags.Changed("max-output-tokens") {
+		maxOutputTokens, _ := flags.GetInt32("max-output-tokens")
+		generation.MaxOutputTokens = &maxOutputTokens
+	}
+	if flags.Changed("stop-sequences") {
+		generation.StopSequences, _ = flags.GetStringSlice("stop-sequences")
+	}
+	if flags.Changed("candidate-count") {
+		candidateCount, _ := flags.GetInt32("candidate-count")
+		generation.CandidateCount = &candidateCount
+	}
+	return systemInstruction, generation
+}
+
+func float32Ptr(f float32) *float32 {
+	return &f
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// MaxToolWorkers returns the configured number of read-only tools that may run concurrently.
+// It returns 0 when the setting is absent, letting the tool executor pick its default.
+func (c *CliConfig) MaxToolWorkers() int {
+	if c.MaxConcurrentTools == nil {
+		return 0
+	}
+	return *c.MaxConcurrentTools
+}
+
 // loadEnvironment loads environment variables from .env files.
 // It searches for .env files hierarchically, similar to the JS/TS reference.
 func loadEnvironment(startDir string) {
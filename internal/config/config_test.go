@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestDefaultGenerationSettings(t *testing.T) {
+	pro := DefaultGenerationSettings("gemini-2.5-pro")
+	if pro.Temperature == nil || *pro.Temperature != 0 || pro.TopP == nil || *pro.TopP != 1 {
+		t.Errorf("Expected the defaults of all Gemini models, got %+v", pro)
+	}
+	if pro.MaxOutputTokens == nil || *pro.MaxOutputTokens != 65536 {
+		t.Errorf("Expected the output limit of Gemini 2.5, got %+v", pro.MaxOutputTokens)
+	}
+	if flash := DefaultGenerationSettings("gemini-1.5-flash"); flash.MaxOutputTokens == nil || *flash.MaxOutputTokens != 8192 {
+		t.Errorf("Expected the output limit of Gemini 1.5, got %+v", flash.MaxOutputTokens)
+	}
+	if other := DefaultGenerationSettings("custom-model"); other.Temperature != nil || other.MaxOutputTokens != nil {
+		t.Errorf("Expected no defaults for an unknown model, got %+v", other)
+	}
+}
+
+func newGenerationCommand(args ...string) *cobra.Command {
+	cmd := &cobra.Command{Use: "test", Run: func(*cobra.Command, []string) {}}
+	cmd.Flags().String("system-instruction", "", "")
+	cmd.Flags().Float32("temperature", 0, "")
+	cmd.Flags().Float32("top-p", 0, "")
+	cmd.Flags().Int32("max-output-tokens", 0, "")
+	cmd.Flags().StringSlice("stop-sequences", nil, "")
+	cmd.Flags().Int32("candidate-count", 0, "")
+	cmd.Flags().Parse(args)
+	return cmd
+}
+
+func TestGenerationFromFlags(t *testing.T) {
+	settings := Settings{
+		SystemInstruction: stringPtr("Be brief."),
+		GenerationConfig:  &GenerationSettings{Temperature: float32Ptr(0.7), TopP: float32Ptr(0.9)},
+	}
+
+	// Without flags, the settings are used as they are.
+	instruction, generation := generationFromFlags(newGenerationCommand(), settings)
+	if instruction != "Be brief." || *generation.Temperature != 0.7 || *generation.TopP != 0.9 || generation.MaxOutputTokens != nil {
+		t.Errorf("Expected the settings, got %q, %+v", instruction, generation)
+	}
+
+	// Flags override the settings, including with zero values.
+	cmd := newGenerationCommand("--system-instruction", "Be thorough.", "--temperature", "0",
+		"--max-output-tokens", "256", "--stop-sequences", "END,STOP", "--candidate-count", "2")
+	instruction, generation = generationFromFlags(cmd, settings)
+	if instruction != "Be thorough." {
+		t.Errorf("Expected the flag's system instruction, got %q", instruction)
+	}
+	if *generation.Temperature != 0 || *generation.TopP != 0.9 || *generation.MaxOutputTokens != 256 || *generation.CandidateCount != 2 {
+		t.Errorf("Unexpected generation settings: %+v", generation)
+	}
+	if strings.Join(generation.StopSequences, ",") != "END,STOP" {
+		t.Errorf("Expected stop sequences from the flag, got %v", generation.StopSequences)
+	}
+	if *settings.GenerationConfig.Temperature != 0.7 {
+		t.Error("Expected the settings not to be modified")
+	}
+}
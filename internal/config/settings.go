@@ -52,30 +52,194 @@ type BugCommandSettings struct {
 type FileFilteringSettings struct {
 	RespectGitIgnore      *bool `json:"respectGitIgnore,omitempty"`
 	EnableRecursiveFileSearch *bool `json:"enableRecursiveFileSearch,omitempty"`
+	// MaxFileSize is the largest file, in bytes, included as context. Larger files are skipped.
+	MaxFileSize *int64 `json:"maxFileSize,omitempty"`
+}
+
+// McpServerConfig configures an MCP server whose tools are offered to the model.
+// Exactly one of HTTPURL, URL and Command says how to connect to it.
+type McpServerConfig struct {
+	// Command starts a server that speaks MCP over its stdin and stdout.
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"` // Added to the environment of the CLI
+	Cwd     string            `json:"cwd,omitempty"`
+	// URL is the event stream of a server using the HTTP with SSE transport.
+	URL string `json:"url,omitempty"`
+	// HTTPURL is the endpoint of a server using the streamable HTTP transport.
+	HTTPURL string `json:"httpUrl,omitempty"`
+	// Headers are sent with every request to URL or HTTPURL, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout bounds each request to the server, in milliseconds. Zero selects the client's default.
+	Timeout int `json:"timeout,omitempty"`
+	// StartupTimeout bounds connecting to the server and listing its tools, in milliseconds.
+	// Zero selects the client's default.
+	StartupTimeout int `json:"startupTimeout,omitempty"`
+	// Trust lets the server's tools run without confirmation.
+	Trust bool `json:"trust,omitempty"`
+}
+
+// GenerationSettings are the parameters the model generates with. Unset fields fall back
+// to the defaults of the model, see DefaultGenerationSettings.
+type GenerationSettings struct {
+	Temperature     *float32 `json:"temperature,omitempty"`
+	TopP            *float32 `json:"topP,omitempty"`
+	MaxOutputTokens *int32   `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	CandidateCount  *int32   `json:"candidateCount,omitempty"`
+}
+
+// Merge returns g with the fields set in override replacing its own.
+func (g GenerationSettings) Merge(override GenerationSettings) GenerationSettings {
+	if override.Temperature != nil {
+		g.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		g.TopP = override.TopP
+	}
+	if override.MaxOutputTokens != nil {
+		g.MaxOutputTokens = override.MaxOutputTokens
+	}
+	if override.StopSequences != nil {
+		g.StopSequences = override.StopSequences
+	}
+	if override.CandidateCount != nil {
+		g.CandidateCount = override.CandidateCount
+	}
+	return g
+}
+
+// Validate checks that the set fields are within the ranges the API accepts.
+func (g GenerationSettings) Validate() error {
+	if g.Temperature != nil && (*g.Temperature < 0 || *g.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2, got %g", *g.Temperature)
+	}
+	if g.TopP != nil && (*g.TopP < 0 || *g.TopP > 1) {
+		return fmt.Errorf("topP must be between 0 and 1, got %g", *g.TopP)
+	}
+	if g.MaxOutputTokens != nil && *g.MaxOutputTokens < 1 {
+		return fmt.Errorf("maxOutputTokens must be positive, got %d", *g.MaxOutputTokens)
+	}
+	if len(g.StopSequences) > 5 {
+		return fmt.Errorf("at most 5 stopSequences are allowed, got %d", len(g.StopSequences))
+	}
+	if g.CandidateCount != nil && (*g.CandidateCount < 1 || *g.CandidateCount > 8) {
+		return fmt.Errorf("candidateCount must be between 1 and 8, got %d", *g.CandidateCount)
+	}
+	return nil
+}
+
+// ModelPrice is what a model costs, in US dollars per million tokens.
+type ModelPrice struct {
+	InputPerMillion float64 `json:"inputPerMillion"`
+	// CachedInputPerMillion is the price of prompt tokens served from the cache.
+	// Zero bills them at InputPerMillion.
+	CachedInputPerMillion float64 `json:"cachedInputPerMillion,omitempty"`
+	OutputPerMillion      float64 `json:"outputPerMillion"`
+}
+
+// DefaultModelPricing are the list prices of the Gemini API for prompts up to 200k tokens,
+// keyed by model name prefix. The modelPricing setting adds to and overrides them.
+var DefaultModelPricing = map[string]ModelPrice{
+	"gemini-2.5-pro":        {InputPerMillion: 1.25, CachedInputPerMillion: 0.31, OutputPerMillion: 10},
+	"gemini-2.5-flash":      {InputPerMillion: 0.30, CachedInputPerMillion: 0.075, OutputPerMillion: 2.50},
+	"gemini-2.5-flash-lite": {InputPerMillion: 0.10, CachedInputPerMillion: 0.025, OutputPerMillion: 0.40},
+	"gemini-2.0-flash":      {InputPerMillion: 0.10, CachedInputPerMillion: 0.025, OutputPerMillion: 0.40},
+	"gemini-2.0-flash-lite": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-pro":        {InputPerMillion: 1.25, CachedInputPerMillion: 0.3125, OutputPerMillion: 5},
+	"gemini-1.5-flash":      {InputPerMillion: 0.075, CachedInputPerMillion: 0.01875, OutputPerMillion: 0.30},
+}
+
+// PriceTable maps model name prefixes to prices.
+type PriceTable map[string]ModelPrice
+
+// Price returns the price of the model with the longest matching prefix, so that
+// "gemini-2.5-flash-lite" is not priced as "gemini-2.5-flash".
+func (t PriceTable) Price(modelName string) (ModelPrice, bool) {
+	best := ""
+	found := false
+	for prefix := range t {
+		if strings.HasPrefix(modelName, prefix) && (!found || len(prefix) > len(best)) {
+			best, found = prefix, true
+		}
+	}
+	return t[best], found
 }
 
 // Settings defines the structure of the settings.json file.
 type Settings struct {
-	Theme                        *string                `json:"theme,omitempty"`
-	SelectedAuthType             *string                `json:"selectedAuthType,omitempty"` // Corresponds to AuthType in JS/TS
-	Sandbox                      interface{}            `json:"sandbox,omitempty"`         // boolean or string
-	CoreTools                    []string               `json:"coreTools,omitempty"`
-	ExcludeTools                 []string               `json:"excludeTools,omitempty"`
-	ToolDiscoveryCommand         *string                `json:"toolDiscoveryCommand,omitempty"`
-	ToolCallCommand              *string                `json:"toolCallCommand,omitempty"`
-	McpServerCommand             *string                `json:"mcpServerCommand,omitempty"`
-	McpServers                   map[string]interface{} `json:"mcpServers,omitempty"` // Corresponds to Record<string, MCPServerConfig>
-	ShowMemoryUsage              *bool                  `json:"showMemoryUsage,omitempty"`
-	ContextFileName              interface{}            `json:"contextFileName,omitempty"` // string or []string
-	Accessibility                *AccessibilitySettings `json:"accessibility,omitempty"`
-	Telemetry                    *TelemetrySettings     `json:"telemetry,omitempty"`
-	UsageStatisticsEnabled       *bool                  `json:"usageStatisticsEnabled,omitempty"`
-	PreferredEditor              *string                `json:"preferredEditor,omitempty"`
-	BugCommand                   *BugCommandSettings    `json:"bugCommand,omitempty"`
-	Checkpointing                *CheckpointingSettings `json:"checkpointing,omitempty"`
-	AutoConfigureMaxOldSpaceSize *bool                  `json:"autoConfigureMaxOldSpaceSize,omitempty"`
-	FileFiltering                *FileFilteringSettings `json:"fileFiltering,omitempty"`
-	HideWindowTitle              *bool                  `json:"hideWindowTitle,omitempty"`
+	Theme                        *string                    `json:"theme,omitempty"`
+	SelectedAuthType             *string                    `json:"selectedAuthType,omitempty"` // Corresponds to AuthType in JS/TS
+	Sandbox                      interface{}                `json:"sandbox,omitempty"`          // boolean or string
+	CoreTools                    []string                   `json:"coreTools,omitempty"`
+	ExcludeTools                 []string                   `json:"excludeTools,omitempty"`
+	ToolDiscoveryCommand         *string                    `json:"toolDiscoveryCommand,omitempty"`
+	ToolCallCommand              *string                    `json:"toolCallCommand,omitempty"`
+	McpServerCommand             *string                    `json:"mcpServerCommand,omitempty"`
+	McpServers                   map[string]McpServerConfig `json:"mcpServers,omitempty"` // Keyed by server name
+	ShowMemoryUsage              *bool                      `json:"showMemoryUsage,omitempty"`
+	ContextFileName              interface{}                `json:"contextFileName,omitempty"` // string or []string
+	Accessibility                *AccessibilitySettings     `json:"accessibility,omitempty"`
+	Telemetry                    *TelemetrySettings         `json:"telemetry,omitempty"`
+	UsageStatisticsEnabled       *bool                      `json:"usageStatisticsEnabled,omitempty"`
+	PreferredEditor              *string                    `json:"preferredEditor,omitempty"`
+	BugCommand                   *BugCommandSettings        `json:"bugCommand,omitempty"`
+	Checkpointing                *CheckpointingSettings     `json:"checkpointing,omitempty"`
+	AutoConfigureMaxOldSpaceSize *bool                      `json:"autoConfigureMaxOldSpaceSize,omitempty"`
+	FileFiltering                *FileFilteringSettings     `json:"fileFiltering,omitempty"`
+	HideWindowTitle              *bool                      `json:"hideWindowTitle,omitempty"`
+	MaxConcurrentTools           *int                       `json:"maxConcurrentTools,omitempty"` // Limit for read-only tools run in parallel
+	SystemInstruction            *string                    `json:"systemInstruction,omitempty"`  // Sent before the loaded memory
+	GenerationConfig             *GenerationSettings        `json:"generationConfig,omitempty"`
+	ModelPricing                 map[string]ModelPrice      `json:"modelPricing,omitempty"` // Prices by model name prefix, see DefaultModelPricing
+}
+
+// RespectsGitIgnore reports whether directory walks skip the files matched by .gitignore files.
+// It is true unless fileFiltering.respectGitIgnore is set to false.
+func (s Settings) RespectsGitIgnore() bool {
+	return s.FileFiltering == nil || s.FileFiltering.RespectGitIgnore == nil || *s.FileFiltering.RespectGitIgnore
+}
+
+// MaxFileSize returns the fileFiltering.maxFileSize setting, or 0 if it is not set.
+func (s Settings) MaxFileSize() int64 {
+	if s.FileFiltering == nil || s.FileFiltering.MaxFileSize == nil {
+		return 0
+	}
+	return *s.FileFiltering.MaxFileSize
+}
+
+// PriceTable returns DefaultModelPricing with the entries of the modelPricing setting added or replaced.
+func (s Settings) PriceTable() PriceTable {
+	table := make(PriceTable, len(DefaultModelPricing)+len(s.ModelPricing))
+	for prefix, price := range DefaultModelPricing {
+		table[prefix] = price
+	}
+	for prefix, price := range s.ModelPricing {
+		table[prefix] = price
+	}
+	return table
+}
+
+// ContextFileNames returns the names of instruction files from the contextFileName setting,
+// which is a string or a list of strings, or nil if it is not set.
+func (s Settings) ContextFileNames() []string {
+	switch v := s.ContextFileName.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []string:
+		return v
+	case []interface{}:
+		var names []string
+		for _, item := range v {
+			if name, ok := item.(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
 }
 
 // SettingsFile represents a loaded settings file with its path.
@@ -160,6 +324,24 @@ func computeMergedSettings(user, workspace Settings) Settings {
 	if workspace.HideWindowTitle != nil {
 		merged.HideWindowTitle = workspace.HideWindowTitle
 	}
+	if workspace.MaxConcurrentTools != nil {
+		merged.MaxConcurrentTools = workspace.MaxConcurrentTools
+	}
+	if workspace.ModelPricing != nil {
+		merged.ModelPricing = workspace.ModelPricing
+	}
+	if workspace.SystemInstruction != nil {
+		merged.SystemInstruction = workspace.SystemInstruction
+	}
+	if workspace.GenerationConfig != nil {
+		// Merged field by field, so that a workspace can change the temperature alone.
+		generation := workspace.GenerationConfig
+		if user.GenerationConfig != nil {
+			combined := user.GenerationConfig.Merge(*workspace.GenerationConfig)
+			generation = &combined
+		}
+		merged.GenerationConfig = generation
+	}
 
 	return merged
 }
@@ -225,10 +407,28 @@ func resolveSettingsEnvVars(s *Settings) {
 		// resolveStringPtrEnvVars(&s.FileFiltering.SomeStringField)
 	}
 
-	// For map[string]interface{} like McpServers, we would need to iterate and resolve strings
-	// This is more complex and might require reflection or specific type assertions if values are nested strings. 
-	// For now, assuming direct string values in McpServers are not expected to contain env vars,
-	// or that they are handled by the core logic that consumes them.
+	for name, server := range s.McpServers {
+		server.Command = os.ExpandEnv(server.Command)
+		server.Cwd = os.ExpandEnv(server.Cwd)
+		args := make([]string, len(server.Args))
+		for i, arg := range server.Args {
+			args[i] = os.ExpandEnv(arg)
+		}
+		server.Args = args
+		env := make(map[string]string, len(server.Env))
+		for key, value := range server.Env {
+			env[key] = os.ExpandEnv(value)
+		}
+		server.Env = env
+		server.URL = os.ExpandEnv(server.URL)
+		server.HTTPURL = os.ExpandEnv(server.HTTPURL)
+		headers := make(map[string]string, len(server.Headers))
+		for key, value := range server.Headers {
+			headers[key] = os.ExpandEnv(value)
+		}
+		server.Headers = headers
+		s.McpServers[name] = server
+	}
 }
 
 // LoadSettings loads settings from user and workspace directories.
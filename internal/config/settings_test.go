@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -204,6 +205,146 @@ func TestResolveSettingsEnvVars(t *testing.T) {
 	}
 }
 
+func TestParseMcpServers(t *testing.T) {
+	os.Setenv("TEST_MCP_TOKEN", "secret")
+	defer os.Unsetenv("TEST_MCP_TOKEN")
+
+	var settings Settings
+	err := json.Unmarshal([]byte(`{
+		"mcpServers": {
+			"github": {
+				"command": "github-mcp",
+				"args": ["--token", "$TEST_MCP_TOKEN"],
+				"env": {"GITHUB_TOKEN": "${TEST_MCP_TOKEN}"},
+				"cwd": "/tmp",
+				"timeout": 30000,
+				"trust": true
+			},
+			"remote": {
+				"httpUrl": "https://tools.example.com/mcp",
+				"headers": {"Authorization": "Bearer $TEST_MCP_TOKEN"}
+			}
+		}
+	}`), &settings)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	resolveSettingsEnvVars(&settings)
+
+	server := settings.McpServers["github"]
+	if server.Command != "github-mcp" || server.Cwd != "/tmp" || server.Timeout != 30000 || !server.Trust {
+		t.Errorf("Unexpected server config: %+v", server)
+	}
+	if strings.Join(server.Args, " ") != "--token secret" || server.Env["GITHUB_TOKEN"] != "secret" {
+		t.Errorf("Expected environment variables to be resolved, got %+v", server)
+	}
+	remote := settings.McpServers["remote"]
+	if remote.HTTPURL != "https://tools.example.com/mcp" || remote.Headers["Authorization"] != "Bearer secret" {
+		t.Errorf("Unexpected remote server config: %+v", remote)
+	}
+}
+
+func TestRespectsGitIgnore(t *testing.T) {
+	if !(Settings{}).RespectsGitIgnore() {
+		t.Error("Expected .gitignore to be respected by default")
+	}
+	if !(Settings{FileFiltering: &FileFilteringSettings{}}).RespectsGitIgnore() {
+		t.Error("Expected .gitignore to be respected when respectGitIgnore is unset")
+	}
+	if (Settings{FileFiltering: &FileFilteringSettings{RespectGitIgnore: boolPtr(false)}}).RespectsGitIgnore() {
+		t.Error("Expected respectGitIgnore: false to disable .gitignore")
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	if got := (Settings{}).MaxFileSize(); got != 0 {
+		t.Errorf("Expected 0 when maxFileSize is unset, got %d", got)
+	}
+	limit := int64(2048)
+	if got := (Settings{FileFiltering: &FileFilteringSettings{MaxFileSize: &limit}}).MaxFileSize(); got != 2048 {
+		t.Errorf("Expected 2048, got %d", got)
+	}
+}
+
+func TestContextFileNames(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{"", ""},
+		{"AGENTS.md", "AGENTS.md"},
+		{[]string{"AGENTS.md", "GEMINI.md"}, "AGENTS.md,GEMINI.md"},
+		// Lists decoded from JSON hold interface{} values.
+		{[]interface{}{"AGENTS.md", 1, "GEMINI.md"}, "AGENTS.md,GEMINI.md"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join((Settings{ContextFileName: tt.value}).ContextFileNames(), ","); got != tt.want {
+			t.Errorf("ContextFileNames() for %#v = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMergeGenerationSettings(t *testing.T) {
+	user := Settings{GenerationConfig: &GenerationSettings{Temperature: float32Ptr(0.5), MaxOutputTokens: int32Ptr(100)}}
+	workspace := Settings{GenerationConfig: &GenerationSettings{Temperature: float32Ptr(1)}}
+
+	merged := computeMergedSettings(user, workspace).GenerationConfig
+	if merged == nil || *merged.Temperature != 1 || merged.MaxOutputTokens == nil || *merged.MaxOutputTokens != 100 {
+		t.Errorf("Expected the workspace temperature and the user maxOutputTokens, got %+v", merged)
+	}
+	if *user.GenerationConfig.Temperature != 0.5 {
+		t.Error("Expected the user settings not to be modified")
+	}
+}
+
+func TestGenerationSettingsValidate(t *testing.T) {
+	valid := GenerationSettings{Temperature: float32Ptr(2), TopP: float32Ptr(0), MaxOutputTokens: int32Ptr(1), CandidateCount: int32Ptr(8)}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected %+v to be valid, got %v", valid, err)
+	}
+	invalid := []GenerationSettings{
+		{Temperature: float32Ptr(-0.1)},
+		{TopP: float32Ptr(1.5)},
+		{MaxOutputTokens: int32Ptr(0)},
+		{StopSequences: []string{"a", "b", "c", "d", "e", "f"}},
+		{CandidateCount: int32Ptr(9)},
+	}
+	for _, settings := range invalid {
+		if err := settings.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", settings)
+		}
+	}
+}
+
+func TestPriceTable(t *testing.T) {
+	table := Settings{ModelPricing: map[string]ModelPrice{
+		"gemini-2.5-pro": {InputPerMillion: 2, OutputPerMillion: 20},
+		"my-model":       {InputPerMillion: 1, OutputPerMillion: 1},
+	}}.PriceTable()
+
+	tests := []struct {
+		model      string
+		wantInput  float64
+		wantPriced bool
+	}{
+		{"gemini-2.5-pro", 2, true},                   // overridden by the setting
+		{"gemini-2.5-flash-lite-preview", 0.10, true}, // longest prefix
+		{"gemini-2.5-flash-001", 0.30, true},
+		{"my-model-v2", 1, true},
+		{"unknown", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := table.Price(tt.model)
+		if ok != tt.wantPriced || price.InputPerMillion != tt.wantInput {
+			t.Errorf("Price(%q) = %+v, %v; want input %g, %v", tt.model, price, ok, tt.wantInput, tt.wantPriced)
+		}
+	}
+	if DefaultModelPricing["gemini-2.5-pro"].InputPerMillion != 1.25 {
+		t.Error("Expected the setting not to modify the defaults")
+	}
+}
+
 // Helper functions for creating pointers
 func stringPtr(s string) *string {
 	return &s
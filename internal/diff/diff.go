@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	// oldIndex and newIndex are the positions of the line in the old and new text.
+	oldIndex, newIndex int
+	line               string
+}
+
+// Unified returns a unified diff turning oldText into newText, with oldName and
+// newName in the file headers. It returns "" when the texts are equal.
+func Unified(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+	ops := lineOps(oldLines, newLines)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range hunks(ops) {
+		writeHunk(&builder, hunk)
+	}
+	return builder.String()
+}
+
+// splitLines splits text into lines that keep their trailing newline,
+// so a missing newline at the end of the file shows up as a change.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxEditDistance bounds the search for a shortest edit script. Texts that differ
+// by more lines than this are shown as a deletion followed by an insertion.
+const maxEditDistance = 1000
+
+// lineOps computes the edit script turning a into b.
+func lineOps(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []op
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, op{kind: opEqual, oldIndex: i, newIndex: i, line: a[i]})
+	}
+	for _, o := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		o.oldIndex += prefix
+		o.newIndex += prefix
+		ops = append(ops, o)
+	}
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, op{kind: opEqual, oldIndex: len(a) - i, newIndex: len(b) - i, line: a[len(a)-i]})
+	}
+	return ops
+}
+
+// myers computes a shortest edit script with Myers' algorithm.
+func myers(a, b []string) []op {
+	n, m := len(a), len(b)
+	maxD := min(n+m, maxEditDistance)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	// trace[d] holds the furthest x reached on diagonals -d..d before step d.
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+// backtrack walks the saved search states from the end to recover the edit script.
+func backtrack(a, b []string, trace [][]int, depth int) []op {
+	x, y := len(a), len(b)
+	var ops []op
+	for d := depth; d > 0; d-- {
+		furthest := func(k int) int { return trace[d][k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && furthest(k-1) < furthest(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := furthest(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: opEqual, oldIndex: x, newIndex: y, line: a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, op{kind: opInsert, oldIndex: x, newIndex: y, line: b[y]})
+		} else {
+			x--
+			ops = append(ops, op{kind: opDelete, oldIndex: x, newIndex: y, line: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, op{kind: opEqual, oldIndex: x, newIndex: y, line: a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// replaceAll deletes every line of a and inserts every line of b.
+func replaceAll(a, b []string) []op {
+	ops := make([]op, 0, len(a)+len(b))
+	for i, line := range a {
+		ops = append(ops, op{kind: opDelete, oldIndex: i, newIndex: 0, line: line})
+	}
+	for j, line := range b {
+		ops = append(ops, op{kind: opInsert, oldIndex: len(a), newIndex: j, line: line})
+	}
+	return ops
+}
+
+// hunks groups changes that are close together, keeping contextLines of context around them.
+func hunks(ops []op) [][]op {
+	var result [][]op
+	start := -1
+	lastChange := -1
+	for i, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+		if start >= 0 && i-lastChange > 2*contextLines {
+			result = append(result, ops[start:min(lastChange+contextLines+1, len(ops))])
+			start = -1
+		}
+		if start < 0 {
+			start = max(i-contextLines, 0)
+		}
+		lastChange = i
+	}
+	if start >= 0 {
+		result = append(result, ops[start:min(lastChange+contextLines+1, len(ops))])
+	}
+	return result
+}
+
+func writeHunk(builder *strings.Builder, hunk []op) {
+	oldStart, newStart := hunk[0].oldIndex+1, hunk[0].newIndex+1
+	oldCount, newCount := 0, 0
+	for _, o := range hunk {
+		if o.kind != opInsert {
+			oldCount++
+		}
+		if o.kind != opDelete {
+			newCount++
+		}
+	}
+	// An empty range is addressed by the line before it.
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+	fmt.Fprintf(builder, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	for _, o := range hunk {
+		prefix := " "
+		switch o.kind {
+		case opDelete:
+			prefix = "-"
+		case opInsert:
+			prefix = "+"
+		}
+		builder.WriteString(prefix)
+		builder.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			builder.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
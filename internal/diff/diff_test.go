@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedEqual(t *testing.T) {
+	if got := Unified("a", "b", "same\n", "same\n"); got != "" {
+		t.Errorf("Expected empty diff for equal texts, got %q", got)
+	}
+}
+
+func TestUnifiedModify(t *testing.T) {
+	oldText := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	newText := "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\nnine\nten\n"
+	want := `--- a.txt
++++ a.txt
+@@ -2,7 +2,7 @@
+ two
+ three
+ four
+-five
++FIVE
+ six
+ seven
+ eight
+`
+	if got := Unified("a.txt", "a.txt", oldText, newText); got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	var oldLines []string
+	for i := 0; i < 20; i++ {
+		oldLines = append(oldLines, fmt.Sprintf("line %d", i))
+	}
+	newLines := append([]string(nil), oldLines...)
+	newLines[1] = "changed"
+	newLines[18] = "changed"
+
+	got := Unified("f", "f", strings.Join(oldLines, "\n")+"\n", strings.Join(newLines, "\n")+"\n")
+	if n := strings.Count(got, "@@ -"); n != 2 {
+		t.Errorf("Expected 2 hunks, got %d:\n%s", n, got)
+	}
+	if !strings.Contains(got, "@@ -1,5 +1,5 @@") || !strings.Contains(got, "@@ -16,5 +16,5 @@") {
+		t.Errorf("Unexpected hunk headers:\n%s", got)
+	}
+}
+
+func TestUnifiedNewFile(t *testing.T) {
+	want := "--- /dev/null\n+++ new.txt\n@@ -0,0 +1,2 @@\n+hello\n+world\n"
+	if got := Unified("/dev/null", "new.txt", "", "hello\nworld\n"); got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedMissingNewline(t *testing.T) {
+	got := Unified("f", "f", "a\nb\n", "a\nb")
+	want := "--- f\n+++ f\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n"
+	if got != want {
+		t.Errorf("Unexpected diff:\n%q\nwant:\n%q", got, want)
+	}
+}
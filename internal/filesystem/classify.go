@@ -0,0 +1,189 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxFileSize is the largest file, in bytes, that is treated as text when ClassifyOptions.MaxFileSize is not set.
+const DefaultMaxFileSize = 1024 * 1024
+
+// FileClass is the kind of a file, as far as including it in a prompt is concerned.
+type FileClass int
+
+const (
+	// ClassText is a text file that can be included in a prompt.
+	ClassText FileClass = iota
+	// ClassBinary is a file with binary content, such as an image or a compiled program.
+	ClassBinary
+	// ClassTooLarge is a file larger than the configured size limit. Its content is not read.
+	ClassTooLarge
+	// ClassGenerated is a text file generated by a tool, such as a lockfile or minified code.
+	ClassGenerated
+)
+
+// String returns the name of the class.
+func (c FileClass) String() string {
+	switch c {
+	case ClassText:
+		return "text"
+	case ClassBinary:
+		return "binary"
+	case ClassTooLarge:
+		return "too large"
+	case ClassGenerated:
+		return "generated"
+	default:
+		return fmt.Sprintf("FileClass(%d)", int(c))
+	}
+}
+
+// Classification is the result of classifying a file.
+type Classification struct {
+	Class FileClass
+	Size  int64
+	// Detail says why the file got its class: the detected content type of a binary file,
+	// the size limit a file exceeds, or how a generated file was recognized.
+	Detail string
+}
+
+// String describes the classification, e.g. "binary (image/png, 5120 bytes)".
+func (c Classification) String() string {
+	if c.Detail == "" {
+		return fmt.Sprintf("%s (%d bytes)", c.Class, c.Size)
+	}
+	return fmt.Sprintf("%s (%s, %d bytes)", c.Class, c.Detail, c.Size)
+}
+
+// ClassifyOptions configures classification.
+type ClassifyOptions struct {
+	// MaxFileSize is the largest file, in bytes, that is read. Zero selects DefaultMaxFileSize.
+	MaxFileSize int64
+}
+
+func (o ClassifyOptions) maxFileSize() int64 {
+	if o.MaxFileSize <= 0 {
+		return DefaultMaxFileSize
+	}
+	return o.MaxFileSize
+}
+
+// generatedFileNames are lockfiles and other files that are always generated.
+var generatedFileNames = map[string]bool{
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lockb":           true,
+	"go.sum":              true,
+	"Cargo.lock":          true,
+	"Gemfile.lock":        true,
+	"composer.lock":       true,
+	"poetry.lock":         true,
+	"Pipfile.lock":        true,
+}
+
+// generatedMarker matches the Go convention for generated files, and the "@generated" tag used elsewhere.
+var generatedMarker = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$|@generated\b`)
+
+// generatedMarkerLen is how much of the beginning of a file is searched for a generated marker.
+const generatedMarkerLen = 1024
+
+// ClassifyContent classifies the file called name from its size and its content,
+// of which only the beginning is needed.
+func ClassifyContent(name string, size int64, content []byte, opts ClassifyOptions) Classification {
+	if limit := opts.maxFileSize(); size > limit {
+		return Classification{Class: ClassTooLarge, Size: size, Detail: fmt.Sprintf("limit is %d bytes", limit)}
+	}
+	if LooksBinary(content) {
+		return Classification{Class: ClassBinary, Size: size, Detail: http.DetectContentType(content)}
+	}
+
+	base := filepath.Base(name)
+	if generatedFileNames[base] {
+		return Classification{Class: ClassGenerated, Size: size, Detail: "lockfile"}
+	}
+	if strings.HasSuffix(base, ".min.js") || strings.HasSuffix(base, ".min.css") {
+		return Classification{Class: ClassGenerated, Size: size, Detail: "minified"}
+	}
+	head := content[:min(len(content), generatedMarkerLen)]
+	if generatedMarker.Match(head) {
+		return Classification{Class: ClassGenerated, Size: size, Detail: "marked as generated"}
+	}
+	return Classification{Class: ClassText, Size: size}
+}
+
+// Classify classifies the file described by entry, reading no more of it than needed.
+func Classify(entry Entry, opts ClassifyOptions) (Classification, error) {
+	if entry.Size > opts.maxFileSize() {
+		return ClassifyContent(entry.Path, entry.Size, nil, opts), nil
+	}
+	file, err := os.Open(entry.Path)
+	if err != nil {
+		return Classification{}, fmt.Errorf("failed to read file %s: %w", entry.Path, err)
+	}
+	defer file.Close()
+
+	sample := make([]byte, max(binarySniffLen, generatedMarkerLen))
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Classification{}, fmt.Errorf("failed to read file %s: %w", entry.Path, err)
+	}
+	return ClassifyContent(entry.Path, entry.Size, sample[:n], opts), nil
+}
+
+// SkippedFile is a file left out because it is not a text file.
+type SkippedFile struct {
+	Path           string
+	Classification Classification
+}
+
+// ReadTextFiles reads the text files among entries like ReadFiles, and returns the other
+// files as skipped, in the order of entries. Files over the size limit are not read at all.
+func ReadTextFiles(ctx context.Context, entries []Entry, workers int, opts ClassifyOptions) ([]FileContent, []SkippedFile, error) {
+	type result struct {
+		file    FileContent
+		skipped *SkippedFile
+	}
+	results := make([]result, len(entries))
+	err := forEachConcurrently(ctx, len(entries), workers, func(i int) error {
+		entry := entries[i]
+		if entry.Size > opts.maxFileSize() {
+			classification := ClassifyContent(entry.Path, entry.Size, nil, opts)
+			results[i].skipped = &SkippedFile{Path: entry.Path, Classification: classification}
+			return nil
+		}
+		content, err := entry.ReadContent()
+		if err != nil {
+			return err
+		}
+		// The size may have changed since the walk.
+		classification := ClassifyContent(entry.Path, int64(len(content)), content, opts)
+		if classification.Class != ClassText {
+			results[i].skipped = &SkippedFile{Path: entry.Path, Classification: classification}
+			return nil
+		}
+		results[i].file = FileContent{Path: entry.Path, Content: content}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := make([]FileContent, 0, len(entries))
+	var skipped []SkippedFile
+	for _, r := range results {
+		if r.skipped != nil {
+			skipped = append(skipped, *r.skipped)
+			continue
+		}
+		files = append(files, r.file)
+	}
+	return files, skipped, nil
+}
@@ -0,0 +1,115 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestClassifyContent(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int64
+		content    []byte
+		opts       ClassifyOptions
+		wantClass  FileClass
+		wantDetail string
+	}{
+		{"main.go", 12, []byte("package main"), ClassifyOptions{}, ClassText, ""},
+		{"logo.png", 16, pngHeader, ClassifyOptions{}, ClassBinary, "image/png"},
+		{"big.txt", DefaultMaxFileSize + 1, nil, ClassifyOptions{}, ClassTooLarge, "limit is 1048576 bytes"},
+		{"notes.txt", 11, []byte("hello world"), ClassifyOptions{MaxFileSize: 10}, ClassTooLarge, "limit is 10 bytes"},
+		{"web/package-lock.json", 2, []byte("{}"), ClassifyOptions{}, ClassGenerated, "lockfile"},
+		{"app.min.js", 8, []byte("var a=1;"), ClassifyOptions{}, ClassGenerated, "minified"},
+		{"api.pb.go", 60, []byte("// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api"), ClassifyOptions{}, ClassGenerated, "marked as generated"},
+		{"schema.ts", 20, []byte("/** @generated */\nexport {}"), ClassifyOptions{}, ClassGenerated, "marked as generated"},
+		// The Go marker must be a whole line.
+		{"doc.go", 40, []byte("package doc\n// See: Code generated by x. DO NOT EDIT."), ClassifyOptions{}, ClassText, ""},
+	}
+	for _, tt := range tests {
+		got := ClassifyContent(tt.name, tt.size, tt.content, tt.opts)
+		if got.Class != tt.wantClass || got.Detail != tt.wantDetail || got.Size != tt.size {
+			t.Errorf("ClassifyContent(%q) = %+v, want class %v, detail %q", tt.name, got, tt.wantClass, tt.wantDetail)
+		}
+	}
+
+	if got := (Classification{Class: ClassBinary, Size: 16, Detail: "image/png"}).String(); got != "binary (image/png, 16 bytes)" {
+		t.Errorf("Unexpected String(): %q", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"main.go":  "package main",
+		"logo.png": string(pngHeader),
+		// A NUL byte past the sniffed prefix does not make a file binary.
+		"late.txt": strings.Repeat("a", binarySniffLen) + "\x00",
+	})
+	entries, err := Entries(root, WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]FileClass{"late.txt": ClassText, "logo.png": ClassBinary, "main.go": ClassText}
+	for _, entry := range entries {
+		got, err := Classify(entry, ClassifyOptions{})
+		if err != nil {
+			t.Fatalf("Classify(%s) failed: %v", entry.Path, err)
+		}
+		if name := filepath.Base(entry.Path); got.Class != want[name] {
+			t.Errorf("Classify(%s) = %v, want %v", name, got.Class, want[name])
+		}
+	}
+
+	// Files over the limit are classified from their size alone.
+	missing := Entry{Path: filepath.Join(root, "missing.bin"), Size: 100}
+	if got, err := Classify(missing, ClassifyOptions{MaxFileSize: 10}); err != nil || got.Class != ClassTooLarge {
+		t.Errorf("Expected a too large file not to be opened, got %+v, %v", got, err)
+	}
+	missing.Size = 1
+	if _, err := Classify(missing, ClassifyOptions{}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestReadTextFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.go":     "package a",
+		"b.png":    string(pngHeader),
+		"c.txt":    string(bytes.Repeat([]byte("c"), 100)),
+		"d/go.sum": "example.com/m v1.0.0 h1:abc=",
+		"d/e.md":   "# e",
+	})
+	entries, err := Entries(root, WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, skipped, err := ReadTextFiles(context.Background(), entries, 2, ClassifyOptions{MaxFileSize: 50})
+	if err != nil {
+		t.Fatalf("ReadTextFiles failed: %v", err)
+	}
+
+	var gotFiles []string
+	for _, file := range files {
+		gotFiles = append(gotFiles, mustRel(t, root, file.Path)+"="+string(file.Content))
+	}
+	if got := strings.Join(gotFiles, " "); got != "a.go=package a d/e.md=# e" {
+		t.Errorf("Unexpected text files: %s", got)
+	}
+
+	var gotSkipped []string
+	for _, file := range skipped {
+		gotSkipped = append(gotSkipped, mustRel(t, root, file.Path)+": "+file.Classification.String())
+	}
+	want := "b.png: binary (image/png, 16 bytes)|c.txt: too large (limit is 50 bytes, 100 bytes)|d/go.sum: generated (lockfile, 28 bytes)"
+	if got := strings.Join(gotSkipped, "|"); got != want {
+		t.Errorf("Unexpected skipped files:\n got %s\nwant %s", got, want)
+	}
+}
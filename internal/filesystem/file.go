@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 )
@@ -30,3 +31,15 @@ func WriteFile(filePath string, content []byte) error {
 
 	return nil
 }
+
+// binarySniffLen is how much of a file LooksBinary needs to see.
+const binarySniffLen = 8000
+
+// LooksBinary reports whether sample, the beginning of a file, looks like binary data.
+// Like git, it treats content with a NUL byte in the first 8000 bytes as binary.
+func LooksBinary(sample []byte) bool {
+	if len(sample) > binarySniffLen {
+		sample = sample[:binarySniffLen]
+	}
+	return bytes.IndexByte(sample, 0) >= 0
+}
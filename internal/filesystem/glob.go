@@ -0,0 +1,70 @@
+package filesystem
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Glob returns the files under root selected by opts whose slash-separated path relative
+// to root matches pattern. See MatchGlob for the pattern syntax. File contents are not read.
+func Glob(root, pattern string, opts WalkOptions) ([]Entry, error) {
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	err := Walk(root, opts, func(entry Entry) error {
+		rel, err := filepath.Rel(root, entry.Path)
+		if err != nil {
+			return err
+		}
+		if MatchGlob(pattern, filepath.ToSlash(rel)) {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// MatchGlob reports whether the slash-separated name matches pattern.
+// Each path segment is matched as in path.Match, and a "**" segment matches
+// any number of segments, including none, so "internal/**/*_test.go" matches
+// both "internal/a_test.go" and "internal/tool/b_test.go".
+// A pattern without "/" only matches names at the top level; use "**/*.go" to match at any depth.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			// Collapse repeated "**" and try every split point.
+			for len(patterns) > 0 && patterns[0] == "**" {
+				patterns = patterns[1:]
+			}
+			if len(patterns) == 0 {
+				return true
+			}
+			for i := range names {
+				if matchSegments(patterns, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(patterns[0], names[0]); !ok {
+			return false
+		}
+		patterns = patterns[1:]
+		names = names[1:]
+	}
+	return len(names) == 0
+}
@@ -0,0 +1,86 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/gemini/main.go", true},
+		{"internal/**/*_test.go", "internal/a_test.go", true},
+		{"internal/**/*_test.go", "internal/tool/b_test.go", true},
+		{"internal/**/*_test.go", "internal/tool/b.go", false},
+		{"internal/**/*_test.go", "cmd/a_test.go", false},
+		{"internal/**", "internal/x/y/z.txt", true},
+		{"**", "anything/at/all", true},
+		{"a/**/**/b", "a/b", true},
+		{"a/?/c", "a/b/c", true},
+		{"a/[xy]/c", "a/b/c", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"main.go":                 "package main",
+		"internal/a_test.go":      "package internal",
+		"internal/tool/b_test.go": "package tool",
+		"internal/tool/b.go":      "package tool",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(tmpDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := Glob(tmpDir, "internal/**/*_test.go", WalkOptions{})
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+		if entry.Size != int64(len(files[mustRel(t, tmpDir, entry.Path)])) {
+			t.Errorf("Unexpected size %d for %s", entry.Size, entry.Path)
+		}
+		if entry.ModTime.IsZero() {
+			t.Errorf("Expected a modification time for %s", entry.Path)
+		}
+	}
+	sort.Strings(paths)
+	want := []string{filepath.Join(tmpDir, "internal/a_test.go"), filepath.Join(tmpDir, "internal/tool/b_test.go")}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, paths)
+	}
+
+	if _, err := Glob(tmpDir, "[unterminated", WalkOptions{}); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+}
+
+func mustRel(t *testing.T, root, path string) string {
+	t.Helper()
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.ToSlash(rel)
+}
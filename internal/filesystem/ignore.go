@@ -0,0 +1,228 @@
+package filesystem
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// GitIgnoreFileName is the name of git's per-directory ignore files.
+	GitIgnoreFileName = ".gitignore"
+	// GeminiIgnoreFileName is the name of the ignore files read for this CLI only.
+	// They use the .gitignore syntax and take precedence over .gitignore in the same directory.
+	GeminiIgnoreFileName = ".geminiignore"
+)
+
+// DefaultIgnorePatterns are applied below every ignore file, so an ignore file can
+// re-include one of them with a negated pattern such as "!vendor/".
+var DefaultIgnorePatterns = []string{".git/", "node_modules/", "vendor/"}
+
+// IgnoreMatcher decides which paths are ignored, following the .gitignore rules:
+// patterns are read from the ignore files of every directory from the enclosing
+// repository root down, later and deeper patterns take precedence, "!" negates a
+// pattern, a trailing "/" only matches directories, and a pattern containing a "/"
+// other than a trailing one is anchored to the directory of its ignore file.
+// Ignore files are read lazily, the first time a path below their directory is matched.
+type IgnoreMatcher struct {
+	// top is the absolute path of the outermost directory whose ignore files are read:
+	// the root of the enclosing git repository, or the directory the matcher was created for.
+	top              string
+	respectGitIgnore bool
+	defaults         []ignoreRule
+	// rules caches the parsed ignore files, keyed by the slash path of their directory relative to top.
+	rules map[string][]ignoreRule
+}
+
+// NewIgnoreMatcher creates a matcher for paths under root. .geminiignore files and
+// DefaultIgnorePatterns always apply; .gitignore files only if respectGitIgnore is set.
+func NewIgnoreMatcher(root string, respectGitIgnore bool) (*IgnoreMatcher, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	m := &IgnoreMatcher{
+		top:              findRepositoryRoot(absRoot),
+		respectGitIgnore: respectGitIgnore,
+		rules:            make(map[string][]ignoreRule),
+	}
+	for _, pattern := range DefaultIgnorePatterns {
+		if rule, ok := parseIgnoreRule(pattern); ok {
+			m.defaults = append(m.defaults, rule)
+		}
+	}
+	return m, nil
+}
+
+// findRepositoryRoot returns the closest directory at or above dir that contains .git, or dir if there is none.
+func findRepositoryRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Lstat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// Ignored reports whether path, which is a directory if isDir is set, is ignored
+// either itself or because one of its parent directories is. Paths outside the
+// matcher's repository are never ignored.
+func (m *IgnoreMatcher) Ignored(filePath string, isDir bool) bool {
+	rel, ok := m.relative(filePath)
+	if !ok || rel == "" {
+		return false
+	}
+	segments := strings.Split(rel, "/")
+	for i := 1; i < len(segments); i++ {
+		if m.match(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.match(rel, isDir)
+}
+
+// relative returns the slash path of filePath relative to the matcher's top directory,
+// which is "" for the top directory itself. It fails for paths outside the top directory.
+func (m *IgnoreMatcher) relative(filePath string) (string, bool) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.top, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// match reports whether the ignore rules match rel itself, without looking at its parent directories.
+func (m *IgnoreMatcher) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.defaults {
+		if rule.matches(rel, isDir) {
+			ignored = !rule.negate
+		}
+	}
+
+	dir := ""
+	for {
+		sub := rel
+		if dir != "" {
+			sub = rel[len(dir)+1:]
+		}
+		for _, rule := range m.load(dir) {
+			if rule.matches(sub, isDir) {
+				ignored = !rule.negate
+			}
+		}
+		next := strings.IndexByte(sub, '/')
+		if next < 0 {
+			return ignored
+		}
+		if dir == "" {
+			dir = sub[:next]
+		} else {
+			dir = dir + "/" + sub[:next]
+		}
+	}
+}
+
+// load returns the rules of the ignore files in dir, reading them on first use.
+func (m *IgnoreMatcher) load(dir string) []ignoreRule {
+	if rules, ok := m.rules[dir]; ok {
+		return rules
+	}
+	var rules []ignoreRule
+	if m.respectGitIgnore {
+		rules = append(rules, readIgnoreFile(filepath.Join(m.top, filepath.FromSlash(dir), GitIgnoreFileName))...)
+	}
+	rules = append(rules, readIgnoreFile(filepath.Join(m.top, filepath.FromSlash(dir), GeminiIgnoreFileName))...)
+	m.rules[dir] = rules
+	return rules
+}
+
+// readIgnoreFile parses the ignore file at filePath. A missing or unreadable file has no rules.
+func readIgnoreFile(filePath string) []ignoreRule {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ignoreRule is one pattern of an ignore file.
+type ignoreRule struct {
+	// pattern is a MatchGlob pattern; unanchored patterns have a single segment matched against the base name.
+	pattern  string
+	anchored bool
+	dirOnly  bool
+	negate   bool
+}
+
+// parseIgnoreRule parses a line of an ignore file. Blank lines and comments have no rule.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped with a backslash.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	// gitignore negates character classes with "[!...]"; path.Match uses "[^...]".
+	rule.pattern = strings.ReplaceAll(line, "[!", "[^")
+	// "dir/**" matches everything inside dir, but not dir itself.
+	if strings.HasSuffix(rule.pattern, "/**") {
+		rule.pattern = strings.TrimSuffix(rule.pattern, "**") + "*/**"
+	}
+	return rule, true
+}
+
+// matches reports whether the rule matches rel, the slash path relative to the rule's ignore file.
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		return MatchGlob(r.pattern, rel)
+	}
+	ok, _ := path.Match(r.pattern, path.Base(rel))
+	return ok
+}
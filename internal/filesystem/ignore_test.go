@@ -0,0 +1,180 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the files under root; a path ending in "/" creates a directory.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if strings.HasSuffix(path, "/") {
+			if err := os.MkdirAll(fullPath, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// walkedPaths returns the slash paths relative to root of the files Walk yields.
+func walkedPaths(t *testing.T, root string, opts WalkOptions) []string {
+	t.Helper()
+	var paths []string
+	err := Walk(root, opts, func(entry Entry) error {
+		paths = append(paths, mustRel(t, root, entry.Path))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	return paths
+}
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		want ignoreRule
+	}{
+		{"", false, ignoreRule{}},
+		{"   ", false, ignoreRule{}},
+		{"# comment", false, ignoreRule{}},
+		{`\#not-a-comment`, true, ignoreRule{pattern: "#not-a-comment"}},
+		{`\!important`, true, ignoreRule{pattern: "!important"}},
+		{"*.log   ", true, ignoreRule{pattern: "*.log"}},
+		{`trailing\ `, true, ignoreRule{pattern: `trailing\ `}},
+		{"!keep.log", true, ignoreRule{pattern: "keep.log", negate: true}},
+		{"build/", true, ignoreRule{pattern: "build", dirOnly: true}},
+		{"/root.txt", true, ignoreRule{pattern: "root.txt", anchored: true}},
+		{"doc/*.txt", true, ignoreRule{pattern: "doc/*.txt", anchored: true}},
+		{"logs/**", true, ignoreRule{pattern: "logs/*/**", anchored: true}},
+		{"[!a]*.tmp", true, ignoreRule{pattern: "[^a]*.tmp"}},
+		{"/", false, ignoreRule{}},
+	}
+	for _, tt := range tests {
+		got, ok := parseIgnoreRule(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseIgnoreRule(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/HEAD": "ref: refs/heads/main",
+		".gitignore": strings.Join([]string{
+			"# build output",
+			"*.log",
+			"!important.log",
+			"build/",
+			"/root-only.txt",
+			"doc/*.txt",
+			"**/cache",
+			"tmp/**",
+			"[!a]*.tmp",
+		}, "\n"),
+		"sub/.gitignore":      "/local.txt\n!*.log\n",
+		"sub/deep/.gitignore": "!/build/\n",
+	})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"sub/app.log", false, false},   // re-included by sub/.gitignore
+		{"important.log", false, false}, // negation
+		{"other/important.log", false, false},
+		{"build", true, true},
+		{"build", false, false},          // directory-only pattern
+		{"build/out.o", false, true},     // inside an ignored directory
+		{"sub/build/out.o", false, true}, // unanchored directory pattern matches at any depth
+		{"sub/deep/build", true, false},  // re-included by the deeper ignore file
+		{"root-only.txt", false, true},
+		{"sub/root-only.txt", false, false}, // anchored to the root
+		{"doc/a.txt", false, true},
+		{"doc/sub/a.txt", false, false}, // "*" does not match "/"
+		{"cache", true, true},
+		{"a/b/cache", true, true},
+		{"tmp", true, false}, // "tmp/**" matches the contents only
+		{"tmp/x/y", false, true},
+		{"b.tmp", false, true},
+		{"a.tmp", false, false}, // negated character class
+		{"local.txt", false, false},
+		{"sub/local.txt", false, true}, // anchored to sub/
+		{".git", true, true},           // built-in patterns
+		{"node_modules/pkg/index.js", false, true},
+		{"main.go", false, false},
+	}
+
+	matcher, err := NewIgnoreMatcher(filepath.Join(root, "sub"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if got := matcher.Ignored(filepath.Join(root, filepath.FromSlash(tt.path)), tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, isDir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	if matcher.Ignored(filepath.Dir(root), true) {
+		t.Error("Expected paths outside the repository not to be ignored")
+	}
+}
+
+func TestWalkRespectsIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/config":         "[core]",
+		".gitignore":          "*.log\nbuild/\n!build/keep.txt\n",
+		".geminiignore":       "secrets/\n!debug.log\n",
+		"main.go":             "package main",
+		"debug.log":           "log",
+		"other.log":           "log",
+		"build/out.o":         "binary",
+		"build/keep.txt":      "cannot be re-included: its parent directory is excluded",
+		"secrets/key.pem":     "key",
+		"node_modules/x/x.js": "x",
+		"vendor/lib/lib.go":   "package lib",
+		"pkg/vendor/v.go":     "package vendor",
+		"pkg/lib.go":          "package pkg",
+		"pkg/.geminiignore":   "!vendor/\n",
+	})
+
+	got := strings.Join(walkedPaths(t, root, WalkOptions{}), " ")
+	want := ".geminiignore .gitignore debug.log main.go pkg/.geminiignore pkg/lib.go pkg/vendor/v.go"
+	if got != want {
+		t.Errorf("Walk with ignore files:\n got %s\nwant %s", got, want)
+	}
+
+	// Walking an ignored directory explicitly lists its files.
+	got = strings.Join(walkedPaths(t, filepath.Join(root, "build"), WalkOptions{}), " ")
+	if got != "keep.txt out.o" {
+		t.Errorf("Walk of an explicitly given directory: got %q", got)
+	}
+
+	// Walking a subdirectory still applies the ignore files of its parents.
+	writeTree(t, root, map[string]string{"pkg/trace.log": "log"})
+	if got := walkedPaths(t, filepath.Join(root, "pkg"), WalkOptions{Extensions: []string{".log"}}); len(got) != 0 {
+		t.Errorf("Expected the root .gitignore to apply in pkg, got %v", got)
+	}
+
+	got = strings.Join(walkedPaths(t, root, WalkOptions{DisableGitIgnore: true}), " ")
+	want = ".geminiignore .gitignore build/keep.txt build/out.o debug.log main.go other.log pkg/.geminiignore pkg/lib.go pkg/trace.log pkg/vendor/v.go"
+	if got != want {
+		t.Errorf("Walk without .gitignore:\n got %s\nwant %s", got, want)
+	}
+}
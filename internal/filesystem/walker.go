@@ -1,61 +1,197 @@
 package filesystem
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
+// DefaultReadWorkers is how many files ReadFiles reads at once when no worker count is given.
+const DefaultReadWorkers = 8
+
 // FileContent represents the content of a file with its path.
 type FileContent struct {
 	Path    string
 	Content []byte
 }
 
-// WalkDir recursively walks a directory and returns the content of files
-// that match the given file extensions.
-func WalkDir(root string, extensions []string) ([]FileContent, error) {
-	var files []FileContent
+// Entry describes a file found by a walk. Its content is only read when ReadContent is called.
+type Entry struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
 
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// ReadContent reads the content of the file.
+func (e Entry) ReadContent() ([]byte, error) {
+	content, err := os.ReadFile(e.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", e.Path, err)
+	}
+	return content, nil
+}
 
-		if info.IsDir() {
-			return nil
+// WalkOptions selects which files a walk yields.
+type WalkOptions struct {
+	// Extensions limits the walk to files whose names end with one of the extensions.
+	// An empty list matches every file.
+	Extensions []string
+	// DisableGitIgnore stops the walk from skipping files matched by .gitignore files.
+	// Files matched by .geminiignore files or DefaultIgnorePatterns are still skipped.
+	DisableGitIgnore bool
+}
+
+// matches reports whether the file called name is selected by the options.
+func (o WalkOptions) matches(name string) bool {
+	if len(o.Extensions) == 0 {
+		return true
+	}
+	for _, ext := range o.Extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
 		}
+	}
+	return false
+}
 
-		// Check if the file extension matches any of the desired extensions
-		if len(extensions) > 0 {
-			match := false
-			for _, ext := range extensions {
-				if strings.HasSuffix(info.Name(), ext) {
-					match = true
-					break
-				}
+// Walk recursively walks root and calls fn with the metadata of every file selected by opts,
+// in lexical order, one file at a time. File contents are not read, so memory use does not
+// grow with the size of the tree. Files and directories matched by ignore files are skipped
+// (see IgnoreMatcher), without descending into ignored directories.
+// If fn returns fs.SkipAll, the walk stops without an error.
+func Walk(root string, opts WalkOptions, fn func(entry Entry) error) error {
+	ignore, err := NewIgnoreMatcher(root, !opts.DisableGitIgnore)
+	if err != nil {
+		return fmt.Errorf("error walking directory %s: %w", root, err)
+	}
+	rootRel, filtered := ignore.relative(root)
+
+	err = filepath.WalkDir(root, func(filePath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath != root && filtered {
+			rel, err := filepath.Rel(root, filePath)
+			if err != nil {
+				return err
 			}
-			if !match {
+			if ignore.match(path.Join(rootRel, filepath.ToSlash(rel)), dirEntry.IsDir()) {
+				if dirEntry.IsDir() {
+					return fs.SkipDir
+				}
 				return nil
 			}
 		}
-
-		content, err := os.ReadFile(path)
+		if dirEntry.IsDir() || !opts.matches(dirEntry.Name()) {
+			return nil
+		}
+		info, err := dirEntry.Info()
 		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", path, err)
+			// The file was removed during the walk.
+			return nil
 		}
+		return fn(Entry{Path: filePath, Size: info.Size(), ModTime: info.ModTime()})
+	})
+	if err != nil {
+		return fmt.Errorf("error walking directory %s: %w", root, err)
+	}
+	return nil
+}
 
-		files = append(files, FileContent{
-			Path:    path,
-			Content: content,
-		})
+// Entries returns the metadata of every file under root selected by opts, in lexical order.
+func Entries(root string, opts WalkOptions) ([]Entry, error) {
+	var entries []Entry
+	err := Walk(root, opts, func(entry Entry) error {
+		entries = append(entries, entry)
 		return nil
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("error walking directory %s: %w", root, err)
+		return nil, err
 	}
+	return entries, nil
+}
 
+// ReadFiles reads the contents of entries with at most workers reads in flight
+// and returns them in the order of entries. A worker count of zero or less selects
+// DefaultReadWorkers. The first read error stops the remaining reads and is returned.
+func ReadFiles(ctx context.Context, entries []Entry, workers int) ([]FileContent, error) {
+	files := make([]FileContent, len(entries))
+	err := forEachConcurrently(ctx, len(entries), workers, func(i int) error {
+		content, err := entries[i].ReadContent()
+		if err != nil {
+			return err
+		}
+		files[i] = FileContent{Path: entries[i].Path, Content: content}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return files, nil
 }
+
+// forEachConcurrently calls fn for the indexes 0 to n-1 with at most workers calls in flight.
+// The first error, or ctx being done, stops the calls that have not started yet.
+func forEachConcurrently(ctx context.Context, n, workers int, fn func(i int) error) error {
+	if workers <= 0 {
+		workers = DefaultReadWorkers
+	}
+	workers = min(workers, n)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// WalkDir recursively walks a directory and returns the content of files
+// that match the given file extensions.
+// It holds every file in memory; use Walk when the contents are not needed.
+func WalkDir(root string, extensions []string) ([]FileContent, error) {
+	entries, err := Entries(root, WalkOptions{Extensions: extensions})
+	if err != nil {
+		return nil, err
+	}
+	return ReadFiles(context.Background(), entries, 0)
+}
@@ -1,6 +1,10 @@
 package filesystem
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -133,3 +137,151 @@ func TestWalkDir(t *testing.T) {
 		})
 	}
 }
+
+func TestWalk(t *testing.T) {
+	tmpDir := t.TempDir()
+	for path, content := range map[string]string{"a.go": "package a", "sub/b.go": "package b!", "sub/c.txt": "text"} {
+		fullPath := filepath.Join(tmpDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var entries []Entry
+	err := Walk(tmpDir, WalkOptions{Extensions: []string{".go"}}, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %v", entries)
+	}
+	if entries[1].Path != filepath.Join(tmpDir, "sub/b.go") || entries[1].Size != 10 || entries[1].ModTime.IsZero() {
+		t.Errorf("Unexpected entry metadata: %+v", entries[1])
+	}
+
+	// Content is read on demand, so changes after the walk are visible.
+	if err := os.WriteFile(entries[0].Path, []byte("package changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	content, err := entries[0].ReadContent()
+	if err != nil || string(content) != "package changed" {
+		t.Errorf("Expected the current content, got %q, %v", content, err)
+	}
+
+	count := 0
+	err = Walk(tmpDir, WalkOptions{}, func(Entry) error {
+		count++
+		return fs.SkipAll
+	})
+	if err != nil || count != 1 {
+		t.Errorf("Expected SkipAll to stop after one entry, got %d, %v", count, err)
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	var entries []Entry
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(tmpDir, fmt.Sprintf("file%02d.txt", i))
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("content %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, Entry{Path: path})
+	}
+
+	files, err := ReadFiles(context.Background(), entries, 3)
+	if err != nil {
+		t.Fatalf("ReadFiles failed: %v", err)
+	}
+	for i, file := range files {
+		if file.Path != entries[i].Path || string(file.Content) != fmt.Sprintf("content %d", i) {
+			t.Errorf("Unexpected file at index %d: %s %q", i, file.Path, file.Content)
+		}
+	}
+
+	missing := append(entries[:5:5], Entry{Path: filepath.Join(tmpDir, "missing.txt")})
+	if _, err := ReadFiles(context.Background(), missing, 2); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ReadFiles(ctx, entries, 2); err == nil {
+		t.Error("Expected an error for a cancelled context")
+	}
+
+	if files, err := ReadFiles(context.Background(), nil, 0); err != nil || len(files) != 0 {
+		t.Errorf("Expected no files, got %v, %v", files, err)
+	}
+}
+
+// createBenchmarkTree writes files of size bytes each under a new temporary directory.
+func createBenchmarkTree(b *testing.B, files, size int) string {
+	b.Helper()
+	root := b.TempDir()
+	content := bytes.Repeat([]byte("x"), size)
+	for i := 0; i < files; i++ {
+		path := filepath.Join(root, fmt.Sprintf("dir%02d", i%10), fmt.Sprintf("file%03d.txt", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return root
+}
+
+// BenchmarkWalkDir and BenchmarkWalk list the same tree; compare their B/op to see
+// how much memory reading every file costs when only the metadata is needed.
+func BenchmarkWalkDir(b *testing.B) {
+	root := createBenchmarkTree(b, 200, 64*1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		files, err := WalkDir(root, nil)
+		if err != nil || len(files) != 200 {
+			b.Fatalf("WalkDir returned %d files, %v", len(files), err)
+		}
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	root := createBenchmarkTree(b, 200, 64*1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		err := Walk(root, WalkOptions{}, func(Entry) error {
+			count++
+			return nil
+		})
+		if err != nil || count != 200 {
+			b.Fatalf("Walk returned %d files, %v", count, err)
+		}
+	}
+}
+
+func BenchmarkReadFiles(b *testing.B) {
+	root := createBenchmarkTree(b, 200, 64*1024)
+	entries, err := Entries(root, WalkOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, workers := range []int{1, DefaultReadWorkers} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadFiles(context.Background(), entries, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
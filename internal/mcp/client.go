@@ -0,0 +1,160 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gemini-cli-go/internal/config"
+)
+
+// DefaultTimeout bounds each request to a server whose timeout setting is not set.
+const DefaultTimeout = 10 * time.Minute
+
+// DefaultStartupTimeout bounds the start of a server whose startupTimeout setting is not set.
+const DefaultStartupTimeout = 30 * time.Second
+
+// Options configure how the client connects to servers.
+type Options struct {
+	// Stderr receives the stderr output of stdio servers. Nil discards it.
+	Stderr io.Writer
+}
+
+// Client is a connection to an MCP server on which the handshake was performed.
+type Client struct {
+	// Name is the server's key in the mcpServers setting.
+	Name string
+	// ServerInfo is how the server introduced itself.
+	ServerInfo Implementation
+
+	transport Transport
+	timeout   time.Duration
+}
+
+// Connect connects to the server configured by cfg and performs the initialize handshake.
+// The streamable HTTP transport is used if httpUrl is set, the SSE transport if url is
+// set, and otherwise the command is started and spoken to over stdio.
+func Connect(ctx context.Context, name string, cfg config.McpServerConfig, opts Options) (*Client, error) {
+	timeout := time.Duration(cfg.Timeout) * time.Millisecond
+	var transport Transport
+	switch {
+	case cfg.HTTPURL != "":
+		transport = newStreamableTransport(cfg.HTTPURL, cfg.Headers)
+	case cfg.URL != "":
+		connectCtx, cancel := context.WithTimeout(ctx, defaultTimeout(timeout))
+		defer cancel()
+		sse, err := startSSE(connectCtx, cfg.URL, cfg.Headers)
+		if err != nil {
+			return nil, err
+		}
+		transport = sse
+	case cfg.Command != "":
+		stdio, err := startStdio(cfg.Command, cfg.Args, cfg.Env, cfg.Cwd, opts.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		transport = stdio
+	default:
+		return nil, fmt.Errorf("no command or URL configured")
+	}
+	client := NewClient(name, transport, timeout)
+	if err := client.initialize(ctx); err != nil {
+		transport.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewClient returns a client that talks over transport, without performing the handshake.
+// A timeout of zero selects DefaultTimeout.
+func NewClient(name string, transport Transport, timeout time.Duration) *Client {
+	return &Client{Name: name, transport: transport, timeout: defaultTimeout(timeout)}
+}
+
+func defaultTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultTimeout
+	}
+	return timeout
+}
+
+// call sends a request bounded by the client's timeout and decodes its result into result.
+// If the server's session expired, the handshake is performed again in a new session
+// and the request sent once more.
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	raw, err := c.transport.Call(ctx, method, params)
+	if errors.Is(err, ErrSessionExpired) {
+		err = nil
+		if method != "initialize" {
+			err = c.initialize(ctx)
+		}
+		if err == nil {
+			raw, err = c.transport.Call(ctx, method, params)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", method, err)
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("invalid %s result: %w", method, err)
+	}
+	return nil
+}
+
+// initialize performs the handshake: the initialize request, followed by the initialized notification.
+func (c *Client) initialize(ctx context.Context) error {
+	var result initializeResult
+	params := initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      clientInfo,
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+	c.ServerInfo = result.ServerInfo
+	return c.transport.Notify(ctx, "notifications/initialized", nil)
+}
+
+// ListTools returns every tool the server offers, following the pages of the list.
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var tools []ToolInfo
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page listToolsResult
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool calls the server's tool called name.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", callToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close disconnects from the server.
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
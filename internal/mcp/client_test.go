@@ -0,0 +1,223 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gemini-cli-go/internal/config"
+)
+
+// fakeServerPath is the fake server built by TestMain, or "" if it could not be built.
+var fakeServerPath string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "mcp-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	path := filepath.Join(dir, "fakeserver")
+	if output, err := exec.Command("go", "build", "-o", path, "./testdata/fakeserver").CombinedOutput(); err == nil {
+		fakeServerPath = path
+	} else {
+		fmt.Fprintf(os.Stderr, "failed to build the fake server: %v\n%s", err, output)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func fakeServer(t *testing.T) config.McpServerConfig {
+	t.Helper()
+	if fakeServerPath == "" {
+		t.Skip("the fake server could not be built")
+	}
+	return config.McpServerConfig{Command: fakeServerPath}
+}
+
+// syncBuffer is a bytes.Buffer that the server's stderr can be copied to concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestClient(t *testing.T) {
+	cfg := fakeServer(t)
+	cfg.Env = map[string]string{"FAKE_VAR": "from settings"}
+	cfg.Cwd = t.TempDir()
+	stderr := &syncBuffer{}
+
+	ctx := context.Background()
+	client, err := Connect(ctx, "fake", cfg, Options{Stderr: stderr})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if client.ServerInfo.Name != "fake" || client.ServerInfo.Version != "1.0" {
+		t.Errorf("Unexpected server info: %+v", client.ServerInfo)
+	}
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, " ") != "echo fail env cwd slow" {
+		t.Errorf("Expected the tools of both pages, got %v", names)
+	}
+
+	for _, tt := range []struct {
+		tool string
+		args map[string]interface{}
+		want string
+	}{
+		{"echo", map[string]interface{}{"text": "hello"}, "hello"},
+		{"env", nil, "from settings"},
+		{"cwd", nil, cfg.Cwd},
+	} {
+		result, err := client.CallTool(ctx, tt.tool, tt.args)
+		if err != nil {
+			t.Fatalf("CallTool(%s) failed: %v", tt.tool, err)
+		}
+		if got := FormatContent(result.Content); got != tt.want || result.IsError {
+			t.Errorf("CallTool(%s) = %q (error: %v), want %q", tt.tool, got, result.IsError, tt.want)
+		}
+	}
+
+	var rpcErr *RPCError
+	if _, err := client.CallTool(ctx, "missing", nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32602 {
+		t.Errorf("Expected an RPC error for an unknown tool, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "fake server started") {
+		t.Errorf("Expected the server's stderr to be copied, got %q", stderr.String())
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	cfg := fakeServer(t)
+	cfg.Timeout = 200
+
+	ctx := context.Background()
+	client, err := Connect(ctx, "fake", cfg, Options{})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	start := time.Now()
+	if _, err := client.CallTool(ctx, "slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the call to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the call to end after the timeout, took %v", elapsed)
+	}
+	// The connection is still usable after a timed out call.
+	if _, err := client.CallTool(ctx, "echo", map[string]interface{}{"text": "still here"}); err != nil {
+		t.Errorf("CallTool failed after a timeout: %v", err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	cfg := fakeServer(t)
+	broken := cfg
+	broken.Env = map[string]string{"FAKE_EXIT": "1"}
+	trusted := cfg
+	trusted.Trust = true
+
+	manager, err := Start(context.Background(), map[string]config.McpServerConfig{
+		"broken":  broken,
+		"fake":    cfg,
+		"trusted": trusted,
+		"missing": {Command: filepath.Join(t.TempDir(), "missing")},
+	}, Options{})
+	defer manager.Close()
+
+	if err == nil || !strings.Contains(err.Error(), "MCP server broken: ") || !strings.Contains(err.Error(), "MCP server missing: ") {
+		t.Errorf("Expected errors for the broken and missing servers, got %v", err)
+	}
+	if len(manager.Clients()) != 2 {
+		t.Fatalf("Expected 2 connected servers, got %d", len(manager.Clients()))
+	}
+
+	tools := make(map[string]*Tool)
+	for _, tool := range manager.Tools() {
+		tools[tool.Name()] = tool.(*Tool)
+	}
+	if len(tools) != 10 {
+		t.Errorf("Expected 10 tools, got %d", len(tools))
+	}
+	echo := tools["fake__echo"]
+	if echo == nil || echo.IsReadOnly() || echo.IsTrusted() {
+		t.Fatalf("Expected an untrusted fake__echo tool that ignores its readOnlyHint, got %+v", echo)
+	}
+	if trustedEcho := tools["trusted__echo"]; trustedEcho == nil || !trustedEcho.IsReadOnly() || !trustedEcho.IsTrusted() {
+		t.Errorf("Expected a read-only, trusted trusted__echo tool, got %+v", trustedEcho)
+	}
+	if fail := tools["trusted__fail"]; fail == nil || fail.IsReadOnly() || !fail.IsTrusted() {
+		t.Errorf("Expected a mutating, trusted trusted__fail tool, got %+v", fail)
+	}
+
+	declaration := echo.FunctionDeclaration()
+	if declaration.Description != "Echoes its text. (from MCP server fake)" || len(declaration.Parameters.Required) != 1 {
+		t.Errorf("Unexpected declaration: %+v", declaration)
+	}
+	output, err := echo.Execute(context.Background(), map[string]interface{}{"text": "hi"})
+	if err != nil || output != "hi" {
+		t.Errorf("Execute() = %q, %v", output, err)
+	}
+	if _, err := tools["fake__fail"].Execute(context.Background(), nil); err == nil || err.Error() != "something broke" {
+		t.Errorf("Expected the tool's error, got %v", err)
+	}
+
+	if err := manager.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if _, err := echo.Execute(context.Background(), map[string]interface{}{"text": "hi"}); err == nil {
+		t.Error("Expected calls to fail after Close")
+	}
+}
+
+func TestStartSkipsHungServers(t *testing.T) {
+	cfg := fakeServer(t)
+	hung := cfg
+	hung.Env = map[string]string{"FAKE_HANG": "1"}
+	hung.StartupTimeout = 200
+
+	start := time.Now()
+	manager, err := Start(context.Background(), map[string]config.McpServerConfig{"fake": cfg, "hung": hung}, Options{})
+	defer manager.Close()
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the hung server to be given up on after its startup timeout, took %v", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "MCP server hung: did not start within 200ms") {
+		t.Errorf("Expected a startup timeout for the hung server, got %v", err)
+	}
+	if len(manager.Clients()) != 1 || manager.Clients()[0].Name != "fake" {
+		t.Errorf("Expected only the fake server to be connected, got %d", len(manager.Clients()))
+	}
+}
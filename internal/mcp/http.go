@@ -0,0 +1,257 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+// sessionHeader carries the session ID of the streamable HTTP transport.
+const sessionHeader = "Mcp-Session-Id"
+
+// streamableTransport speaks the streamable HTTP transport of MCP revision 2025-03-26:
+// every message is POSTed to the server's URL, which answers a request either with
+// a JSON response or with an event stream ending with the response. A stream that
+// breaks before the response is resumed with GET and Last-Event-ID. The server may
+// assign a session ID in its answer to initialize, which is sent with every later
+// message; a 404 for it means the session expired.
+type streamableTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	nextID    int64
+	sessionID string
+}
+
+func newStreamableTransport(serverURL string, headers map[string]string) *streamableTransport {
+	return &streamableTransport{url: serverURL, headers: headers, client: http.DefaultClient}
+}
+
+// session returns the current session ID, "" before the server assigned one.
+func (t *streamableTransport) session() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+// newRequest builds a request to the server with the configured headers and the session ID.
+func (t *streamableTransport) newRequest(ctx context.Context, method string, body []byte) (*http.Request, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	setHeaders(req, t.headers)
+	session := t.session()
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	return req, session, nil
+}
+
+// post sends msg. The response is returned if its status is successful; a 404 for
+// a session makes the transport forget the session and return ErrSessionExpired.
+func (t *streamableTransport) post(ctx context.Context, msg *message) (*http.Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, session, err := t.newRequest(ctx, http.MethodPost, data)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send to the server: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound && session != "" {
+		resp.Body.Close()
+		t.mu.Lock()
+		if t.sessionID == session {
+			t.sessionID = ""
+		}
+		t.mu.Unlock()
+		return nil, ErrSessionExpired
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, statusError(resp)
+	}
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// send posts a message that has no response, such as a notification.
+func (t *streamableTransport) send(ctx context.Context, msg *message) error {
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (t *streamableTransport) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	t.mu.Lock()
+	t.nextID++
+	id := t.nextID
+	t.mu.Unlock()
+	msg, err := newMessage(requestID(id), method, params)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response *message
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		response = &message{}
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+	case "text/event-stream":
+		response, err = t.readStream(ctx, resp.Body, id)
+	default:
+		err = fmt.Errorf("unexpected response content type %q", mediaType)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+			defer cancel()
+			t.send(cancelCtx, cancelNotification(id, ctx.Err()))
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Result, nil
+}
+
+// readStream reads the event stream answering the request with the given ID until its
+// response arrives. Requests from the server on the stream are answered; notifications
+// are ignored. If the stream ends early and its events had IDs, it is resumed. The
+// stream being read when it returns, first or resumed, is closed.
+func (t *streamableTransport) readStream(ctx context.Context, body io.ReadCloser, id int64) (*message, error) {
+	defer func() {
+		body.Close()
+	}()
+	lastEventID := ""
+	attempt := 0
+	for {
+		reader := newSSEReader(body)
+		for {
+			event, err := reader.next()
+			if err != nil {
+				break
+			}
+			if event.ID != "" {
+				lastEventID = event.ID
+			}
+			var msg message
+			if event.Event != "message" || json.Unmarshal([]byte(event.Data), &msg) != nil {
+				continue
+			}
+			switch {
+			case msg.Method != "" && msg.ID != nil:
+				if err := t.send(ctx, answerServerRequest(&msg)); err != nil {
+					return nil, err
+				}
+			case msg.Method != "":
+				// Notifications are not acted on.
+			default:
+				if responseID, ok := parseID(msg.ID); ok && responseID == id {
+					return &msg, nil
+				}
+			}
+		}
+		body.Close()
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if lastEventID == "" || attempt >= reconnectAttempts {
+			return nil, errors.New("the server closed the stream before responding")
+		}
+		if attempt > 0 {
+			if err := sleepContext(ctx, reconnectDelay<<(attempt-1)); err != nil {
+				return nil, err
+			}
+		}
+		attempt++
+		resumed, err := t.resume(ctx, lastEventID)
+		if err != nil {
+			return nil, err
+		}
+		body = resumed
+	}
+}
+
+// resume reopens a broken stream after the event with the given ID.
+func (t *streamableTransport) resume(ctx context.Context, lastEventID string) (io.ReadCloser, error) {
+	req, _, err := t.newRequest(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", lastEventID)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume the stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("failed to resume the stream: %w", statusError(resp))
+	}
+	return resp.Body, nil
+}
+
+func (t *streamableTransport) Notify(ctx context.Context, method string, params interface{}) error {
+	msg, err := newMessage(nil, method, params)
+	if err != nil {
+		return err
+	}
+	return t.send(ctx, msg)
+}
+
+// Close ends the session, if the server assigned one.
+func (t *streamableTransport) Close() error {
+	if t.session() == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	req, _, err := t.newRequest(ctx, http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to end the session: %w", err)
+	}
+	resp.Body.Close()
+	t.mu.Lock()
+	t.sessionID = ""
+	t.mu.Unlock()
+	return nil
+}
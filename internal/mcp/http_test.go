@@ -0,0 +1,427 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gemini-cli-go/internal/config"
+)
+
+const testToken = "Bearer secret"
+
+// fakeResult answers the requests both fake HTTP servers support.
+func fakeResult(request *message) (interface{}, *RPCError) {
+	switch request.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "http-fake", "version": "2.0"},
+		}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": []interface{}{
+			map[string]interface{}{"name": "echo", "inputSchema": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}},
+			}},
+		}}, nil
+	case "tools/call":
+		var params callToolParams
+		json.Unmarshal(request.Params, &params)
+		return map[string]interface{}{"content": []interface{}{
+			map[string]interface{}{"type": "text", "text": fmt.Sprint(params.Arguments["text"])},
+		}}, nil
+	}
+	return nil, &RPCError{Code: codeMethodNotFound, Message: "method not found"}
+}
+
+// fakeResponse encodes the answer to request.
+func fakeResponse(request *message) []byte {
+	result, rpcErr := fakeResult(request)
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": request.ID}
+	if rpcErr != nil {
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	data, _ := json.Marshal(response)
+	return data
+}
+
+func writeEvent(w http.ResponseWriter, id, event string, data []byte) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	w.(http.Flusher).Flush()
+}
+
+// streamableServer is a fake server of the streamable HTTP transport. tools/list is
+// answered on an event stream that also carries a notification and a ping, and calls
+// to the "flaky" tool get a stream that breaks before the response and must be resumed.
+type streamableServer struct {
+	mu          sync.Mutex
+	sessions    map[string]bool
+	nextSession int
+	initialized int
+	pinged      bool
+	flakyID     json.RawMessage
+	deleted     []string
+}
+
+func (s *streamableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != testToken {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := r.Header.Get(sessionHeader)
+
+	switch r.Method {
+	case http.MethodDelete:
+		delete(s.sessions, session)
+		s.deleted = append(s.deleted, session)
+		return
+	case http.MethodGet:
+		if r.Header.Get("Last-Event-ID") != "1" || s.flakyID == nil {
+			http.Error(w, "no stream to resume", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeEvent(w, "2", "", []byte(`{"jsonrpc":"2.0","id":`+string(s.flakyID)+`,"result":{"content":[{"type":"text","text":"resumed"}]}}`))
+		return
+	}
+
+	var msg message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if msg.Method == "initialize" {
+		s.nextSession++
+		s.initialized++
+		session = fmt.Sprintf("s%d", s.nextSession)
+		s.sessions[session] = true
+		w.Header().Set(sessionHeader, session)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fakeResponse(&msg))
+		return
+	}
+	if !s.sessions[session] {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	if msg.Method == "" || msg.ID == nil {
+		// A notification, or the answer to the ping.
+		if msg.Method == "" && string(msg.Result) == "{}" {
+			s.pinged = true
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	var params callToolParams
+	json.Unmarshal(msg.Params, &params)
+	switch {
+	case msg.Method == "tools/list":
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeEvent(w, "", "", []byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info","data":"listing"}}`))
+		writeEvent(w, "", "", []byte(`{"jsonrpc":"2.0","id":"server-1","method":"ping"}`))
+		// Release the lock while the client answers the ping.
+		s.mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		s.mu.Lock()
+		writeEvent(w, "", "", fakeResponse(&msg))
+	case params.Name == "flaky":
+		s.flakyID = *msg.ID
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeEvent(w, "1", "", []byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fakeResponse(&msg))
+	}
+}
+
+// expire forgets every session, as a restarted server would.
+func (s *streamableServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]bool)
+}
+
+func TestStreamableHTTP(t *testing.T) {
+	fake := &streamableServer{sessions: make(map[string]bool)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cfg := config.McpServerConfig{HTTPURL: server.URL + "/mcp", Headers: map[string]string{"Authorization": testToken}}
+
+	ctx := context.Background()
+	client, err := Connect(ctx, "remote", cfg, Options{})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if client.ServerInfo.Name != "http-fake" {
+		t.Errorf("Unexpected server info: %+v", client.ServerInfo)
+	}
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "echo" {
+		t.Errorf("Unexpected tools: %+v", tools)
+	}
+	fake.mu.Lock()
+	if !fake.pinged {
+		t.Error("Expected the client to answer the server's ping")
+	}
+	fake.mu.Unlock()
+
+	for _, tt := range []struct{ tool, want string }{{"echo", "hi"}, {"flaky", "resumed"}} {
+		result, err := client.CallTool(ctx, tt.tool, map[string]interface{}{"text": "hi"})
+		if err != nil {
+			t.Fatalf("CallTool(%s) failed: %v", tt.tool, err)
+		}
+		if got := FormatContent(result.Content); got != tt.want {
+			t.Errorf("CallTool(%s) = %q, want %q", tt.tool, got, tt.want)
+		}
+	}
+
+	// After the session expires, the client starts a new one and retries.
+	fake.expire()
+	if _, err := client.CallTool(ctx, "echo", map[string]interface{}{"text": "again"}); err != nil {
+		t.Fatalf("CallTool failed after the session expired: %v", err)
+	}
+	if fake.initialized != 2 {
+		t.Errorf("Expected 2 handshakes, got %d", fake.initialized)
+	}
+
+	client.Close()
+	if strings.Join(fake.deleted, " ") != "s2" {
+		t.Errorf("Expected session s2 to be ended, got %v", fake.deleted)
+	}
+
+	cfg.Headers = nil
+	if _, err := Connect(ctx, "remote", cfg, Options{}); err == nil || !strings.Contains(err.Error(), "401 Unauthorized: missing token") {
+		t.Errorf("Expected the server's error without the token, got %v", err)
+	}
+}
+
+// roundTripFunc answers the requests of an http.Client.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// trackedBody is a response body that records whether it was closed.
+type trackedBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestReadStreamClosesResumedStreams(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		resumed string
+		wantErr bool
+	}{
+		{"response", "id: 2\ndata: {\"jsonrpc\":\"2.0\",\"id\":7,\"result\":{}}\n\n", false},
+		{"failed answer", "id: 2\ndata: {\"jsonrpc\":\"2.0\",\"id\":\"s\",\"method\":\"ping\"}\n\n", true},
+		{"ended again", "id: 2\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n", true},
+	} {
+		var bodies []*trackedBody
+		transport := newStreamableTransport("http://mcp.invalid/mcp", nil)
+		transport.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method == http.MethodPost {
+				return nil, fmt.Errorf("cannot answer")
+			}
+			body := &trackedBody{Reader: strings.NewReader(tt.resumed)}
+			bodies = append(bodies, body)
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body}, nil
+		})}
+		first := &trackedBody{Reader: strings.NewReader("id: 1\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")}
+
+		response, err := transport.readStream(context.Background(), first, 7)
+		if (err != nil) != tt.wantErr || (err == nil && response.Result == nil) {
+			t.Errorf("%s: readStream() = %+v, %v", tt.name, response, err)
+		}
+		if !first.closed || len(bodies) == 0 {
+			t.Fatalf("%s: expected the first stream to be closed and resumed", tt.name)
+		}
+		for i, body := range bodies {
+			if !body.closed {
+				t.Errorf("%s: resumed stream %d was not closed", tt.name, i+1)
+			}
+		}
+	}
+}
+
+// sseServer is a fake server of the HTTP with SSE transport. Each GET opens a session
+// whose messages are POSTed to /messages; responses are sent on the session's stream.
+type sseServer struct {
+	mu          sync.Mutex
+	streams     map[string]chan []byte
+	nextSession int
+	initialized int
+	pinged      bool
+}
+
+func (s *sseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != testToken {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodGet {
+		s.serveStream(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	stream, ok := s.streams[r.URL.Query().Get("session")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	var msg message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case msg.Method == "":
+		s.pinged = s.pinged || string(msg.Result) == "{}"
+	case msg.Method == "notifications/initialized":
+		stream <- []byte(`{"jsonrpc":"2.0","id":7,"method":"ping"}`)
+	case msg.ID != nil:
+		if msg.Method == "initialize" {
+			s.initialized++
+		}
+		stream <- fakeResponse(&msg)
+	}
+}
+
+func (s *sseServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.nextSession++
+	session := fmt.Sprint(s.nextSession)
+	stream := make(chan []byte, 10)
+	s.streams[session] = stream
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	writeEvent(w, "", "endpoint", []byte("/messages?session="+session))
+	for {
+		select {
+		case data, ok := <-stream:
+			if !ok {
+				return
+			}
+			writeEvent(w, "", "message", data)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// drop ends every stream, and with them the sessions.
+func (s *sseServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for session, stream := range s.streams {
+		close(stream)
+		delete(s.streams, session)
+	}
+}
+
+func TestSSE(t *testing.T) {
+	defer func(delay time.Duration) { reconnectDelay = delay }(reconnectDelay)
+	reconnectDelay = time.Millisecond
+
+	fake := &sseServer{streams: make(map[string]chan []byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer fake.drop()
+	cfg := config.McpServerConfig{URL: server.URL + "/sse", Headers: map[string]string{"Authorization": testToken}}
+
+	ctx := context.Background()
+	client, err := Connect(ctx, "remote", cfg, Options{})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if client.ServerInfo.Name != "http-fake" {
+		t.Errorf("Unexpected server info: %+v", client.ServerInfo)
+	}
+
+	tools, err := client.ListTools(ctx)
+	if err != nil || len(tools) != 1 {
+		t.Fatalf("ListTools() = %+v, %v", tools, err)
+	}
+	result, err := client.CallTool(ctx, "echo", map[string]interface{}{"text": "hi"})
+	if err != nil || FormatContent(result.Content) != "hi" {
+		t.Fatalf("CallTool() = %+v, %v", result, err)
+	}
+	fake.mu.Lock()
+	if !fake.pinged {
+		t.Error("Expected the client to answer the server's ping")
+	}
+	fake.mu.Unlock()
+
+	// The client reconnects, performs the handshake again and retries.
+	fake.drop()
+	result, err = client.CallTool(ctx, "echo", map[string]interface{}{"text": "again"})
+	if err != nil || FormatContent(result.Content) != "again" {
+		t.Fatalf("CallTool() after the stream was lost = %+v, %v", result, err)
+	}
+	fake.mu.Lock()
+	if fake.initialized != 2 || fake.nextSession != 2 {
+		t.Errorf("Expected 2 sessions and handshakes, got %d and %d", fake.nextSession, fake.initialized)
+	}
+	fake.mu.Unlock()
+
+	cfg.Headers = nil
+	if _, err := Connect(ctx, "remote", cfg, Options{}); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("Expected the server's error without the token, got %v", err)
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	input := ": comment\nevent: endpoint\ndata: /messages\n\nid: 4\ndata: {\"a\":\ndata: 1}\r\n\r\nevent: ignored\n\ndata: last"
+	reader := newSSEReader(strings.NewReader(input))
+
+	var got []sseEvent
+	for {
+		event, err := reader.next()
+		if err != nil {
+			break
+		}
+		got = append(got, event)
+	}
+	want := []sseEvent{
+		{Event: "endpoint", Data: "/messages"},
+		{ID: "4", Event: "message", Data: "{\"a\":\n1}"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Read events %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/shared"
+)
+
+// Manager holds the connections to the configured servers and the tools they offer.
+type Manager struct {
+	clients []*Client
+	tools   []shared.Tool
+}
+
+// Start connects to every server in servers concurrently and lists their tools.
+// Servers that fail to start or to list their tools within their startup timeout are
+// left out, and their errors are returned joined, in the order of the server names;
+// the manager holds the others.
+func Start(ctx context.Context, servers map[string]config.McpServerConfig, opts Options) (*Manager, error) {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clients := make([]*Client, len(names))
+	tools := make([][]shared.Tool, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clients[i], tools[i], errs[i] = startServer(ctx, name, servers[name], opts)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("MCP server %s: %w", name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	manager := &Manager{}
+	for i := range names {
+		if clients[i] != nil {
+			manager.clients = append(manager.clients, clients[i])
+			manager.tools = append(manager.tools, tools[i]...)
+		}
+	}
+	return manager, errors.Join(errs...)
+}
+
+// startServer connects to a server and adapts its tools.
+func startServer(ctx context.Context, name string, cfg config.McpServerConfig, opts Options) (*Client, []shared.Tool, error) {
+	timeout := time.Duration(cfg.StartupTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = DefaultStartupTimeout
+	}
+	startCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	timedOut := func(err error) error {
+		if errors.Is(startCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("did not start within %s: %w", timeout, err)
+		}
+		return err
+	}
+
+	client, err := Connect(startCtx, name, cfg, opts)
+	if err != nil {
+		return nil, nil, timedOut(err)
+	}
+	infos, err := client.ListTools(startCtx)
+	if err != nil {
+		client.Close()
+		return nil, nil, timedOut(err)
+	}
+	tools := make([]shared.Tool, 0, len(infos))
+	for _, info := range infos {
+		adapted, err := NewTool(client, info, cfg.Trust)
+		if err != nil {
+			client.Close()
+			return nil, nil, err
+		}
+		tools = append(tools, adapted)
+	}
+	return client, tools, nil
+}
+
+// Clients returns the connected servers, in the order of their names.
+func (m *Manager) Clients() []*Client {
+	return m.clients
+}
+
+// Tools returns the tools of every connected server.
+func (m *Manager) Tools() []shared.Tool {
+	return m.tools
+}
+
+// Close disconnects from every server.
+func (m *Manager) Close() error {
+	var errs []error
+	for _, client := range m.clients {
+		errs = append(errs, client.Close())
+	}
+	m.clients, m.tools = nil, nil
+	return errors.Join(errs...)
+}
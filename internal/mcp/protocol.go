@@ -0,0 +1,113 @@
+// Package mcp is a client for Model Context Protocol servers. It connects to the
+// servers of the mcpServers setting, lists their tools and offers them to the model
+// as shared.Tool values. Server offers the CLI's own tools to other MCP clients.
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtocolVersion is the MCP revision the client asks for in the handshake.
+const ProtocolVersion = "2025-03-26"
+
+// JSON-RPC error codes used by the client.
+const (
+	codeMethodNotFound = -32601
+)
+
+// Implementation names the client or the server in the handshake.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// clientInfo is how the client introduces itself to servers.
+var clientInfo = Implementation{Name: "gemini-cli-go", Version: "0.1.0"}
+
+// message is a JSON-RPC 2.0 message: a request if Method and ID are set, a notification
+// if only Method is set, and a response otherwise.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *RPCError        `json:"error,omitempty"`
+}
+
+// RPCError is an error returned by a server in a JSON-RPC response.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// initializeParams are the parameters of the initialize request.
+type initializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ClientInfo      Implementation         `json:"clientInfo"`
+}
+
+// initializeResult is the server's answer to the initialize request.
+type initializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      Implementation         `json:"serverInfo"`
+	Instructions    string                 `json:"instructions,omitempty"`
+}
+
+// ToolInfo describes a tool offered by a server.
+type ToolInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	InputSchema json.RawMessage  `json:"inputSchema"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are hints about a tool's behavior. Servers are not trusted to report them correctly.
+type ToolAnnotations struct {
+	Title        string `json:"title,omitempty"`
+	ReadOnlyHint bool   `json:"readOnlyHint,omitempty"`
+}
+
+// listToolsResult is one page of the answer to tools/list.
+type listToolsResult struct {
+	Tools      []ToolInfo `json:"tools"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// callToolParams are the parameters of tools/call.
+type callToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// Content is an item of a tool's result. Text is set for text content, Data and MimeType
+// for images and audio, and Resource for embedded resources.
+type Content struct {
+	Type     string           `json:"type"`
+	Text     string           `json:"text,omitempty"`
+	Data     string           `json:"data,omitempty"`
+	MimeType string           `json:"mimeType,omitempty"`
+	Resource *ResourceContent `json:"resource,omitempty"`
+}
+
+// ResourceContent is a resource embedded in a tool's result.
+type ResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+// CallToolResult is the result of a tool call. IsError reports a failure of the tool
+// itself, described by the content, as opposed to a protocol error.
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
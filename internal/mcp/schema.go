@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gemini-cli-go/internal/shared"
+)
+
+// ConvertSchema converts the JSON Schema of a tool's input into a shared.Schema. Servers
+// use more of JSON Schema than the API accepts, so the conversion is lenient (see
+// shared.JSONSchemaOptions). An empty input converts to an object without properties.
+func ConvertSchema(data json.RawMessage) (shared.Schema, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return shared.Schema{Type: shared.TypeObject}, nil
+	}
+	schema, err := shared.ParseJSONSchema(data, shared.JSONSchemaOptions{Lenient: true})
+	if err != nil {
+		return shared.Schema{}, fmt.Errorf("invalid input schema: %w", err)
+	}
+	if schema.Type != shared.TypeObject {
+		return shared.Schema{}, fmt.Errorf("input schema has type %q, want object", schema.Type)
+	}
+	return schema, nil
+}
+
+// ToJSONSchema converts a shared.Schema into JSON Schema, for the input schemas of the
+// tools the server offers. A nullable schema allows null in addition to its type.
+func ToJSONSchema(schema shared.Schema) map[string]interface{} {
+	result := map[string]interface{}{"type": string(schema.Type)}
+	if schema.Nullable {
+		result["type"] = []string{string(schema.Type), "null"}
+	}
+	if schema.Description != "" {
+		result["description"] = schema.Description
+	}
+	if len(schema.Enum) > 0 {
+		result["enum"] = schema.Enum
+	}
+	if schema.Items != nil {
+		result["items"] = ToJSONSchema(*schema.Items)
+	}
+	if schema.Type == shared.TypeObject {
+		properties := make(map[string]interface{}, len(schema.Properties))
+		for name, property := range schema.Properties {
+			properties[name] = ToJSONSchema(property)
+		}
+		result["properties"] = properties
+	}
+	if len(schema.Required) > 0 {
+		result["required"] = schema.Required
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/shared"
+)
+
+func TestConvertSchema(t *testing.T) {
+	input := `{
+		"type": "object",
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"additionalProperties": false,
+		"properties": {
+			"path": {"type": "string", "description": "A file.", "minLength": 1},
+			"mode": {"type": ["string", "null"], "enum": ["r", "w", 1]},
+			"lines": {"type": "array"},
+			"limit": {"anyOf": [{"type": "integer"}, {"type": "null"}], "description": "At most."},
+			"options": {"properties": {"force": {"type": "boolean"}}, "required": ["force", "other"]},
+			"anything": {}
+		},
+		"required": ["path", "undefined"]
+	}`
+	got, err := ConvertSchema(json.RawMessage(input))
+	if err != nil {
+		t.Fatalf("ConvertSchema failed: %v", err)
+	}
+	want := shared.Schema{
+		Type: shared.TypeObject,
+		Properties: map[string]shared.Schema{
+			"path":  {Type: shared.TypeString, Description: "A file."},
+			"mode":  {Type: shared.TypeString, Enum: []string{"r", "w"}, Nullable: true},
+			"lines": {Type: shared.TypeArray, Items: &shared.Schema{Type: shared.TypeString}},
+			"limit": {Type: shared.TypeInteger, Description: "At most.", Nullable: true},
+			"options": {
+				Type:       shared.TypeObject,
+				Properties: map[string]shared.Schema{"force": {Type: shared.TypeBoolean}},
+				Required:   []string{"force"},
+			},
+			"anything": {Type: shared.TypeString},
+		},
+		Required: []string{"path"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertSchema() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got, err := ConvertSchema(nil); err != nil || got.Type != shared.TypeObject {
+		t.Errorf("Expected an empty object for no schema, got %+v, %v", got, err)
+	}
+	for _, invalid := range []string{`{"type": "string"}`, `[1]`} {
+		if _, err := ConvertSchema(json.RawMessage(invalid)); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestToJSONSchema(t *testing.T) {
+	schema := shared.Schema{
+		Type: shared.TypeObject,
+		Properties: map[string]shared.Schema{
+			"path":  {Type: shared.TypeString, Description: "A file."},
+			"mode":  {Type: shared.TypeString, Enum: []string{"r", "w"}, Nullable: true},
+			"lines": {Type: shared.TypeArray, Items: &shared.Schema{Type: shared.TypeInteger}},
+		},
+		Required: []string{"path"},
+	}
+	data, err := json.Marshal(ToJSONSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"mode":{"enum":["r","w"],"type":["string","null"]}`) {
+		t.Errorf("Expected a nullable type list, got %s", data)
+	}
+	// Converting back gives the original schema.
+	got, err := ConvertSchema(data)
+	if err != nil {
+		t.Fatalf("ConvertSchema failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, schema) {
+		t.Errorf("Round trip gave\n%+v\nwant\n%+v", got, schema)
+	}
+}
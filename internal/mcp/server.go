@@ -0,0 +1,254 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"gemini-cli-go/internal/shared"
+)
+
+// JSON-RPC error codes returned by the server.
+const (
+	codeParseError    = -32700
+	codeInvalidParams = -32602
+	codeInternalError = -32603
+)
+
+// supportedVersions are the MCP revisions the server accepts in the handshake.
+var supportedVersions = map[string]bool{"2024-11-05": true, ProtocolVersion: true}
+
+// Server offers the tools of a registry to MCP clients over stdio. Tools run without
+// confirmation, which is up to the client; read-only tools are annotated as such.
+// Read-only tools run concurrently, and mutating tools alone, as in tool.Executor.
+type Server struct {
+	tools shared.ToolRegistryInterface
+	info  Implementation
+
+	writeMu sync.Mutex
+	out     io.Writer
+	// running is held for reading by read-only tools and for writing by mutating ones.
+	running sync.RWMutex
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewServer returns a server offering the tools of registry.
+func NewServer(registry shared.ToolRegistryInterface) *Server {
+	return &Server{tools: registry, info: clientInfo, cancels: make(map[string]context.CancelFunc)}
+}
+
+// Serve reads requests from in, one JSON message per line, and writes the responses to out
+// until in ends or ctx is cancelled. It waits for the calls in progress before returning.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.out = out
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var calls sync.WaitGroup
+	defer calls.Wait()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case line := <-lines:
+			var msg message
+			if err := json.Unmarshal(line, &msg); err != nil {
+				s.write(&message{JSONRPC: "2.0", ID: nullID(), Error: &RPCError{Code: codeParseError, Message: err.Error()}})
+				continue
+			}
+			switch {
+			case msg.Method == "notifications/cancelled":
+				s.cancel(msg.Params)
+			case msg.Method == "" || msg.ID == nil:
+				// Other notifications, and responses, need no answer.
+			case msg.Method == "tools/call":
+				callCtx, cancelCall := context.WithCancel(ctx)
+				s.mu.Lock()
+				s.cancels[string(*msg.ID)] = cancelCall
+				s.mu.Unlock()
+				calls.Add(1)
+				go func() {
+					defer calls.Done()
+					defer s.forget(string(*msg.ID))
+					s.respond(&msg, s.callTool(callCtx, msg.Params))
+				}()
+			default:
+				s.respond(&msg, s.handle(&msg))
+			}
+		}
+	}
+}
+
+// nullID is the ID of the answer to a message whose ID could not be read.
+func nullID() *json.RawMessage {
+	raw := json.RawMessage("null")
+	return &raw
+}
+
+// response is the outcome of a request: a result or an error.
+type response struct {
+	result interface{}
+	err    *RPCError
+}
+
+func (s *Server) respond(request *message, resp response) {
+	answer := &message{JSONRPC: "2.0", ID: request.ID, Error: resp.err}
+	if resp.err == nil {
+		data, err := json.Marshal(resp.result)
+		if err != nil {
+			answer.Error = &RPCError{Code: codeInternalError, Message: err.Error()}
+		} else {
+			answer.Result = data
+		}
+	}
+	s.write(answer)
+}
+
+func (s *Server) write(msg *message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+// handle answers the requests other than tools/call.
+func (s *Server) handle(request *message) response {
+	switch request.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return response{err: &RPCError{Code: codeInvalidParams, Message: err.Error()}}
+		}
+		version := ProtocolVersion
+		if supportedVersions[params.ProtocolVersion] {
+			version = params.ProtocolVersion
+		}
+		return response{result: initializeResult{
+			ProtocolVersion: version,
+			Capabilities:    map[string]interface{}{"tools": map[string]interface{}{"listChanged": false}},
+			ServerInfo:      s.info,
+		}}
+	case "ping":
+		return response{result: struct{}{}}
+	case "tools/list":
+		return response{result: s.listTools()}
+	}
+	return response{err: &RPCError{Code: codeMethodNotFound, Message: "method not found: " + request.Method}}
+}
+
+// serverTool is a tool as listed by the server.
+type serverTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations ToolAnnotations        `json:"annotations"`
+}
+
+// listTools lists every tool of the registry, in the order of their names, on one page.
+func (s *Server) listTools() map[string]interface{} {
+	declarations := s.tools.GetFunctionDeclarations()
+	sort.Slice(declarations, func(i, j int) bool {
+		return declarations[i].Name < declarations[j].Name
+	})
+	tools := make([]serverTool, 0, len(declarations))
+	for _, declaration := range declarations {
+		listed := serverTool{
+			Name:        declaration.Name,
+			Description: declaration.Description,
+			InputSchema: ToJSONSchema(declaration.Parameters),
+		}
+		if registered, ok := s.tools.GetTool(declaration.Name); ok {
+			listed.Annotations.ReadOnlyHint = registered.IsReadOnly()
+		}
+		tools = append(tools, listed)
+	}
+	return map[string]interface{}{"tools": tools}
+}
+
+// callTool runs a tool. Failures of the tool are reported in the result, so that the
+// client's model sees them; an unknown tool is a protocol error.
+func (s *Server) callTool(ctx context.Context, rawParams json.RawMessage) response {
+	var params callToolParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return response{err: &RPCError{Code: codeInvalidParams, Message: err.Error()}}
+	}
+	called, ok := s.tools.GetTool(params.Name)
+	if !ok {
+		return response{err: &RPCError{Code: codeInvalidParams, Message: "unknown tool " + params.Name}}
+	}
+	if params.Arguments == nil {
+		params.Arguments = map[string]interface{}{}
+	}
+	if called.IsReadOnly() {
+		s.running.RLock()
+		defer s.running.RUnlock()
+	} else {
+		s.running.Lock()
+		defer s.running.Unlock()
+	}
+
+	output, err := called.Execute(ctx, params.Arguments)
+	if err != nil {
+		return response{result: CallToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}}
+	}
+	return response{result: CallToolResult{Content: []Content{{Type: "text", Text: output}}}}
+}
+
+// cancel cancels the call named by a notifications/cancelled notification.
+func (s *Server) cancel(rawParams json.RawMessage) {
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(rawParams, &params) != nil {
+		return
+	}
+	s.mu.Lock()
+	cancel, ok := s.cancels[string(params.RequestID)]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (s *Server) forget(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
@@ -0,0 +1,152 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/tool"
+)
+
+// serverConn sends lines to a Server and reads its answers.
+type serverConn struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	out  *bufio.Reader
+	done chan error
+}
+
+func startStdioServer(t *testing.T, server *Server) *serverConn {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	conn := &serverConn{t: t, in: inWriter, out: bufio.NewReader(outReader), done: make(chan error, 1)}
+	go func() {
+		conn.done <- server.Serve(context.Background(), inReader, outWriter)
+		outWriter.Close()
+	}()
+	return conn
+}
+
+func (c *serverConn) send(line string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.in, line+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// request sends a request and decodes the result of the answer into result.
+func (c *serverConn) request(id int, method, params string, result interface{}) *RPCError {
+	c.t.Helper()
+	c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`, id, method, params))
+	line, err := c.out.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("failed to read the answer to %s: %v", method, err)
+	}
+	var answer message
+	if err := json.Unmarshal(line, &answer); err != nil {
+		c.t.Fatalf("invalid answer %s: %v", line, err)
+	}
+	if got, _ := parseID(answer.ID); got != int64(id) {
+		c.t.Fatalf("Expected the answer to request %d, got %s", id, line)
+	}
+	if answer.Error != nil {
+		return answer.Error
+	}
+	if err := json.Unmarshal(answer.Result, result); err != nil {
+		c.t.Fatalf("invalid result %s: %v", answer.Result, err)
+	}
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("some notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := tool.NewToolRegistryFromSettings(config.Settings{
+		CoreTools:    []string{"read_file", "write_file", "run_shell_command(echo)"},
+		ExcludeTools: []string{"write_file"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := startStdioServer(t, NewServer(registry))
+
+	var initialized initializeResult
+	if err := conn.request(1, "initialize", `{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}`, &initialized); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if initialized.ProtocolVersion != "2024-11-05" || initialized.ServerInfo.Name != "gemini-cli-go" {
+		t.Errorf("Unexpected initialize result: %+v", initialized)
+	}
+	conn.send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	var listed struct {
+		Tools []struct {
+			Name        string                 `json:"name"`
+			InputSchema map[string]interface{} `json:"inputSchema"`
+			Annotations ToolAnnotations        `json:"annotations"`
+		} `json:"tools"`
+	}
+	if err := conn.request(2, "tools/list", `{}`, &listed); err != nil {
+		t.Fatalf("tools/list failed: %v", err)
+	}
+	var names []string
+	for _, listedTool := range listed.Tools {
+		names = append(names, listedTool.Name)
+	}
+	if strings.Join(names, " ") != "read_file run_shell_command" {
+		t.Fatalf("Expected only the allowed tools, got %v", names)
+	}
+	readFile := listed.Tools[0]
+	if !readFile.Annotations.ReadOnlyHint || listed.Tools[1].Annotations.ReadOnlyHint {
+		t.Errorf("Expected only read_file to be read-only: %+v", listed.Tools)
+	}
+	if readFile.InputSchema["type"] != "object" || fmt.Sprint(readFile.InputSchema["required"]) != "[path]" {
+		t.Errorf("Unexpected input schema: %v", readFile.InputSchema)
+	}
+
+	for i, tt := range []struct {
+		params    string
+		want      string
+		wantError bool
+	}{
+		{fmt.Sprintf(`{"name":"read_file","arguments":{"path":%q}}`, path), "some notes", false},
+		{`{"name":"read_file","arguments":{}}`, "missing or invalid 'path'", true},
+		{`{"name":"run_shell_command","arguments":{"command":"rm -rf /"}}`, "not allowed by the coreTools setting", true},
+		{`{"name":"run_shell_command","arguments":{"command":"echo from the shell"}}`, "from the shell", false},
+	} {
+		var result CallToolResult
+		if err := conn.request(10+i, "tools/call", tt.params, &result); err != nil {
+			t.Fatalf("tools/call %s failed: %v", tt.params, err)
+		}
+		if got := FormatContent(result.Content); !strings.Contains(got, tt.want) || result.IsError != tt.wantError {
+			t.Errorf("tools/call %s = %q (error: %v), want %q (error: %v)", tt.params, got, result.IsError, tt.want, tt.wantError)
+		}
+	}
+
+	var result CallToolResult
+	if err := conn.request(20, "tools/call", `{"name":"write_file","arguments":{}}`, &result); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("Expected an error for an excluded tool, got %v", err)
+	}
+	if err := conn.request(21, "resources/list", `{}`, &result); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("Expected method not found, got %v", err)
+	}
+	var pong struct{}
+	if err := conn.request(22, "ping", `{}`, &pong); err != nil {
+		t.Errorf("ping failed: %v", err)
+	}
+
+	conn.in.Close()
+	if err := <-conn.done; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
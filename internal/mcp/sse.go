@@ -0,0 +1,380 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sseEvent is an event read from a text/event-stream body.
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// sseReader reads server-sent events.
+type sseReader struct {
+	reader *bufio.Reader
+}
+
+func newSSEReader(body io.Reader) *sseReader {
+	return &sseReader{reader: bufio.NewReader(body)}
+}
+
+// next returns the next event with data. The event type defaults to "message".
+func (r *sseReader) next() (sseEvent, error) {
+	var event sseEvent
+	var data []string
+	for {
+		line, err := r.reader.ReadString('\n')
+		if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			return sseEvent{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if len(data) > 0 {
+				event.Data = strings.Join(data, "\n")
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				return event, nil
+			}
+			event, data = sseEvent{}, nil
+			if err != nil {
+				return sseEvent{}, err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			event.ID = value
+		}
+	}
+}
+
+// setHeaders sets the configured headers on an HTTP request.
+func setHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// statusError describes an HTTP response with an unexpected status.
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if text := strings.TrimSpace(string(body)); text != "" {
+		return fmt.Errorf("unexpected HTTP status %s: %s", resp.Status, text)
+	}
+	return fmt.Errorf("unexpected HTTP status %s", resp.Status)
+}
+
+// reconnectAttempts and reconnectDelay bound how a lost connection is reestablished:
+// the delay doubles after each failed attempt.
+var (
+	reconnectAttempts = 3
+	reconnectDelay    = 500 * time.Millisecond
+)
+
+// sleepContext waits for d, or until ctx ends.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sseTransport speaks the HTTP with SSE transport of MCP revision 2024-11-05: the server
+// sends messages as events on a stream the client opens with GET, and the first event
+// names the endpoint the client POSTs its messages to. The session lasts as long as the
+// stream; when it is lost, the next request opens a new one and returns ErrSessionExpired.
+type sseTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	calls   pendingCalls
+
+	// connectMu makes concurrent requests reconnect once.
+	connectMu sync.Mutex
+
+	mu       sync.Mutex
+	endpoint string
+	// generation counts the streams opened, so that the end of a replaced stream is ignored.
+	generation int
+	stop       context.CancelFunc
+	closed     bool
+}
+
+// startSSE opens the event stream at serverURL.
+func startSSE(ctx context.Context, serverURL string, headers map[string]string) (*sseTransport, error) {
+	t := &sseTransport{url: serverURL, headers: headers, client: http.DefaultClient}
+	if err := t.connect(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// connect opens a new event stream and waits for its endpoint event. Requests pending on
+// the previous stream fail, since their responses will not arrive.
+func (t *sseTransport) connect(ctx context.Context) error {
+	// The stream outlives ctx, which only bounds the wait for the endpoint.
+	streamCtx, stop := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, t.url, nil)
+	if err != nil {
+		stop()
+		return err
+	}
+	setHeaders(req, t.headers)
+	req.Header.Set("Accept", "text/event-stream")
+
+	stopWaiting := context.AfterFunc(ctx, stop)
+	defer stopWaiting()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		stop()
+		return fmt.Errorf("failed to open the event stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		stop()
+		return statusError(resp)
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		resp.Body.Close()
+		stop()
+		return errors.New("the connection is closed")
+	}
+	if t.stop != nil {
+		t.stop()
+	}
+	t.generation++
+	t.stop = stop
+	generation := t.generation
+	t.mu.Unlock()
+	t.calls.fail(errors.New("the connection to the server was lost"))
+	t.calls.reset()
+
+	endpoints := make(chan string, 1)
+	go t.readLoop(resp.Body, generation, endpoints)
+	endpoint, ok := <-endpoints
+	if !ok {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errors.New("the event stream ended before the endpoint event")
+	}
+	resolved, err := resolveEndpoint(t.url, endpoint)
+	if err != nil {
+		stop()
+		return err
+	}
+	t.mu.Lock()
+	t.endpoint = resolved
+	t.mu.Unlock()
+	return nil
+}
+
+// resolveEndpoint resolves the endpoint sent by the server against the stream's URL.
+func resolveEndpoint(streamURL, endpoint string) (string, error) {
+	base, err := url.Parse(streamURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// readLoop dispatches the events of a stream until it ends. The endpoint is sent on
+// endpoints, which is closed if the stream ends without one.
+func (t *sseTransport) readLoop(body io.ReadCloser, generation int, endpoints chan<- string) {
+	defer body.Close()
+	reader := newSSEReader(body)
+	sentEndpoint := false
+	for {
+		event, err := reader.next()
+		if err != nil {
+			break
+		}
+		switch event.Event {
+		case "endpoint":
+			if !sentEndpoint {
+				endpoints <- event.Data
+				sentEndpoint = true
+			}
+		case "message":
+			var msg message
+			if err := json.Unmarshal([]byte(event.Data), &msg); err != nil {
+				continue
+			}
+			switch {
+			case msg.Method != "" && msg.ID != nil:
+				go t.post(context.Background(), answerServerRequest(&msg))
+			case msg.Method != "":
+				// Notifications are not acted on.
+			default:
+				t.calls.deliver(&msg)
+			}
+		}
+	}
+	if !sentEndpoint {
+		close(endpoints)
+	}
+
+	t.mu.Lock()
+	current := generation == t.generation
+	t.mu.Unlock()
+	if current {
+		t.calls.fail(errors.New("the server closed the event stream"))
+	}
+}
+
+// ensureConnected opens a new stream if the current one was lost. Since the server's
+// session ended with the stream, it returns ErrSessionExpired once reconnected.
+func (t *sseTransport) ensureConnected(ctx context.Context) error {
+	if t.calls.failed() == nil {
+		return nil
+	}
+	t.connectMu.Lock()
+	defer t.connectMu.Unlock()
+	lost := t.calls.failed()
+	if lost == nil {
+		// Another request reconnected.
+		return ErrSessionExpired
+	}
+	t.mu.Lock()
+	closed := t.closed
+	t.mu.Unlock()
+	if closed {
+		return lost
+	}
+
+	var err error
+	delay := reconnectDelay
+	for attempt := 0; attempt < reconnectAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
+			delay *= 2
+		}
+		if err = t.connect(ctx); err == nil {
+			return ErrSessionExpired
+		}
+	}
+	return fmt.Errorf("failed to reconnect: %w", err)
+}
+
+// post sends msg to the endpoint. A 404 means the server no longer knows the session,
+// so the stream is dropped and ErrSessionExpired returned.
+func (t *sseTransport) post(ctx context.Context, msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	endpoint, generation := t.endpoint, t.generation
+	t.mu.Unlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	setHeaders(req, t.headers)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send to the server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		t.mu.Lock()
+		if generation == t.generation {
+			t.stop()
+		}
+		t.mu.Unlock()
+		t.calls.fail(ErrSessionExpired)
+		return ErrSessionExpired
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (t *sseTransport) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	if err := t.ensureConnected(ctx); err != nil {
+		return nil, err
+	}
+	id, ch, err := t.calls.add()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := newMessage(requestID(id), method, params)
+	if err == nil {
+		err = t.post(ctx, msg)
+	}
+	if err != nil {
+		t.calls.remove(id)
+		return nil, err
+	}
+
+	result, err := t.calls.wait(ctx, id, ch)
+	if err != nil && err == ctx.Err() {
+		cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+		defer cancel()
+		t.post(cancelCtx, cancelNotification(id, err))
+	}
+	return result, err
+}
+
+func (t *sseTransport) Notify(ctx context.Context, method string, params interface{}) error {
+	if err := t.ensureConnected(ctx); err != nil {
+		return err
+	}
+	msg, err := newMessage(nil, method, params)
+	if err != nil {
+		return err
+	}
+	return t.post(ctx, msg)
+}
+
+// Close ends the event stream, which ends the session.
+func (t *sseTransport) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	t.generation++
+	if t.stop != nil {
+		t.stop()
+	}
+	t.mu.Unlock()
+	t.calls.fail(errors.New("the connection is closed"))
+	return nil
+}
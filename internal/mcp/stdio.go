@@ -0,0 +1,175 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"gemini-cli-go/internal/process"
+)
+
+// closeGracePeriod is how long a server process may take to exit after its stdin is closed
+// before it is killed.
+const closeGracePeriod = 2 * time.Second
+
+// stdioTransport talks to a server process over its stdin and stdout, one JSON message per line.
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	calls   pendingCalls
+
+	// exited is closed once the process has exited.
+	exited chan struct{}
+}
+
+// startStdio starts command and connects to it. The server's stderr is copied to stderr,
+// or discarded if it is nil.
+func startStdio(command string, args []string, env map[string]string, dir string, stderr io.Writer) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+env[key])
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	cmd.Stderr = stderr
+	// Children of the server are stopped with it, and Ctrl-C in the terminal does not reach them.
+	process.SetGroup(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command, err)
+	}
+
+	t := &stdioTransport{
+		cmd:    cmd,
+		stdin:  stdin,
+		exited: make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+// readLoop reads the server's messages until its stdout is closed, then waits for the process.
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	var readErr error
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			t.handleLine(line)
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+
+	waitErr := t.cmd.Wait()
+	err := errors.New("the server closed the connection")
+	if waitErr != nil {
+		err = fmt.Errorf("the server exited: %w", waitErr)
+	} else if !errors.Is(readErr, io.EOF) {
+		err = fmt.Errorf("failed to read from the server: %w", readErr)
+	}
+	t.calls.fail(err)
+	close(t.exited)
+}
+
+// handleLine dispatches a message read from the server. Lines that are not JSON-RPC
+// messages, such as stray log output, are ignored.
+func (t *stdioTransport) handleLine(line []byte) {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return
+	}
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		t.write(answerServerRequest(&msg))
+	case msg.Method != "":
+		// Notifications such as log messages or list changes are not acted on.
+	default:
+		t.calls.deliver(&msg)
+	}
+}
+
+// write sends msg as one line.
+func (t *stdioTransport) write(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to the server: %w", err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	id, ch, err := t.calls.add()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := newMessage(requestID(id), method, params)
+	if err == nil {
+		err = t.write(msg)
+	}
+	if err != nil {
+		t.calls.remove(id)
+		return nil, err
+	}
+
+	result, err := t.calls.wait(ctx, id, ch)
+	if err != nil && err == ctx.Err() {
+		t.write(cancelNotification(id, ctx.Err()))
+	}
+	return result, err
+}
+
+func (t *stdioTransport) Notify(_ context.Context, method string, params interface{}) error {
+	msg, err := newMessage(nil, method, params)
+	if err != nil {
+		return err
+	}
+	return t.write(msg)
+}
+
+// Close closes the server's stdin, which asks it to exit, and kills its process group if
+// it does not. Processes the server left behind are killed either way.
+func (t *stdioTransport) Close() error {
+	t.stdin.Close()
+	select {
+	case <-t.exited:
+		process.KillGroup(t.cmd)
+	case <-time.After(closeGracePeriod):
+		process.KillGroup(t.cmd)
+		<-t.exited
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdioCloseKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	// The background child keeps stdout open after the server itself exits.
+	transport, err := startStdio("/bin/sh", []string{"-c", "sleep 30 & echo $! > " + pidFile + "; exec cat > /dev/null"}, nil, "", nil)
+	if err != nil {
+		t.Fatalf("startStdio failed: %v", err)
+	}
+	var pid []byte
+	for deadline := time.Now().Add(5 * time.Second); len(pid) == 0 || pid[len(pid)-1] != '\n'; {
+		if time.Now().After(deadline) {
+			t.Fatal("The server did not start its child")
+		}
+		time.Sleep(10 * time.Millisecond)
+		pid, _ = os.ReadFile(pidFile)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		transport.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(closeGracePeriod + 5*time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	// Give init a moment to reap the killed child.
+	time.Sleep(100 * time.Millisecond)
+	status, err := os.ReadFile(filepath.Join("/proc", strings.TrimSpace(string(pid)), "status"))
+	if err == nil && !strings.Contains(string(status), "zombie") {
+		t.Errorf("Expected background child %s to be killed", strings.TrimSpace(string(pid)))
+	}
+}
@@ -0,0 +1,138 @@
+// Command fakeserver is a minimal MCP server speaking over stdio, used by the tests of package mcp.
+// It exits at once with status 1 if FAKE_EXIT is set, and never answers if FAKE_HANG is set.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   interface{}     `json:"error,omitempty"`
+}
+
+var writeMu sync.Mutex
+
+func send(msg message) {
+	msg.JSONRPC = "2.0"
+	data, _ := json.Marshal(msg)
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	os.Stdout.Write(append(data, '\n'))
+}
+
+func text(s string) map[string]interface{} {
+	return map[string]interface{}{"content": []interface{}{map[string]interface{}{"type": "text", "text": s}}}
+}
+
+var pages = map[string]interface{}{
+	"": map[string]interface{}{
+		"tools": []interface{}{
+			map[string]interface{}{
+				"name":        "echo",
+				"description": "Echoes its text.",
+				"inputSchema": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}},
+					"required":   []string{"text"},
+				},
+				"annotations": map[string]interface{}{"readOnlyHint": true},
+			},
+			map[string]interface{}{"name": "fail", "inputSchema": map[string]interface{}{"type": "object"}},
+		},
+		"nextCursor": "page2",
+	},
+	"page2": map[string]interface{}{
+		"tools": []interface{}{
+			map[string]interface{}{"name": "env", "inputSchema": map[string]interface{}{"type": "object"}},
+			map[string]interface{}{"name": "cwd", "inputSchema": map[string]interface{}{"type": "object"}},
+			map[string]interface{}{"name": "slow", "inputSchema": map[string]interface{}{"type": "object"}},
+		},
+	},
+}
+
+func main() {
+	if os.Getenv("FAKE_EXIT") != "" {
+		fmt.Fprintln(os.Stderr, "fake server refusing to start")
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "fake server started")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if os.Getenv("FAKE_HANG") != "" {
+			continue
+		}
+		if msg.ID == nil {
+			if msg.Method == "notifications/initialized" {
+				// Exercise the client's handling of server requests and notifications.
+				send(message{Method: "notifications/message", Params: json.RawMessage(`{"level":"info","data":"ready"}`)})
+				send(message{ID: json.RawMessage(`"ping-1"`), Method: "ping"})
+			}
+			continue
+		}
+		if msg.Method == "" {
+			// The client's answer to the ping.
+			continue
+		}
+		go handle(msg)
+	}
+}
+
+func handle(msg message) {
+	response := message{ID: msg.ID}
+	switch msg.Method {
+	case "initialize":
+		response.Result = map[string]interface{}{
+			"protocolVersion": "2025-03-26",
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "fake", "version": "1.0"},
+		}
+	case "tools/list":
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		response.Result = pages[params.Cursor]
+	case "tools/call":
+		var params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		switch params.Name {
+		case "echo":
+			response.Result = text(fmt.Sprint(params.Arguments["text"]))
+		case "fail":
+			result := text("something broke")
+			result["isError"] = true
+			response.Result = result
+		case "env":
+			response.Result = text(os.Getenv("FAKE_VAR"))
+		case "cwd":
+			dir, _ := os.Getwd()
+			response.Result = text(dir)
+		case "slow":
+			time.Sleep(5 * time.Second)
+			response.Result = text("done")
+		default:
+			response.Error = map[string]interface{}{"code": -32602, "message": "unknown tool " + params.Name}
+		}
+	default:
+		response.Error = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	send(response)
+}
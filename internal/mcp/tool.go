@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gemini-cli-go/internal/shared"
+)
+
+// maxToolNameLength is the longest function name the API accepts.
+const maxToolNameLength = 64
+
+// ToolName returns the name a server's tool is declared to the model with: the server
+// name and the tool name joined by "__", with characters the API does not accept in
+// function names replaced by "_". Names that are too long keep their start and end.
+func ToolName(server, tool string) string {
+	name := []rune(sanitizeName(server) + "__" + sanitizeName(tool))
+	if len(name) > maxToolNameLength {
+		name = append(append(name[:28:28], []rune("___")...), name[len(name)-(maxToolNameLength-31):]...)
+	}
+	return string(name)
+}
+
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Tool offers a server's tool to the model.
+type Tool struct {
+	client *Client
+	info   ToolInfo
+	name   string
+	schema shared.Schema
+	trust  bool
+}
+
+// NewTool adapts the tool described by info. Its calls run without confirmation if trust is set.
+func NewTool(client *Client, info ToolInfo, trust bool) (*Tool, error) {
+	schema, err := ConvertSchema(info.InputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", info.Name, err)
+	}
+	return &Tool{
+		client: client,
+		info:   info,
+		name:   ToolName(client.Name, info.Name),
+		schema: schema,
+		trust:  trust,
+	}, nil
+}
+
+func (t *Tool) Name() string {
+	return t.name
+}
+
+// Description is the server's description of the tool, naming the server it comes from.
+func (t *Tool) Description() string {
+	description := strings.TrimSpace(t.info.Description)
+	if description == "" {
+		description = t.info.Name
+	}
+	return fmt.Sprintf("%s (from MCP server %s)", description, t.client.Name)
+}
+
+func (t *Tool) FunctionDeclaration() shared.FunctionDeclaration {
+	return shared.FunctionDeclaration{
+		Name:        t.name,
+		Description: t.Description(),
+		Parameters:  t.schema,
+	}
+}
+
+// IsReadOnly honors the server's readOnlyHint annotation only for a trusted server: a
+// read-only tool runs without confirmation, and the hint is the server's own claim.
+func (t *Tool) IsReadOnly() bool {
+	return t.trust && t.info.Annotations != nil && t.info.Annotations.ReadOnlyHint
+}
+
+// IsTrusted reports whether the server is trusted by the settings.
+func (t *Tool) IsTrusted() bool {
+	return t.trust
+}
+
+// Execute calls the tool on the server and returns its text content. A result the
+// server marks as an error is returned as an error.
+func (t *Tool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	result, err := t.client.CallTool(ctx, t.info.Name, args)
+	if err != nil {
+		return "", err
+	}
+	output := FormatContent(result.Content)
+	if result.IsError {
+		if output == "" {
+			output = "the tool reported an error"
+		}
+		return "", errors.New(output)
+	}
+	return output, nil
+}
+
+// FormatContent joins the items of a tool result. Text is kept as is; other items are
+// described, since only text is passed back to the model.
+func FormatContent(content []Content) string {
+	parts := make([]string, 0, len(content))
+	for _, item := range content {
+		switch {
+		case item.Type == "text":
+			parts = append(parts, item.Text)
+		case item.Type == "resource" && item.Resource != nil && item.Resource.Text != "":
+			parts = append(parts, item.Resource.Text)
+		case item.Type == "resource" && item.Resource != nil:
+			parts = append(parts, fmt.Sprintf("[resource %s]", item.Resource.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content: %s]", item.Type, item.MimeType))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToolName(t *testing.T) {
+	tests := []struct {
+		server, tool, want string
+	}{
+		{"github", "create_issue", "github__create_issue"},
+		{"my server", "get/item:v2", "my_server__get_item_v2"},
+		{"docs", strings.Repeat("a", 30) + strings.Repeat("b", 40), "docs__" + strings.Repeat("a", 22) + "___" + strings.Repeat("b", 33)},
+	}
+	for _, tt := range tests {
+		got := ToolName(tt.server, tt.tool)
+		if got != tt.want {
+			t.Errorf("ToolName(%q, %q) = %q, want %q", tt.server, tt.tool, got, tt.want)
+		}
+		if len(got) > maxToolNameLength {
+			t.Errorf("ToolName(%q, %q) is %d characters long", tt.server, tt.tool, len(got))
+		}
+	}
+}
+
+func TestFormatContent(t *testing.T) {
+	content := []Content{
+		{Type: "text", Text: "first"},
+		{Type: "image", Data: "iVBORw0", MimeType: "image/png"},
+		{Type: "resource", Resource: &ResourceContent{URI: "file:///a.txt", Text: "embedded"}},
+		{Type: "resource", Resource: &ResourceContent{URI: "file:///b.bin"}},
+	}
+	want := "first\n[image content: image/png]\nembedded\n[resource file:///b.bin]"
+	if got := FormatContent(content); got != want {
+		t.Errorf("FormatContent() = %q, want %q", got, want)
+	}
+}
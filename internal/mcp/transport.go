@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transport carries JSON-RPC messages between the client and a server.
+type Transport interface {
+	// Call sends a request and returns the result of its response.
+	// An error response is returned as an *RPCError.
+	Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error)
+	// Notify sends a notification, which has no response.
+	Notify(ctx context.Context, method string, params interface{}) error
+	// Close disconnects from the server.
+	Close() error
+}
+
+// ErrSessionExpired is returned by a transport for a request the server did not process
+// because its session ended. The transport starts a new session, in which the client
+// must perform the handshake again before retrying the request.
+var ErrSessionExpired = errors.New("the server's session expired")
+
+// cancelTimeout bounds the sending of a cancellation after a request timed out, for
+// transports where sending can block.
+const cancelTimeout = 5 * time.Second
+
+// pendingCalls tracks the requests waiting for a response on a connection where
+// responses arrive independently of the requests, such as stdio.
+type pendingCalls struct {
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	// err is set when the connection is lost; calls fail with it until reset.
+	err error
+}
+
+// add registers a new request and returns its ID and the channel its response is delivered to.
+func (p *pendingCalls) add() (int64, chan *message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return 0, nil, p.err
+	}
+	if p.pending == nil {
+		p.pending = make(map[int64]chan *message)
+	}
+	p.nextID++
+	ch := make(chan *message, 1)
+	p.pending[p.nextID] = ch
+	return p.nextID, ch, nil
+}
+
+// remove forgets a request whose response is no longer waited for.
+func (p *pendingCalls) remove(id int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, id)
+}
+
+// deliver passes a response to the request it answers. Responses nobody waits for are dropped.
+func (p *pendingCalls) deliver(response *message) {
+	id, ok := parseID(response.ID)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	ch, ok := p.pending[id]
+	delete(p.pending, id)
+	p.mu.Unlock()
+	if ok {
+		ch <- response
+	}
+}
+
+// fail ends the pending requests with err and makes new ones fail with it.
+func (p *pendingCalls) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	for id, ch := range p.pending {
+		close(ch)
+		delete(p.pending, id)
+	}
+}
+
+// failed returns the error the connection was lost with, if it was.
+func (p *pendingCalls) failed() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// reset accepts new requests again after the connection was reestablished.
+func (p *pendingCalls) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = nil
+}
+
+// wait waits for the response delivered to ch. If ctx ends first, the request is forgotten
+// and ctx's error returned.
+func (p *pendingCalls) wait(ctx context.Context, id int64, ch chan *message) (json.RawMessage, error) {
+	select {
+	case response, ok := <-ch:
+		if !ok {
+			return nil, p.failed()
+		}
+		if response.Error != nil {
+			return nil, response.Error
+		}
+		return response.Result, nil
+	case <-ctx.Done():
+		p.remove(id)
+		return nil, ctx.Err()
+	}
+}
+
+// parseID returns the numeric ID of a message. The client only sends numeric IDs.
+func parseID(raw *json.RawMessage) (int64, bool) {
+	if raw == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(string(*raw), 10, 64)
+	return id, err == nil
+}
+
+// requestID encodes id as the ID of a message.
+func requestID(id int64) *json.RawMessage {
+	raw := json.RawMessage(strconv.FormatInt(id, 10))
+	return &raw
+}
+
+// newMessage builds a request or, if id is nil, a notification.
+func newMessage(id *json.RawMessage, method string, params interface{}) (*message, error) {
+	msg := &message{JSONRPC: "2.0", ID: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s parameters: %w", method, err)
+		}
+		msg.Params = data
+	}
+	return msg, nil
+}
+
+// cancelNotification tells the server to stop working on the request with the given ID.
+func cancelNotification(id int64, reason error) *message {
+	msg, _ := newMessage(nil, "notifications/cancelled", map[string]interface{}{"requestId": id, "reason": reason.Error()})
+	return msg
+}
+
+// answerServerRequest answers a request sent by the server. Only ping is supported.
+func answerServerRequest(request *message) *message {
+	response := &message{JSONRPC: "2.0", ID: request.ID}
+	if request.Method == "ping" {
+		response.Result = json.RawMessage("{}")
+	} else {
+		response.Error = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + request.Method}
+	}
+	return response
+}
@@ -0,0 +1,192 @@
+// Package memory loads the instruction files (GEMINI.md by default) that are sent
+// to the model as the system instruction.
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gemini-cli-go/internal/filesystem"
+)
+
+// DefaultFileName is the name of instruction files when the contextFileName setting is not set.
+const DefaultFileName = "GEMINI.md"
+
+// maxScannedFiles bounds the search of subdirectories, so that starting in a large
+// directory such as the home directory does not walk the whole tree.
+const maxScannedFiles = 20000
+
+// Source says where an instruction file was found.
+type Source string
+
+const (
+	// SourceGlobal is the user's global directory, ~/.gemini.
+	SourceGlobal Source = "global"
+	// SourceProject is the working directory or one of its ancestors up to the project root.
+	SourceProject Source = "project"
+	// SourceSubdirectory is a directory below the working directory.
+	SourceSubdirectory Source = "subdirectory"
+)
+
+// File is a loaded instruction file.
+type File struct {
+	Path    string
+	Source  Source
+	Content string
+}
+
+// Memory is the instruction files loaded for a session, from the most general to the most specific.
+type Memory struct {
+	Files []File
+}
+
+// Options says where instruction files are looked for.
+type Options struct {
+	// WorkingDir is the directory the CLI runs in. Its ancestors up to the project root,
+	// the closest one containing .git, are searched, and so are its subdirectories.
+	WorkingDir string
+	// GlobalDir is the user's global settings directory, usually ~/.gemini. It is skipped if empty.
+	GlobalDir string
+	// FileNames are the names of instruction files. Empty selects DefaultFileName.
+	FileNames []string
+	// Walk selects the subdirectories that are searched, see filesystem.Walk.
+	Walk filesystem.WalkOptions
+}
+
+// Load finds and reads the instruction files: the global ones first, then those of the
+// project root down to the working directory, then those of its subdirectories in
+// lexical order. A file reachable in more than one way is loaded once, and empty files are left out.
+func Load(opts Options) (*Memory, error) {
+	names := opts.FileNames
+	if len(names) == 0 {
+		names = []string{DefaultFileName}
+	}
+	workingDir, err := filepath.Abs(opts.WorkingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	loader := &loader{names: names, seen: make(map[string]bool), memory: &Memory{}}
+	if opts.GlobalDir != "" {
+		if err := loader.loadDir(opts.GlobalDir, SourceGlobal); err != nil {
+			return nil, err
+		}
+	}
+	for _, dir := range projectDirs(workingDir) {
+		if err := loader.loadDir(dir, SourceProject); err != nil {
+			return nil, err
+		}
+	}
+
+	isName := make(map[string]bool, len(names))
+	for _, name := range names {
+		isName[name] = true
+	}
+	scanned := 0
+	err = filesystem.Walk(workingDir, opts.Walk, func(entry filesystem.Entry) error {
+		if scanned++; scanned > maxScannedFiles {
+			return fs.SkipAll
+		}
+		if !isName[filepath.Base(entry.Path)] || filepath.Dir(entry.Path) == workingDir {
+			return nil
+		}
+		return loader.loadFile(entry.Path, SourceSubdirectory)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loader.memory, nil
+}
+
+// projectDirs returns the directories from the project root down to dir. Without a
+// project root, that is dir alone.
+func projectDirs(dir string) []string {
+	dirs := []string{dir}
+	for current := dir; ; {
+		if _, err := os.Lstat(filepath.Join(current, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return []string{dir}
+		}
+		current = parent
+		dirs = append(dirs, current)
+	}
+	for i, j := 0, len(dirs)-1; i < j; i, j = i+1, j-1 {
+		dirs[i], dirs[j] = dirs[j], dirs[i]
+	}
+	return dirs
+}
+
+type loader struct {
+	names  []string
+	seen   map[string]bool
+	memory *Memory
+}
+
+// loadDir loads the instruction files in dir, in the order of the configured names.
+func (l *loader) loadDir(dir string, source Source) error {
+	for _, name := range l.names {
+		if err := l.loadFile(filepath.Join(dir, name), source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFile loads the file at path unless it was loaded before. A missing file is not an error.
+func (l *loader) loadFile(path string, source Source) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if l.seen[absPath] {
+		return nil
+	}
+	content, err := os.ReadFile(absPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read instruction file %s: %w", absPath, err)
+	}
+	l.seen[absPath] = true
+	if strings.TrimSpace(string(content)) == "" {
+		return nil
+	}
+	l.memory.Files = append(l.memory.Files, File{Path: absPath, Source: source, Content: string(content)})
+	return nil
+}
+
+// Text concatenates the loaded files, each between markers naming its source,
+// for use as the system instruction. It returns "" if nothing was loaded.
+func (m *Memory) Text() string {
+	if m == nil {
+		return ""
+	}
+	var builder strings.Builder
+	for _, file := range m.Files {
+		fmt.Fprintf(&builder, "--- Context from: %s (%s) ---\n", file.Path, file.Source)
+		builder.WriteString(strings.TrimRight(file.Content, "\n"))
+		fmt.Fprintf(&builder, "\n--- End of Context from: %s ---\n\n", file.Path)
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
+
+// Summary lists the loaded files, one per line with their source and size.
+func (m *Memory) Summary() string {
+	if m == nil || len(m.Files) == 0 {
+		return "No memory loaded."
+	}
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Loaded %d instruction files:\n", len(m.Files))
+	for _, file := range m.Files {
+		fmt.Fprintf(&builder, "- %s (%s, %d bytes)\n", file.Path, file.Source, len(file.Content))
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
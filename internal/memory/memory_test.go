@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"home/.gemini/GEMINI.md":                  "global rules",
+		"outside/GEMINI.md":                       "above the project root",
+		"outside/repo/.git/HEAD":                  "ref: refs/heads/main",
+		"outside/repo/GEMINI.md":                  "project rules",
+		"outside/repo/app/GEMINI.md":              "app rules",
+		"outside/repo/app/AGENTS.md":              "agent rules",
+		"outside/repo/app/web/GEMINI.md":          "web rules",
+		"outside/repo/app/empty/GEMINI.md":        "  \n",
+		"outside/repo/app/node_modules/GEMINI.md": "dependency rules",
+		"outside/repo/other/GEMINI.md":            "sibling rules",
+	})
+	repo := filepath.Join(root, "outside", "repo")
+
+	mem, err := Load(Options{
+		WorkingDir: filepath.Join(repo, "app"),
+		GlobalDir:  filepath.Join(root, "home", ".gemini"),
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var got []string
+	for _, file := range mem.Files {
+		rel, _ := filepath.Rel(root, file.Path)
+		got = append(got, string(file.Source)+":"+filepath.ToSlash(rel))
+	}
+	want := []string{
+		"global:home/.gemini/GEMINI.md",
+		"project:outside/repo/GEMINI.md",
+		"project:outside/repo/app/GEMINI.md",
+		"subdirectory:outside/repo/app/web/GEMINI.md",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Load() loaded\n%v\nwant\n%v", got, want)
+	}
+
+	text := mem.Text()
+	for _, want := range []string{
+		"--- Context from: " + filepath.Join(root, "home/.gemini/GEMINI.md") + " (global) ---\nglobal rules\n--- End of Context from: ",
+		"web rules",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in the text, got:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "global rules") > strings.Index(text, "project rules") {
+		t.Error("Expected global instructions before project instructions")
+	}
+
+	// Several file names are loaded in order, and a file is loaded once even if the
+	// working directory is the global directory.
+	mem, err = Load(Options{
+		WorkingDir: filepath.Join(repo, "app"),
+		GlobalDir:  filepath.Join(repo, "app"),
+		FileNames:  []string{"AGENTS.md", "GEMINI.md"},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(mem.Files) != 4 || mem.Files[0].Content != "agent rules" || mem.Files[1].Content != "app rules" || mem.Files[1].Source != SourceGlobal {
+		t.Errorf("Unexpected files: %+v", mem.Files)
+	}
+}
+
+func TestLoadWithoutProjectRoot(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"GEMINI.md":     "parent rules",
+		"dir/GEMINI.md": "dir rules",
+	})
+
+	// Without a .git directory, ancestors of the working directory are not searched.
+	mem, err := Load(Options{WorkingDir: filepath.Join(root, "dir")})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(mem.Files) != 1 || mem.Files[0].Content != "dir rules" {
+		t.Errorf("Expected only dir/GEMINI.md, got %+v", mem.Files)
+	}
+}
+
+func TestMemorySummary(t *testing.T) {
+	var empty *Memory
+	if empty.Summary() != "No memory loaded." || empty.Text() != "" {
+		t.Errorf("Unexpected output for no memory: %q, %q", empty.Summary(), empty.Text())
+	}
+
+	mem := &Memory{Files: []File{
+		{Path: "/p/GEMINI.md", Source: SourceProject, Content: "be brief\n"},
+		{Path: "/p/sub/GEMINI.md", Source: SourceSubdirectory, Content: "use tabs"},
+	}}
+	want := "Loaded 2 instruction files:\n- /p/GEMINI.md (project, 9 bytes)\n- /p/sub/GEMINI.md (subdirectory, 8 bytes)"
+	if got := mem.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	wantText := "--- Context from: /p/GEMINI.md (project) ---\nbe brief\n--- End of Context from: /p/GEMINI.md ---\n\n" +
+		"--- Context from: /p/sub/GEMINI.md (subdirectory) ---\nuse tabs\n--- End of Context from: /p/sub/GEMINI.md ---"
+	if got := mem.Text(); got != wantText {
+		t.Errorf("Text() = %q, want %q", got, wantText)
+	}
+}
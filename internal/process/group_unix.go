@@ -0,0 +1,27 @@
+//go:build !windows
+
+package process
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs command with /bin/sh.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+// SetGroup starts the command in a new process group.
+func SetGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// KillGroup kills the command and every process it started.
+func KillGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package process
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+)
+
+// shellCommand runs command with cmd.exe.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
+
+// SetGroup is a no-op on Windows; KillGroup kills the process tree instead.
+func SetGroup(cmd *exec.Cmd) {}
+
+// KillGroup kills the command and every process it started.
+func KillGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
@@ -0,0 +1,22 @@
+// Package process starts commands in their own process group, so that stopping a command
+// also stops the processes it started.
+package process
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// Shell runs command with the system shell in its own process group, so that cancelling
+// ctx kills the command together with any background children.
+func Shell(ctx context.Context, command string) *exec.Cmd {
+	cmd := shellCommand(ctx, command)
+	SetGroup(cmd)
+	cmd.Cancel = func() error {
+		return KillGroup(cmd)
+	}
+	// Stop waiting for output once the group is killed, even if a stray process keeps the pipes open.
+	cmd.WaitDelay = time.Second
+	return cmd
+}
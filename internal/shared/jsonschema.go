@@ -0,0 +1,199 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// JSONSchemaOptions controls how ParseJSONSchema treats what a Schema cannot express.
+type JSONSchemaOptions struct {
+	// Lenient approximates such schemas instead of rejecting them:
+	//   - a type list such as ["string", "null"] becomes the first non-null type, nullable;
+	//   - anyOf and oneOf become their first non-null alternative;
+	//   - a missing type is "object" if properties are given and "string" otherwise,
+	//     and arrays without items hold strings;
+	//   - required names of undefined properties and non-string enums are dropped.
+	Lenient bool
+}
+
+// jsonSchemaTypes are the JSON Schema types a Schema can have.
+var jsonSchemaTypes = map[string]Type{
+	"string":  TypeString,
+	"number":  TypeNumber,
+	"integer": TypeInteger,
+	"boolean": TypeBoolean,
+	"array":   TypeArray,
+	"object":  TypeObject,
+}
+
+// ParseJSONSchema converts a JSON Schema into a Schema. Only the keywords a Schema has are
+// used (type, properties, required, items, enum, nullable, description); others are ignored.
+// Unless options.Lenient is set, every schema must have one of the types, arrays must have
+// items, enums must be lists of strings on strings, and required properties must be defined.
+// Errors name the offending schema by its path, e.g. "$.steps[]".
+func ParseJSONSchema(data []byte, options JSONSchemaOptions) (Schema, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Schema{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return convertJSONSchema("$", raw, options.Lenient)
+}
+
+func convertJSONSchema(path string, raw map[string]interface{}, lenient bool) (Schema, error) {
+	schema := Schema{}
+	schema.Description, _ = raw["description"].(string)
+	if nullable, ok := raw["nullable"].(bool); ok && nullable {
+		schema.Nullable = true
+	}
+
+	switch typ := raw["type"].(type) {
+	case string:
+		schema.Type = jsonSchemaTypes[typ]
+		schema.Nullable = schema.Nullable || typ == "null"
+		if schema.Type == "" && !lenient {
+			return Schema{}, fmt.Errorf("%s: unsupported type %q", path, typ)
+		}
+	case nil:
+		if !lenient {
+			return Schema{}, fmt.Errorf("%s: unsupported type \"\"", path)
+		}
+	case []interface{}:
+		if !lenient {
+			return Schema{}, fmt.Errorf("%s: type must be a single type", path)
+		}
+		for _, item := range typ {
+			name, _ := item.(string)
+			if name == "null" {
+				schema.Nullable = true
+			} else if schema.Type == "" {
+				schema.Type = jsonSchemaTypes[name]
+			}
+		}
+	default:
+		if !lenient {
+			return Schema{}, fmt.Errorf("%s: type must be a string", path)
+		}
+	}
+
+	if schema.Type == "" {
+		var chosen map[string]interface{}
+		for _, key := range []string{"anyOf", "oneOf"} {
+			alternatives, _ := raw[key].([]interface{})
+			for _, alternative := range alternatives {
+				object, ok := alternative.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if object["type"] == "null" {
+					schema.Nullable = true
+				} else if chosen == nil {
+					chosen = object
+				}
+			}
+		}
+		if chosen != nil {
+			converted, err := convertJSONSchema(path, chosen, lenient)
+			if err != nil {
+				return Schema{}, err
+			}
+			converted.Nullable = converted.Nullable || schema.Nullable
+			if schema.Description != "" {
+				converted.Description = schema.Description
+			}
+			return converted, nil
+		}
+		if _, ok := raw["properties"]; ok {
+			schema.Type = TypeObject
+		} else {
+			schema.Type = TypeString
+		}
+	}
+
+	if _, ok := raw["enum"]; ok && schema.Type != TypeString && !lenient {
+		return Schema{}, fmt.Errorf("%s: enum is only supported for strings", path)
+	}
+	switch schema.Type {
+	case TypeObject:
+		if err := convertProperties(path, raw, &schema, lenient); err != nil {
+			return Schema{}, err
+		}
+	case TypeArray:
+		items := Schema{Type: TypeString}
+		switch object := raw["items"].(type) {
+		case map[string]interface{}:
+			var err error
+			if items, err = convertJSONSchema(path+"[]", object, lenient); err != nil {
+				return Schema{}, err
+			}
+		case nil:
+			if !lenient {
+				return Schema{}, fmt.Errorf("%s: array without items", path)
+			}
+		default:
+			if !lenient {
+				return Schema{}, fmt.Errorf("%s: items must be a schema", path)
+			}
+		}
+		schema.Items = &items
+	case TypeString:
+		values, ok := raw["enum"].([]interface{})
+		if _, present := raw["enum"]; present && !ok && !lenient {
+			return Schema{}, fmt.Errorf("%s: enum must be a list", path)
+		}
+		for _, value := range values {
+			text, ok := value.(string)
+			if !ok && !lenient {
+				return Schema{}, fmt.Errorf("%s: enum values must be strings", path)
+			}
+			if ok {
+				schema.Enum = append(schema.Enum, text)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// convertProperties converts the properties and required names of an object schema.
+func convertProperties(path string, raw map[string]interface{}, schema *Schema, lenient bool) error {
+	properties, ok := raw["properties"].(map[string]interface{})
+	if _, present := raw["properties"]; present && !ok && !lenient {
+		return fmt.Errorf("%s: properties must be an object", path)
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			if lenient {
+				continue
+			}
+			return fmt.Errorf("%s.%s: the schema must be an object", path, name)
+		}
+		converted, err := convertJSONSchema(path+"."+name, property, lenient)
+		if err != nil {
+			return err
+		}
+		if schema.Properties == nil {
+			schema.Properties = make(map[string]Schema, len(properties))
+		}
+		schema.Properties[name] = converted
+	}
+
+	required, ok := raw["required"].([]interface{})
+	if _, present := raw["required"]; present && !ok && !lenient {
+		return fmt.Errorf("%s: required must be a list of names", path)
+	}
+	for _, value := range required {
+		name, _ := value.(string)
+		if _, defined := schema.Properties[name]; defined {
+			schema.Required = append(schema.Required, name)
+		} else if !lenient {
+			return fmt.Errorf("%s: required property %q is not defined", path, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package shared
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONSchema(t *testing.T) {
+	input := `{
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "description": "A name.", "minLength": 1},
+			"level": {"type": "string", "enum": ["low", "high"], "nullable": true},
+			"tags": {"type": "array", "items": {"type": "integer"}}
+		},
+		"required": ["name"]
+	}`
+	want := Schema{
+		Type: TypeObject,
+		Properties: map[string]Schema{
+			"name":  {Type: TypeString, Description: "A name."},
+			"level": {Type: TypeString, Enum: []string{"low", "high"}, Nullable: true},
+			"tags":  {Type: TypeArray, Items: &Schema{Type: TypeInteger}},
+		},
+		Required: []string{"name"},
+	}
+	// A schema both modes accept converts the same way.
+	for _, options := range []JSONSchemaOptions{{}, {Lenient: true}} {
+		got, err := ParseJSONSchema([]byte(input), options)
+		if err != nil {
+			t.Fatalf("ParseJSONSchema(%+v) failed: %v", options, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseJSONSchema(%+v) =\n%+v\nwant\n%+v", options, got, want)
+		}
+	}
+
+	tests := []struct {
+		input   string
+		want    Schema
+		wantErr string
+	}{
+		{`{"type": ["integer", "null"]}`, Schema{Type: TypeInteger, Nullable: true}, "$: type must be a single type"},
+		{`{"anyOf": [{"type": "null"}, {"type": "boolean"}]}`, Schema{Type: TypeBoolean, Nullable: true}, `$: unsupported type ""`},
+		{`{"type": "date"}`, Schema{Type: TypeString}, `$: unsupported type "date"`},
+		{`{"type": "array"}`, Schema{Type: TypeArray, Items: &Schema{Type: TypeString}}, "$: array without items"},
+		{`{"type": "string", "enum": ["a", 1]}`, Schema{Type: TypeString, Enum: []string{"a"}}, "$: enum values must be strings"},
+		{`{"type": "integer", "enum": [1]}`, Schema{Type: TypeInteger}, "$: enum is only supported for strings"},
+		{`{"properties": {"a": {"type": 1}}}`, Schema{Type: TypeObject, Properties: map[string]Schema{"a": {Type: TypeString}}}, `$: unsupported type ""`},
+		{`{"type": "object", "properties": {"a": {"type": "date"}}}`, Schema{Type: TypeObject, Properties: map[string]Schema{"a": {Type: TypeString}}}, `$.a: unsupported type "date"`},
+		{`{"type": "object", "properties": {"a": 1}}`, Schema{Type: TypeObject}, "$.a: the schema must be an object"},
+		{`{"type": "object", "required": ["a"]}`, Schema{Type: TypeObject}, `$: required property "a" is not defined`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseJSONSchema([]byte(tt.input), JSONSchemaOptions{}); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParseJSONSchema(%s) = %v, want an error containing %q", tt.input, err, tt.wantErr)
+		}
+		got, err := ParseJSONSchema([]byte(tt.input), JSONSchemaOptions{Lenient: true})
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lenient ParseJSONSchema(%s) = %+v, %v, want %+v", tt.input, got, err, tt.want)
+		}
+	}
+
+	if _, err := ParseJSONSchema([]byte(`[1]`), JSONSchemaOptions{Lenient: true}); err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("Expected an error for a schema that is not an object, got %v", err)
+	}
+}
@@ -17,18 +17,47 @@ type Tools struct {
 }
 
 // FunctionCall represents a function call from the model.
+// ID is only set by backends that assign identifiers to calls.
 type FunctionCall struct {
+	ID   string                 `json:"id,omitempty"`
 	Name string                 `json:"name"`
 	Args map[string]interface{} `json:"args"`
 }
 
+// FunctionResponse represents the result of a FunctionCall that is sent back to the model.
+// Name and ID match the call it answers.
+type FunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// NewFunctionResponse builds the response to call from a tool's output.
+// A successful result is reported under the "output" key and a failure under the "error" key,
+// so the model can tell the two apart.
+func NewFunctionResponse(call FunctionCall, output string, err error) FunctionResponse {
+	response := map[string]interface{}{}
+	if err != nil {
+		response["error"] = err.Error()
+	} else {
+		response["output"] = output
+	}
+	return FunctionResponse{
+		ID:       call.ID,
+		Name:     call.Name,
+		Response: response,
+	}
+}
+
 // Schema represents the schema of a function's parameters.
 type Schema struct {
 	Type        Type              `json:"type"`
 	Properties  map[string]Schema `json:"properties,omitempty"`
 	Required    []string          `json:"required,omitempty"`
 	Description string            `json:"description,omitempty"`
-	Items       *Schema           `json:"items,omitempty"` // Add Items field for array type
+	Items       *Schema           `json:"items,omitempty"`    // Add Items field for array type
+	Enum        []string          `json:"enum,omitempty"`     // Allowed values of a string
+	Nullable    bool              `json:"nullable,omitempty"` // Whether null is allowed
 }
 
 // Type represents the data type of a schema property.
@@ -54,5 +83,29 @@ type Tool interface {
 	Name() string
 	Description() string
 	FunctionDeclaration() FunctionDeclaration
+	// IsReadOnly reports whether the tool only reads state.
+	// Read-only tools may run concurrently; mutating tools are always run one at a time.
+	IsReadOnly() bool
 	Execute(ctx context.Context, args map[string]interface{}) (string, error)
-}
\ No newline at end of file
+}
+
+// TrustedTool is implemented by tools that may change state but whose calls the user
+// allowed to run without confirmation, such as those of a trusted MCP server.
+type TrustedTool interface {
+	Tool
+	IsTrusted() bool
+}
+
+// ConfirmationDetails describes what a call to a mutating tool will do, so the user can approve it.
+type ConfirmationDetails struct {
+	// Title is a one-line summary, e.g. "Write to /tmp/a.txt".
+	Title string
+	// Details shows the effect of the call, e.g. a diff of the file to be written.
+	Details string
+}
+
+// ConfirmableTool is implemented by mutating tools that can describe a call before it runs.
+type ConfirmableTool interface {
+	Tool
+	ConfirmationDetails(args map[string]interface{}) (ConfirmationDetails, error)
+}
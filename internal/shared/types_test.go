@@ -2,6 +2,7 @@ package shared
 
 import (
 	"context"
+	"errors"
 	"testing"
 )
 
@@ -88,6 +89,31 @@ func TestFunctionCall(t *testing.T) {
 	}
 }
 
+func TestNewFunctionResponse(t *testing.T) {
+	call := FunctionCall{ID: "call-1", Name: "test_call"}
+
+	// Successful output
+	fr := NewFunctionResponse(call, "result", nil)
+	if fr.ID != "call-1" || fr.Name != "test_call" {
+		t.Errorf("Expected response keyed by call-1/test_call, got %s/%s", fr.ID, fr.Name)
+	}
+	if fr.Response["output"] != "result" {
+		t.Errorf("Expected output 'result', got %v", fr.Response["output"])
+	}
+	if _, ok := fr.Response["error"]; ok {
+		t.Error("Expected no error key for successful output")
+	}
+
+	// Failed execution
+	fr = NewFunctionResponse(call, "", errors.New("boom"))
+	if fr.Response["error"] != "boom" {
+		t.Errorf("Expected error 'boom', got %v", fr.Response["error"])
+	}
+	if _, ok := fr.Response["output"]; ok {
+		t.Error("Expected no output key for failed execution")
+	}
+}
+
 func TestSchema(t *testing.T) {
 	// Test creating a complex Schema
 	schema := Schema{
@@ -183,6 +209,10 @@ func (m *MockTool) FunctionDeclaration() FunctionDeclaration {
 	}
 }
 
+func (m *MockTool) IsReadOnly() bool {
+	return true
+}
+
 func (m *MockTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
 	m.executed = true
 	return m.output, m.err
@@ -0,0 +1,121 @@
+package shared
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidateValue checks that value, as decoded by encoding/json into an interface{},
+// matches the schema. The error names the first mismatch by its path, e.g. "$.items[2].name".
+// Properties that the schema does not declare are allowed.
+func (s Schema) ValidateValue(value interface{}) error {
+	return s.validate("$", value)
+}
+
+func (s Schema) validate(path string, value interface{}) error {
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: expected %s, got null", path, s.Type)
+	}
+
+	switch s.Type {
+	case TypeString:
+		str, ok := value.(string)
+		if !ok {
+			return mismatch(path, s.Type, value)
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %s", path, str, strings.Join(s.Enum, ", "))
+		}
+	case TypeNumber:
+		if _, ok := value.(float64); !ok {
+			return mismatch(path, s.Type, value)
+		}
+	case TypeInteger:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return mismatch(path, s.Type, value)
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return mismatch(path, s.Type, value)
+		}
+	case TypeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return mismatch(path, s.Type, value)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case TypeObject:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch(path, s.Type, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		// Sorted, so that the reported mismatch does not depend on map order.
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := object[name]; ok {
+				if err := s.Properties[name].validate(path+"."+name, property); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", path, s.Type)
+	}
+	return nil
+}
+
+// mismatch reports a value of the wrong JSON type.
+func mismatch(path string, want Type, value interface{}) error {
+	return fmt.Errorf("%s: expected %s, got %s", path, want, jsonType(value))
+}
+
+// jsonType names the JSON type of a value decoded by encoding/json.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemaValidateValue(t *testing.T) {
+	schema := Schema{
+		Type: TypeObject,
+		Properties: map[string]Schema{
+			"name":  {Type: TypeString},
+			"count": {Type: TypeInteger},
+			"score": {Type: TypeNumber, Nullable: true},
+			"level": {Type: TypeString, Enum: []string{"low", "high"}},
+			"tags":  {Type: TypeArray, Items: &Schema{Type: TypeString}},
+			"done":  {Type: TypeBoolean},
+		},
+		Required: []string{"name", "count"},
+	}
+
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{`{"name": "a", "count": 2, "score": 0.5, "level": "low", "tags": ["x"], "done": true, "extra": 1}`, ""},
+		{`{"name": "a", "count": 2, "score": null}`, ""},
+		{`{"name": "a"}`, `$: missing required property "count"`},
+		{`{"name": 1, "count": 2}`, "$.name: expected string, got integer"},
+		{`{"name": "a", "count": 2.5}`, "$.count: expected integer, got number"},
+		{`{"name": "a", "count": 2, "level": "medium"}`, `$.level: "medium" is not one of low, high`},
+		{`{"name": "a", "count": 2, "tags": ["x", false]}`, "$.tags[1]: expected string, got boolean"},
+		{`{"name": null, "count": 2}`, "$.name: expected string, got null"},
+		{`[1, 2]`, "$: expected object, got array"},
+	}
+	for _, tt := range tests {
+		var value interface{}
+		if err := json.Unmarshal([]byte(tt.input), &value); err != nil {
+			t.Fatal(err)
+		}
+		err := schema.ValidateValue(value)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("ValidateValue(%s) failed: %v", tt.input, err)
+		}
+		if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("ValidateValue(%s) = %v, want %q", tt.input, err, tt.wantErr)
+		}
+	}
+}
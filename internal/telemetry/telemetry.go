@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"gemini-cli-go/internal/config"
 	"log"
+	"os"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
@@ -15,14 +16,15 @@ import (
 
 var tp *sdktrace.TracerProvider
 
-// InitializeTelemetry initializes the OpenTelemetry SDK.
+// InitializeTelemetry initializes the OpenTelemetry SDK. Progress is reported on stderr,
+// so that it does not mix with the output of commands.
 func InitializeTelemetry(cfg *config.CliConfig) {
 	if cfg.TelemetryEnabled == nil || !*cfg.TelemetryEnabled {
-		fmt.Println("Telemetry is disabled.")
+		fmt.Fprintln(os.Stderr, "Telemetry is disabled.")
 		return
 	}
 
-	fmt.Println("Initializing Telemetry...")
+	fmt.Fprintln(os.Stderr, "Initializing Telemetry...")
 
 	ctx := context.Background()
 
@@ -44,21 +46,21 @@ func InitializeTelemetry(cfg *config.CliConfig) {
 	// Set the global TracerProvider
 	otel.SetTracerProvider(tp)
 
-	fmt.Println("Telemetry initialized.")
+	fmt.Fprintln(os.Stderr, "Telemetry initialized.")
 }
 
 // ShutdownTelemetry shuts down the OpenTelemetry SDK.
 func ShutdownTelemetry(ctx context.Context) {
 	if tp == nil {
-		fmt.Println("Telemetry not initialized, skipping shutdown.")
+		fmt.Fprintln(os.Stderr, "Telemetry not initialized, skipping shutdown.")
 		return
 	}
 
-	fmt.Println("Shutting down Telemetry...")
+	fmt.Fprintln(os.Stderr, "Shutting down Telemetry...")
 
 	if err := tp.Shutdown(ctx); err != nil {
 		log.Fatalf("failed to shutdown TracerProvider: %v", err)
 	}
 
-	fmt.Println("Telemetry shut down.")
+	fmt.Fprintln(os.Stderr, "Telemetry shut down.")
 }
@@ -0,0 +1,58 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gemini-cli-go/internal/shared"
+)
+
+// Decision is the user's answer to a confirmation request.
+type Decision int
+
+const (
+	// Deny rejects the call; the model is told it was not approved.
+	Deny Decision = iota
+	// Approve runs the call once.
+	Approve
+	// AlwaysApprove runs the call and every later call to the same tool without asking again.
+	// For run_shell_command, only later commands with the same root commands are approved.
+	AlwaysApprove
+)
+
+// Confirmer asks whether a call to a mutating tool may run.
+type Confirmer interface {
+	Confirm(ctx context.Context, call shared.FunctionCall, details shared.ConfirmationDetails) (Decision, error)
+}
+
+// NonInteractiveConfirmer denies every call, for sessions where nobody can be asked.
+type NonInteractiveConfirmer struct{}
+
+// Confirm always fails with an error explaining how to allow the call.
+func (c NonInteractiveConfirmer) Confirm(_ context.Context, call shared.FunctionCall, _ shared.ConfirmationDetails) (Decision, error) {
+	return Deny, fmt.Errorf("%s requires confirmation, which is not available in non-interactive mode; run with --yolo to allow it", call.Name)
+}
+
+// confirmationDetails describes call for the user. Tools that do not implement
+// shared.ConfirmableTool are described by their arguments.
+func confirmationDetails(calledTool shared.Tool, call shared.FunctionCall) (shared.ConfirmationDetails, error) {
+	if confirmable, ok := calledTool.(shared.ConfirmableTool); ok {
+		return confirmable.ConfirmationDetails(call.Args)
+	}
+
+	keys := make([]string, 0, len(call.Args))
+	for key := range call.Args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var builder strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&builder, "%s: %v\n", key, call.Args[key])
+	}
+	return shared.ConfirmationDetails{
+		Title:   fmt.Sprintf("Run %s", call.Name),
+		Details: builder.String(),
+	}, nil
+}
@@ -0,0 +1,214 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/process"
+	"gemini-cli-go/internal/shared"
+)
+
+// discoveredToolName is what the name of a discovered tool may look like. It is passed to
+// the call command unquoted, so it must not contain characters the shell interprets.
+var discoveredToolName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,63}$`)
+
+// DiscoverToolsFromSettings runs the toolDiscoveryCommand setting and returns a tool for each
+// declaration it prints, called through the toolCallCommand setting. It returns nothing if
+// no discovery command is set.
+func DiscoverToolsFromSettings(ctx context.Context, settings config.Settings) ([]shared.Tool, error) {
+	if settings.ToolDiscoveryCommand == nil || strings.TrimSpace(*settings.ToolDiscoveryCommand) == "" {
+		return nil, nil
+	}
+	if settings.ToolCallCommand == nil || strings.TrimSpace(*settings.ToolCallCommand) == "" {
+		return nil, errors.New("toolDiscoveryCommand is set, but toolCallCommand is not")
+	}
+	return DiscoverTools(ctx, *settings.ToolDiscoveryCommand, *settings.ToolCallCommand)
+}
+
+// DiscoverTools runs discoveryCommand with the system shell and returns a DiscoveredTool for
+// each function declaration it prints, see ParseFunctionDeclarations.
+func DiscoverTools(ctx context.Context, discoveryCommand, callCommand string) ([]shared.Tool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultShellTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := process.Shell(ctx, discoveryCommand)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("tool discovery command failed: %w%s", err, formatStderr(stderr.String()))
+	}
+
+	declarations, err := ParseFunctionDeclarations(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("invalid output of the tool discovery command: %w", err)
+	}
+	tools := make([]shared.Tool, len(declarations))
+	for i, declaration := range declarations {
+		tools[i] = &DiscoveredTool{Declaration: declaration, CallCommand: callCommand}
+	}
+	return tools, nil
+}
+
+// ParseFunctionDeclarations parses the output of a discovery command: a JSON array whose
+// items are function declarations, or tools of the Gemini API, i.e. objects with
+// a "functionDeclarations" (or "function_declarations") array. Types may be written in
+// upper case, as the API does, and parameters without a type are an object.
+func ParseFunctionDeclarations(data []byte) ([]shared.FunctionDeclaration, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("expected a JSON array: %w", err)
+	}
+
+	var raw []json.RawMessage
+	for _, item := range items {
+		var tool struct {
+			FunctionDeclarations      []json.RawMessage `json:"functionDeclarations"`
+			SnakeFunctionDeclarations []json.RawMessage `json:"function_declarations"`
+		}
+		if err := json.Unmarshal(item, &tool); err != nil {
+			return nil, err
+		}
+		if tool.FunctionDeclarations == nil && tool.SnakeFunctionDeclarations == nil {
+			raw = append(raw, item)
+		}
+		raw = append(raw, tool.FunctionDeclarations...)
+		raw = append(raw, tool.SnakeFunctionDeclarations...)
+	}
+
+	seen := make(map[string]bool)
+	declarations := make([]shared.FunctionDeclaration, 0, len(raw))
+	for _, item := range raw {
+		var declaration shared.FunctionDeclaration
+		if err := json.Unmarshal(item, &declaration); err != nil {
+			return nil, err
+		}
+		if !discoveredToolName.MatchString(declaration.Name) {
+			return nil, fmt.Errorf("invalid tool name %q", declaration.Name)
+		}
+		if seen[declaration.Name] {
+			return nil, fmt.Errorf("tool %s is declared twice", declaration.Name)
+		}
+		seen[declaration.Name] = true
+		if declaration.Parameters.Type == "" {
+			declaration.Parameters.Type = shared.TypeObject
+		}
+		if err := normalizeSchema(declaration.Name, &declaration.Parameters); err != nil {
+			return nil, err
+		}
+		declarations = append(declarations, declaration)
+	}
+	return declarations, nil
+}
+
+// normalizeSchema lowercases the types of schema and checks that they are known.
+func normalizeSchema(path string, schema *shared.Schema) error {
+	schema.Type = shared.Type(strings.ToLower(string(schema.Type)))
+	switch schema.Type {
+	case shared.TypeString, shared.TypeNumber, shared.TypeInteger, shared.TypeBoolean, shared.TypeObject:
+	case shared.TypeArray:
+		if schema.Items == nil {
+			return fmt.Errorf("%s: array without items", path)
+		}
+		if err := normalizeSchema(path+"[]", schema.Items); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: unsupported type %q", path, schema.Type)
+	}
+	for name, property := range schema.Properties {
+		if err := normalizeSchema(path+"."+name, &property); err != nil {
+			return err
+		}
+		schema.Properties[name] = property
+	}
+	return nil
+}
+
+// DiscoveredTool is a tool declared by the discovery command. A call runs CallCommand
+// with the tool's name as its argument and the call's arguments as JSON on stdin;
+// what the command prints is the result.
+type DiscoveredTool struct {
+	Declaration shared.FunctionDeclaration
+	CallCommand string
+	// Timeout bounds how long a call may run. Zero selects DefaultShellTimeout.
+	Timeout time.Duration
+}
+
+// Name returns the declared name of the tool.
+func (t *DiscoveredTool) Name() string {
+	return t.Declaration.Name
+}
+
+// Description returns the declared description of the tool.
+func (t *DiscoveredTool) Description() string {
+	return t.Declaration.Description
+}
+
+// FunctionDeclaration returns the declaration printed by the discovery command.
+func (t *DiscoveredTool) FunctionDeclaration() shared.FunctionDeclaration {
+	return t.Declaration
+}
+
+// IsReadOnly is false, since nothing is known about what the command does.
+func (t *DiscoveredTool) IsReadOnly() bool {
+	return false
+}
+
+// Execute runs the call command. Output on stderr is returned after the output on stdout;
+// a non-zero exit code is an error that includes both.
+func (t *DiscoveredTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	input, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode arguments: %w", err)
+	}
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = DefaultShellTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := newBoundedBuffer(DefaultShellMaxOutput)
+	stderr := newBoundedBuffer(DefaultShellMaxOutput)
+	cmd := process.Shell(runCtx, t.CallCommand+" "+t.Name())
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	runErr := cmd.Run()
+
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%s timed out after %s", t.Name(), timeout)
+	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("%s cancelled: %w", t.Name(), ctx.Err())
+	}
+	output := stdout.String() + formatStderr(stderr.String())
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		return "", fmt.Errorf("%s failed with exit code %d\n%s", t.Name(), exitErr.ExitCode(), output)
+	}
+	if runErr != nil {
+		return "", fmt.Errorf("failed to run the tool call command: %w", runErr)
+	}
+	return output, nil
+}
+
+// formatStderr formats the stderr output of a command to follow its other output.
+func formatStderr(stderr string) string {
+	if strings.TrimSpace(stderr) == "" {
+		return ""
+	}
+	return "\nStderr:\n" + stderr
+}
@@ -0,0 +1,113 @@
+//go:build !windows
+
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/shared"
+)
+
+func TestParseFunctionDeclarations(t *testing.T) {
+	declarations, err := ParseFunctionDeclarations([]byte(`[
+		{"name": "plain", "description": "A declaration."},
+		{"functionDeclarations": [{
+			"name": "upper",
+			"parameters": {"type": "OBJECT", "properties": {"ids": {"type": "ARRAY", "items": {"type": "INTEGER"}}}}
+		}]},
+		{"function_declarations": [{"name": "snake.case-name", "parameters": {"properties": {"a": {"type": "string"}}}}]}
+	]`))
+	if err != nil {
+		t.Fatalf("ParseFunctionDeclarations failed: %v", err)
+	}
+	var names []string
+	for _, declaration := range declarations {
+		names = append(names, declaration.Name)
+	}
+	if strings.Join(names, " ") != "plain upper snake.case-name" {
+		t.Fatalf("Unexpected declarations: %v", names)
+	}
+	if declarations[0].Parameters.Type != shared.TypeObject || declarations[0].Description != "A declaration." {
+		t.Errorf("Expected plain to take no parameters, got %+v", declarations[0])
+	}
+	if items := declarations[1].Parameters.Properties["ids"].Items; items == nil || items.Type != shared.TypeInteger {
+		t.Errorf("Expected upper case types to be normalized, got %+v", declarations[1].Parameters)
+	}
+	if declarations[2].Parameters.Type != shared.TypeObject {
+		t.Errorf("Expected parameters without a type to be an object, got %+v", declarations[2].Parameters)
+	}
+
+	for _, invalid := range []string{
+		`{"name": "not_an_array"}`,
+		`[{"name": "rm -rf"}]`,
+		`[{"name": "dup"}, {"name": "dup"}]`,
+		`[{"name": "bad_type", "parameters": {"type": "object", "properties": {"a": {"type": "date"}}}}]`,
+		`[{"name": "no_items", "parameters": {"type": "object", "properties": {"a": {"type": "array"}}}}]`,
+	} {
+		if _, err := ParseFunctionDeclarations([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+// writeScript writes an executable shell script to dir and returns its path.
+func writeScript(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiscoverTools(t *testing.T) {
+	dir := t.TempDir()
+	discover := writeScript(t, dir, "discover.sh", `echo '[{"name": "greet", "description": "Greets."}, {"name": "fail"}]'`)
+	call := writeScript(t, dir, "call.sh", `
+case "$1" in
+greet) echo "hello $(cat)"; echo "a warning" >&2 ;;
+fail) echo "partial"; echo "broken" >&2; exit 2 ;;
+esac
+`)
+
+	discoveryCommand, callCommand := discover, call
+	tools, err := DiscoverToolsFromSettings(context.Background(), config.Settings{
+		ToolDiscoveryCommand: &discoveryCommand,
+		ToolCallCommand:      &callCommand,
+	})
+	if err != nil {
+		t.Fatalf("DiscoverToolsFromSettings failed: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name() != "greet" || tools[0].Description() != "Greets." || tools[0].IsReadOnly() {
+		t.Fatalf("Unexpected tools: %+v", tools)
+	}
+
+	output, err := tools[0].Execute(context.Background(), map[string]interface{}{"who": "world"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if output != "hello {\"who\":\"world\"}\n\nStderr:\na warning\n" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+	_, err = tools[1].Execute(context.Background(), nil)
+	if err == nil || err.Error() != "fail failed with exit code 2\npartial\n\nStderr:\nbroken\n" {
+		t.Errorf("Expected the exit code and output in the error, got %v", err)
+	}
+
+	// Without a discovery command nothing is discovered, and without a call command it is an error.
+	if tools, err := DiscoverToolsFromSettings(context.Background(), config.Settings{}); tools != nil || err != nil {
+		t.Errorf("Expected no tools without a discovery command, got %v, %v", tools, err)
+	}
+	if _, err := DiscoverToolsFromSettings(context.Background(), config.Settings{ToolDiscoveryCommand: &discoveryCommand}); err == nil {
+		t.Error("Expected an error without a call command")
+	}
+	failing := "echo 'no tools here' >&2; exit 1"
+	if _, err := DiscoverTools(context.Background(), failing, call); err == nil || !strings.Contains(err.Error(), "no tools here") {
+		t.Errorf("Expected the discovery command's stderr in the error, got %v", err)
+	}
+}
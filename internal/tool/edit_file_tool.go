@@ -0,0 +1,171 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gemini-cli-go/internal/diff"
+	"gemini-cli-go/internal/filesystem"
+	"gemini-cli-go/internal/shared"
+)
+
+// EditFileTool implements the Tool interface for replacing exact text in a file.
+type EditFileTool struct{}
+
+// Name returns the name of the tool.
+func (t *EditFileTool) Name() string {
+	return "edit_file"
+}
+
+// Description returns a description of the tool.
+func (t *EditFileTool) Description() string {
+	return "Replaces exact text in a file and returns a diff of the change. oldString must match the file exactly, including whitespace and indentation; include enough surrounding lines to make it unique. An empty oldString creates a new file containing newString."
+}
+
+// FunctionDeclaration returns the function declaration for the tool.
+func (t *EditFileTool) FunctionDeclaration() shared.FunctionDeclaration {
+	return shared.FunctionDeclaration{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: shared.Schema{
+			Type: shared.TypeObject,
+			Properties: map[string]shared.Schema{
+				"filePath": {
+					Type:        shared.TypeString,
+					Description: "The absolute path to the file to edit.",
+				},
+				"oldString": {
+					Type:        shared.TypeString,
+					Description: "The exact text to replace.",
+				},
+				"newString": {
+					Type:        shared.TypeString,
+					Description: "The text to replace oldString with.",
+				},
+				"expectedReplacements": {
+					Type:        shared.TypeInteger,
+					Description: "The number of occurrences of oldString to replace. Defaults to 1.",
+				},
+			},
+			Required: []string{"filePath", "oldString", "newString"},
+		},
+	}
+}
+
+// IsReadOnly reports whether the tool only reads state.
+func (t *EditFileTool) IsReadOnly() bool {
+	return false
+}
+
+// ConfirmationDetails shows the edit as a unified diff.
+func (t *EditFileTool) ConfirmationDetails(args map[string]interface{}) (shared.ConfirmationDetails, error) {
+	edit, err := t.prepare(args)
+	if err != nil {
+		return shared.ConfirmationDetails{}, err
+	}
+	return shared.ConfirmationDetails{
+		Title:   fmt.Sprintf("Edit %s", edit.filePath),
+		Details: edit.diff(),
+	}, nil
+}
+
+// Execute executes the edit_file tool.
+func (t *EditFileTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
+	edit, err := t.prepare(args)
+	if err != nil {
+		return "", err
+	}
+
+	if err := filesystem.WriteFile(edit.filePath, []byte(edit.newContent)); err != nil {
+		return "", fmt.Errorf("failed to edit file %s: %w", edit.filePath, err)
+	}
+
+	if edit.created {
+		return fmt.Sprintf("Created %s\n%s", edit.filePath, edit.diff()), nil
+	}
+	return fmt.Sprintf("Successfully edited %s (%d replacements)\n%s", edit.filePath, edit.replacements, edit.diff()), nil
+}
+
+// fileEdit is a validated edit that has not been written yet.
+type fileEdit struct {
+	filePath     string
+	oldContent   string
+	newContent   string
+	replacements int
+	created      bool
+}
+
+func (e *fileEdit) diff() string {
+	if e.created {
+		return diff.Unified("/dev/null", e.filePath, "", e.newContent)
+	}
+	return diff.Unified(e.filePath, e.filePath, e.oldContent, e.newContent)
+}
+
+// prepare validates the arguments against the current file content and computes the result.
+func (t *EditFileTool) prepare(args map[string]interface{}) (*fileEdit, error) {
+	filePath, ok := args["filePath"].(string)
+	if !ok || filePath == "" {
+		return nil, fmt.Errorf("missing or invalid 'filePath' argument")
+	}
+	oldString, ok := args["oldString"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing or invalid 'oldString' argument")
+	}
+	newString, ok := args["newString"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing or invalid 'newString' argument")
+	}
+	expected := 1
+	if value, ok := args["expectedReplacements"]; ok {
+		// Numbers decoded from JSON arrive as float64.
+		var number float64
+		switch n := value.(type) {
+		case float64:
+			number = n
+		case int:
+			number = float64(n)
+		}
+		if number < 1 || number != float64(int(number)) {
+			return nil, fmt.Errorf("invalid 'expectedReplacements' argument: must be a positive integer")
+		}
+		expected = int(number)
+	}
+
+	content, err := filesystem.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		if oldString != "" {
+			return nil, fmt.Errorf("file %s does not exist; use an empty oldString to create it", filePath)
+		}
+		return &fileEdit{filePath: filePath, newContent: newString, created: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if oldString == "" {
+		return nil, fmt.Errorf("file %s already exists; oldString must not be empty", filePath)
+	}
+	if oldString == newString {
+		return nil, fmt.Errorf("oldString and newString are identical; nothing to change")
+	}
+
+	oldContent := string(content)
+	count := strings.Count(oldContent, oldString)
+	if count == 0 {
+		return nil, fmt.Errorf("oldString was not found in %s; it must match exactly, including whitespace and indentation", filePath)
+	}
+	if count != expected {
+		return nil, fmt.Errorf("oldString occurs %d times in %s but %d replacements were expected; include more surrounding context to make it unique, or set expectedReplacements to %d", count, filePath, expected, count)
+	}
+
+	return &fileEdit{
+		filePath:     filePath,
+		oldContent:   oldContent,
+		newContent:   strings.ReplaceAll(oldContent, oldString, newString),
+		replacements: count,
+	}, nil
+}
@@ -0,0 +1,190 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"gemini-cli-go/internal/shared"
+)
+
+// DefaultMaxWorkers is the number of read-only tools run concurrently when no limit is configured.
+const DefaultMaxWorkers = 4
+
+// Executor runs the function calls the model makes in a single turn.
+type Executor struct {
+	registry   shared.ToolRegistryInterface
+	maxWorkers int
+	confirmer  Confirmer
+	// checkpointer saves the state of the project before a mutating call runs, if set.
+	checkpointer Checkpointer
+	// alwaysAllowed holds the approval keys the user approved for the rest of the session.
+	alwaysAllowed map[string]bool
+}
+
+// NewExecutor creates an Executor for the tools in registry.
+// maxWorkers bounds how many read-only tools run at once; values below 1 select DefaultMaxWorkers.
+func NewExecutor(registry shared.ToolRegistryInterface, maxWorkers int) *Executor {
+	if maxWorkers < 1 {
+		maxWorkers = DefaultMaxWorkers
+	}
+	return &Executor{
+		registry:      registry,
+		maxWorkers:    maxWorkers,
+		alwaysAllowed: make(map[string]bool),
+	}
+}
+
+// SetConfirmer makes the executor ask confirmer before running a mutating tool.
+// With no confirmer, every call runs without confirmation (--yolo).
+func (e *Executor) SetConfirmer(confirmer Confirmer) {
+	e.confirmer = confirmer
+}
+
+// Checkpointer saves what a mutating call may change, before it runs.
+type Checkpointer interface {
+	Checkpoint(ctx context.Context, call shared.FunctionCall) error
+}
+
+// SetCheckpointer makes the executor save a checkpoint with checkpointer before every approved
+// mutating call. A call whose checkpoint fails is not run.
+func (e *Executor) SetCheckpointer(checkpointer Checkpointer) {
+	e.checkpointer = checkpointer
+}
+
+// Execute runs every call and returns the responses in the same order as calls.
+// Consecutive read-only calls run concurrently. A mutating call waits for all earlier
+// calls to finish and runs alone, so writes are never reordered with the reads around them.
+func (e *Executor) Execute(ctx context.Context, calls []shared.FunctionCall) []shared.FunctionResponse {
+	responses := make([]shared.FunctionResponse, len(calls))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, e.maxWorkers)
+	for i, call := range calls {
+		if !e.isReadOnly(call) {
+			wg.Wait()
+			if err := e.confirm(ctx, call); err != nil {
+				responses[i] = shared.NewFunctionResponse(call, "", err)
+				continue
+			}
+			if err := e.checkpoint(ctx, call); err != nil {
+				responses[i] = shared.NewFunctionResponse(call, "", err)
+				continue
+			}
+			responses[i] = ExecuteFunctionCall(ctx, e.registry, call)
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, call shared.FunctionCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			responses[i] = ExecuteFunctionCall(ctx, e.registry, call)
+		}(i, call)
+	}
+	wg.Wait()
+
+	return responses
+}
+
+// isReadOnly reports whether call targets a registered read-only tool.
+// Unknown tools are treated as mutating.
+func (e *Executor) isReadOnly(call shared.FunctionCall) bool {
+	calledTool, ok := e.registry.GetTool(call.Name)
+	return ok && calledTool.IsReadOnly()
+}
+
+// confirm asks the confirmer whether call may run and returns an error for the model if it may not.
+// Mutating calls run one at a time, so confirmations never overlap.
+func (e *Executor) confirm(ctx context.Context, call shared.FunctionCall) error {
+	calledTool, ok := e.registry.GetTool(call.Name)
+	keys := approvalKeys(call)
+	if !ok || e.confirmer == nil || e.allAllowed(keys) && !alwaysConfirmed(call) {
+		return nil
+	}
+	if trusted, ok := calledTool.(shared.TrustedTool); ok && trusted.IsTrusted() {
+		return nil
+	}
+
+	details, err := confirmationDetails(calledTool, call)
+	if err != nil {
+		return fmt.Errorf("error preparing tool %s: %w", call.Name, err)
+	}
+	decision, err := e.confirmer.Confirm(ctx, call, details)
+	if err != nil {
+		return fmt.Errorf("tool call was not approved: %w", err)
+	}
+	switch decision {
+	case AlwaysApprove:
+		for _, key := range keys {
+			e.alwaysAllowed[key] = true
+		}
+		return nil
+	case Approve:
+		return nil
+	default:
+		return fmt.Errorf("the user denied the call to %s", call.Name)
+	}
+}
+
+// allAllowed reports whether the user always allowed every one of keys.
+func (e *Executor) allAllowed(keys []string) bool {
+	for _, key := range keys {
+		if !e.alwaysAllowed[key] {
+			return false
+		}
+	}
+	return len(keys) > 0
+}
+
+// approvalKeys returns what an "always" answer to call approves. For the shell tool these
+// are the root commands it runs, e.g. "run_shell_command git" for "git status && git diff",
+// so that approving one command does not approve every other; any other tool is approved
+// as a whole.
+func approvalKeys(call shared.FunctionCall) []string {
+	if call.Name != ShellToolName {
+		return []string{call.Name}
+	}
+	command, _ := call.Args["command"].(string)
+	var keys []string
+	for _, root := range CommandRoots(command) {
+		keys = append(keys, call.Name+" "+root)
+	}
+	return keys
+}
+
+// alwaysConfirmed reports whether call needs confirmation even when its approval keys are
+// always allowed: shell commands that redirect to or from files, since the roots they run
+// do not tell which files they write.
+func alwaysConfirmed(call shared.FunctionCall) bool {
+	command, _ := call.Args["command"].(string)
+	return call.Name == ShellToolName && RedirectsShellCommand(command)
+}
+
+// checkpoint saves a checkpoint before call runs. Unknown tools need none, since they fail.
+func (e *Executor) checkpoint(ctx context.Context, call shared.FunctionCall) error {
+	if _, ok := e.registry.GetTool(call.Name); !ok || e.checkpointer == nil {
+		return nil
+	}
+	if err := e.checkpointer.Checkpoint(ctx, call); err != nil {
+		return fmt.Errorf("%s was not run, since no checkpoint could be created: %w", call.Name, err)
+	}
+	return nil
+}
+
+// ExecuteFunctionCall runs the tool requested by call and wraps the result in a FunctionResponse.
+// Unknown tools and execution failures are reported back to the model as errors
+// instead of ending the conversation.
+func ExecuteFunctionCall(ctx context.Context, registry shared.ToolRegistryInterface, call shared.FunctionCall) shared.FunctionResponse {
+	calledTool, ok := registry.GetTool(call.Name)
+	if !ok {
+		return shared.NewFunctionResponse(call, "", fmt.Errorf("tool %s not found", call.Name))
+	}
+
+	output, err := calledTool.Execute(ctx, call.Args)
+	if err != nil {
+		return shared.NewFunctionResponse(call, "", fmt.Errorf("error executing tool %s: %w", call.Name, err))
+	}
+	return shared.NewFunctionResponse(call, output, nil)
+}
@@ -0,0 +1,333 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gemini-cli-go/internal/shared"
+)
+
+func TestExecuteFunctionCall(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.RegisterTool(&MockTool{name: "mock"})
+
+	// Known tool
+	response := ExecuteFunctionCall(context.Background(), registry, shared.FunctionCall{ID: "1", Name: "mock"})
+	if response.ID != "1" || response.Name != "mock" {
+		t.Errorf("Expected response keyed by 1/mock, got %s/%s", response.ID, response.Name)
+	}
+	if response.Response["output"] != "mock output" {
+		t.Errorf("Expected output 'mock output', got %v", response.Response)
+	}
+
+	// Unknown tool
+	response = ExecuteFunctionCall(context.Background(), registry, shared.FunctionCall{Name: "unknown"})
+	if response.Response["error"] != "tool unknown not found" {
+		t.Errorf("Expected not-found error, got %v", response.Response)
+	}
+
+	// Failing tool
+	registry.RegisterTool(&ReadTool{})
+	response = ExecuteFunctionCall(context.Background(), registry, shared.FunctionCall{
+		Name: "read_file",
+		Args: map[string]interface{}{},
+	})
+	if response.Response["error"] != "error executing tool read_file: missing or invalid 'path' argument for read_file tool" {
+		t.Errorf("Expected execution error, got %v", response.Response)
+	}
+}
+
+// trackingTool records how many executions overlap.
+type trackingTool struct {
+	name     string
+	readOnly bool
+	tracker  *concurrencyTracker
+}
+
+type concurrencyTracker struct {
+	mu       sync.Mutex
+	running  int
+	maxSeen  int
+	order    []string
+	overlaps []string // mutating tools that ran while something else was running
+}
+
+func (t *trackingTool) Name() string        { return t.name }
+func (t *trackingTool) Description() string { return "tracks concurrency" }
+func (t *trackingTool) FunctionDeclaration() shared.FunctionDeclaration {
+	return shared.FunctionDeclaration{Name: t.name, Parameters: shared.Schema{Type: shared.TypeObject}}
+}
+func (t *trackingTool) IsReadOnly() bool { return t.readOnly }
+func (t *trackingTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
+	tr := t.tracker
+	tr.mu.Lock()
+	tr.running++
+	if tr.running > tr.maxSeen {
+		tr.maxSeen = tr.running
+	}
+	if !t.readOnly && tr.running > 1 {
+		tr.overlaps = append(tr.overlaps, t.name)
+	}
+	tr.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	tr.mu.Lock()
+	tr.running--
+	tr.order = append(tr.order, t.name)
+	tr.mu.Unlock()
+	return fmt.Sprintf("%s:%v", t.name, args["n"]), nil
+}
+
+func TestExecutorRunsAllCallsInOrder(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	registry := NewToolRegistry()
+	registry.RegisterTool(&trackingTool{name: "read", readOnly: true, tracker: tracker})
+	registry.RegisterTool(&trackingTool{name: "write", readOnly: false, tracker: tracker})
+
+	calls := []shared.FunctionCall{
+		{Name: "read", Args: map[string]interface{}{"n": 1}},
+		{Name: "read", Args: map[string]interface{}{"n": 2}},
+		{Name: "read", Args: map[string]interface{}{"n": 3}},
+		{Name: "write", Args: map[string]interface{}{"n": 4}},
+		{Name: "read", Args: map[string]interface{}{"n": 5}},
+		{Name: "unknown"},
+	}
+
+	responses := NewExecutor(registry, 2).Execute(context.Background(), calls)
+
+	if len(responses) != len(calls) {
+		t.Fatalf("Expected %d responses, got %d", len(calls), len(responses))
+	}
+	want := []string{"read:1", "read:2", "read:3", "write:4", "read:5"}
+	for i, w := range want {
+		if responses[i].Response["output"] != w {
+			t.Errorf("Response %d: expected output %q, got %v", i, w, responses[i].Response)
+		}
+	}
+	if responses[5].Response["error"] == nil {
+		t.Errorf("Expected an error for the unknown tool, got %v", responses[5].Response)
+	}
+
+	if tracker.maxSeen != 2 {
+		t.Errorf("Expected read-only tools to run 2 at a time, max concurrency was %d", tracker.maxSeen)
+	}
+	if len(tracker.overlaps) != 0 {
+		t.Errorf("Expected mutating tools to run alone, overlapping: %v", tracker.overlaps)
+	}
+	// The write must finish after the first three reads and before the last one.
+	if tracker.order[3] != "write" {
+		t.Errorf("Expected write to run fourth, got order %v", tracker.order)
+	}
+}
+
+func TestNewExecutorDefaultWorkers(t *testing.T) {
+	executor := NewExecutor(NewToolRegistry(), 0)
+	if executor.maxWorkers != DefaultMaxWorkers {
+		t.Errorf("Expected %d workers, got %d", DefaultMaxWorkers, executor.maxWorkers)
+	}
+}
+
+// scriptedConfirmer answers confirmation requests with the given decisions in order.
+type scriptedConfirmer struct {
+	decisions []Decision
+	asked     []shared.ConfirmationDetails
+}
+
+func (c *scriptedConfirmer) Confirm(_ context.Context, _ shared.FunctionCall, details shared.ConfirmationDetails) (Decision, error) {
+	c.asked = append(c.asked, details)
+	decision := c.decisions[0]
+	c.decisions = c.decisions[1:]
+	return decision, nil
+}
+
+func TestExecutorConfirmsMutatingTools(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	registry := NewToolRegistry()
+	registry.RegisterTool(&trackingTool{name: "read", readOnly: true, tracker: tracker})
+	registry.RegisterTool(&trackingTool{name: "write", readOnly: false, tracker: tracker})
+	registry.RegisterTool(&trackingTool{name: "delete", readOnly: false, tracker: tracker})
+
+	confirmer := &scriptedConfirmer{decisions: []Decision{Deny, AlwaysApprove, Approve}}
+	executor := NewExecutor(registry, 1)
+	executor.SetConfirmer(confirmer)
+
+	responses := executor.Execute(context.Background(), []shared.FunctionCall{
+		{Name: "read", Args: map[string]interface{}{"n": 1}},
+		{Name: "write", Args: map[string]interface{}{"n": 2}},
+		{Name: "write", Args: map[string]interface{}{"n": 3}},
+		{Name: "write", Args: map[string]interface{}{"n": 4}},
+		{Name: "delete", Args: map[string]interface{}{"n": 5}},
+	})
+
+	if responses[0].Response["output"] != "read:1" {
+		t.Errorf("Expected read-only tool to run without confirmation, got %v", responses[0].Response)
+	}
+	if responses[1].Response["error"] != "the user denied the call to write" {
+		t.Errorf("Expected denied call, got %v", responses[1].Response)
+	}
+	for i, want := range []string{"", "", "write:3", "write:4", "delete:5"} {
+		if want != "" && responses[i].Response["output"] != want {
+			t.Errorf("Response %d: expected output %q, got %v", i, want, responses[i].Response)
+		}
+	}
+	// write:4 was always-allowed by the answer to write:3, so only three calls were asked about.
+	if len(confirmer.asked) != 3 {
+		t.Fatalf("Expected 3 confirmation requests, got %d", len(confirmer.asked))
+	}
+	if confirmer.asked[0].Title != "Run write" || confirmer.asked[0].Details != "n: 2\n" {
+		t.Errorf("Unexpected confirmation details: %+v", confirmer.asked[0])
+	}
+}
+
+func TestExecutorAlwaysAllowsShellCommandsByRoot(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.RegisterTool(&commandTool{})
+	registry.RegisterTool(&MockTool{name: "write"})
+
+	confirmer := &scriptedConfirmer{decisions: []Decision{AlwaysApprove, Approve, AlwaysApprove, Approve}}
+	executor := NewExecutor(registry, 1)
+	executor.SetConfirmer(confirmer)
+
+	var calls []shared.FunctionCall
+	for _, command := range []string{
+		"git status",             // asked, always allowed: git
+		"git diff && git log",    // only git
+		"git status; rm -rf x",   // asked: rm is new
+		"npm test | tee out.txt", // asked, always allowed: npm and tee
+		"LANG=C git log",         // only git
+		"tee a; npm ci",          // only tee and npm
+		"rm -rf x",               // asked again: rm was approved once only
+	} {
+		calls = append(calls, shared.FunctionCall{Name: "run_shell_command", Args: map[string]interface{}{"command": command}})
+	}
+	responses := executor.Execute(context.Background(), calls)
+	for i, response := range responses {
+		if response.Response["output"] != "ran: "+calls[i].Args["command"].(string) {
+			t.Errorf("Expected %v to run, got %v", calls[i].Args["command"], response.Response)
+		}
+	}
+	if len(confirmer.asked) != 4 {
+		t.Errorf("Expected 4 confirmation requests, got %d", len(confirmer.asked))
+	}
+
+	// Other tools are still always allowed by name.
+	confirmer.decisions = []Decision{AlwaysApprove}
+	executor.Execute(context.Background(), []shared.FunctionCall{{Name: "write"}, {Name: "write", Args: map[string]interface{}{"n": 2}}})
+	if len(confirmer.asked) != 5 {
+		t.Errorf("Expected one more confirmation request, got %d in all", len(confirmer.asked))
+	}
+}
+
+func TestExecutorAlwaysConfirmsShellRedirections(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.RegisterTool(&commandTool{})
+
+	confirmer := &scriptedConfirmer{decisions: []Decision{AlwaysApprove, AlwaysApprove, Approve}}
+	executor := NewExecutor(registry, 1)
+	executor.SetConfirmer(confirmer)
+
+	var calls []shared.FunctionCall
+	for _, command := range []string{
+		"git status",          // asked, always allowed: git
+		"git log > ~/.bashrc", // asked: redirects, even though git is allowed
+		"git log < in.txt",    // asked again
+		"git log 2>&1",        // only git
+	} {
+		calls = append(calls, shared.FunctionCall{Name: "run_shell_command", Args: map[string]interface{}{"command": command}})
+	}
+	executor.Execute(context.Background(), calls)
+	if len(confirmer.asked) != 3 {
+		t.Errorf("Expected 3 confirmation requests, got %d", len(confirmer.asked))
+	}
+}
+
+func TestExecutorNonInteractiveDeniesMutatingTools(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	registry := NewToolRegistry()
+	registry.RegisterTool(&trackingTool{name: "write", readOnly: false, tracker: tracker})
+
+	executor := NewExecutor(registry, 1)
+	executor.SetConfirmer(NonInteractiveConfirmer{})
+	responses := executor.Execute(context.Background(), []shared.FunctionCall{{Name: "write"}})
+
+	errMsg, _ := responses[0].Response["error"].(string)
+	if !strings.Contains(errMsg, "--yolo") {
+		t.Errorf("Expected the denial to mention --yolo, got %v", responses[0].Response)
+	}
+	if len(tracker.order) != 0 {
+		t.Errorf("Expected the tool not to run, ran %v", tracker.order)
+	}
+}
+
+// trustedTool is a mutating tool that may run without confirmation when trusted is set.
+type trustedTool struct {
+	trackingTool
+	trusted bool
+}
+
+func (t *trustedTool) IsTrusted() bool { return t.trusted }
+
+func TestExecutorSkipsConfirmationForTrustedTools(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	registry := NewToolRegistry()
+	registry.RegisterTool(&trustedTool{trackingTool: trackingTool{name: "trusted", tracker: tracker}, trusted: true})
+	registry.RegisterTool(&trustedTool{trackingTool: trackingTool{name: "untrusted", tracker: tracker}})
+
+	executor := NewExecutor(registry, 1)
+	executor.SetConfirmer(NonInteractiveConfirmer{})
+	responses := executor.Execute(context.Background(), []shared.FunctionCall{{Name: "trusted"}, {Name: "untrusted"}})
+
+	if _, ok := responses[0].Response["output"]; !ok {
+		t.Errorf("Expected the trusted tool to run, got %v", responses[0].Response)
+	}
+	if _, ok := responses[1].Response["error"]; !ok {
+		t.Errorf("Expected the untrusted tool to need confirmation, got %v", responses[1].Response)
+	}
+}
+
+// recordingCheckpointer records the calls it saves a checkpoint for and fails for those named in fail.
+type recordingCheckpointer struct {
+	calls []string
+	fail  string
+}
+
+func (c *recordingCheckpointer) Checkpoint(_ context.Context, call shared.FunctionCall) error {
+	c.calls = append(c.calls, call.Name)
+	if call.Name == c.fail {
+		return fmt.Errorf("disk full")
+	}
+	return nil
+}
+
+func TestExecutorCheckpointsMutatingTools(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	registry := NewToolRegistry()
+	registry.RegisterTool(&trackingTool{name: "read", readOnly: true, tracker: tracker})
+	registry.RegisterTool(&trackingTool{name: "write", tracker: tracker})
+	registry.RegisterTool(&trackingTool{name: "delete", tracker: tracker})
+
+	checkpointer := &recordingCheckpointer{fail: "delete"}
+	executor := NewExecutor(registry, 1)
+	executor.SetConfirmer(&scriptedConfirmer{decisions: []Decision{Deny, Approve, Approve}})
+	executor.SetCheckpointer(checkpointer)
+	responses := executor.Execute(context.Background(), []shared.FunctionCall{
+		{Name: "read"}, {Name: "write"}, {Name: "write"}, {Name: "delete"}, {Name: "unknown"},
+	})
+
+	// The denied call and the read-only one need no checkpoint.
+	if strings.Join(checkpointer.calls, " ") != "write delete" {
+		t.Errorf("Expected checkpoints for the approved mutating calls, got %v", checkpointer.calls)
+	}
+	if strings.Join(tracker.order, " ") != "read write" {
+		t.Errorf("Expected the call whose checkpoint failed not to run, ran %v", tracker.order)
+	}
+	errMsg, _ := responses[3].Response["error"].(string)
+	if !strings.Contains(errMsg, "no checkpoint could be created: disk full") {
+		t.Errorf("Expected the checkpoint error, got %v", responses[3].Response)
+	}
+}
@@ -0,0 +1,106 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gemini-cli-go/internal/filesystem"
+	"gemini-cli-go/internal/shared"
+)
+
+// DefaultMaxGlobResults is how many paths are returned when GlobTool.MaxResults is not set.
+const DefaultMaxGlobResults = 200
+
+// GlobTool implements the Tool interface for finding files by path pattern.
+type GlobTool struct {
+	// MaxResults caps the number of paths returned. Zero selects DefaultMaxGlobResults.
+	MaxResults int
+	// DisableGitIgnore also returns files matched by .gitignore files.
+	DisableGitIgnore bool
+}
+
+// Name returns the name of the tool.
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+// Description returns a description of the tool.
+func (t *GlobTool) Description() string {
+	return "Finds files whose path relative to a directory matches a glob pattern, e.g. '**/*.go' or 'internal/**/*_test.go'. '**' matches any number of directories. Returns paths only, most recently modified first. Files ignored by .gitignore or .geminiignore are skipped."
+}
+
+// FunctionDeclaration returns the function declaration for the tool.
+func (t *GlobTool) FunctionDeclaration() shared.FunctionDeclaration {
+	return shared.FunctionDeclaration{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: shared.Schema{
+			Type: shared.TypeObject,
+			Properties: map[string]shared.Schema{
+				"pattern": {
+					Type:        shared.TypeString,
+					Description: "The glob pattern to match against paths relative to dir.",
+				},
+				"dir": {
+					Type:        shared.TypeString,
+					Description: "The directory to search. Defaults to the current directory.",
+				},
+			},
+			Required: []string{"pattern"},
+		},
+	}
+}
+
+// IsReadOnly reports whether the tool only reads state.
+func (t *GlobTool) IsReadOnly() bool {
+	return true
+}
+
+// Execute executes the glob tool.
+func (t *GlobTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return "", fmt.Errorf("missing or invalid 'pattern' argument for glob tool")
+	}
+	dir, _ := args["dir"].(string)
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := filesystem.Glob(dir, pattern, filesystem.WalkOptions{DisableGitIgnore: t.DisableGitIgnore})
+	if err != nil {
+		return "", fmt.Errorf("failed to find files matching %q in %s: %w", pattern, dir, err)
+	}
+	if len(entries) == 0 {
+		return fmt.Sprintf("No files found matching %q in %s", pattern, dir), nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].ModTime.Equal(entries[j].ModTime) {
+			return entries[i].ModTime.After(entries[j].ModTime)
+		}
+		return entries[i].Path < entries[j].Path
+	})
+
+	maxResults := t.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultMaxGlobResults
+	}
+	total := len(entries)
+	if total > maxResults {
+		entries = entries[:maxResults]
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Found %d files matching %q in %s, most recently modified first:\n", total, pattern, dir))
+	for _, entry := range entries {
+		result.WriteString(entry.Path)
+		result.WriteString("\n")
+	}
+	if total > maxResults {
+		result.WriteString(fmt.Sprintf("(showing the first %d of %d files; use a narrower pattern)\n", maxResults, total))
+	}
+	return result.String(), nil
+}
@@ -0,0 +1,185 @@
+package tool
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gemini-cli-go/internal/filesystem"
+	"gemini-cli-go/internal/shared"
+)
+
+const (
+	// DefaultMaxSearchMatches is how many matching lines are returned when GrepTool.MaxMatches is not set.
+	DefaultMaxSearchMatches = 100
+	// maxSearchLineLen bounds how much of a matching line is returned.
+	maxSearchLineLen = 200
+	// maxSearchScanLen is the longest line that is searched; a file is only searched up to its first longer line.
+	maxSearchScanLen = 1024 * 1024
+)
+
+// GrepTool implements the Tool interface for searching file contents with a regular expression.
+type GrepTool struct {
+	// MaxMatches caps the number of matching lines returned. Zero selects DefaultMaxSearchMatches.
+	MaxMatches int
+	// DisableGitIgnore searches files matched by .gitignore files too.
+	DisableGitIgnore bool
+}
+
+// Name returns the name of the tool.
+func (t *GrepTool) Name() string {
+	return "search_file_content"
+}
+
+// Description returns a description of the tool.
+func (t *GrepTool) Description() string {
+	return "Searches the files under a directory for lines matching a regular expression (Go RE2 syntax) and returns each match as path:line: text. Binary files and files ignored by .gitignore or .geminiignore are skipped."
+}
+
+// FunctionDeclaration returns the function declaration for the tool.
+func (t *GrepTool) FunctionDeclaration() shared.FunctionDeclaration {
+	return shared.FunctionDeclaration{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: shared.Schema{
+			Type: shared.TypeObject,
+			Properties: map[string]shared.Schema{
+				"pattern": {
+					Type:        shared.TypeString,
+					Description: "The regular expression to search for, e.g. 'func\\s+main'.",
+				},
+				"include": {
+					Type:        shared.TypeString,
+					Description: "Optional glob limiting which files are searched, e.g. '*.go' or 'internal/**/*.go'. Patterns containing '/' match the path relative to dir.",
+				},
+				"dir": {
+					Type:        shared.TypeString,
+					Description: "The directory to search. Defaults to the current directory.",
+				},
+			},
+			Required: []string{"pattern"},
+		},
+	}
+}
+
+// IsReadOnly reports whether the tool only reads state.
+func (t *GrepTool) IsReadOnly() bool {
+	return true
+}
+
+// Execute executes the search_file_content tool.
+func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return "", fmt.Errorf("missing or invalid 'pattern' argument for search_file_content tool")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+	}
+	include, _ := args["include"].(string)
+	if include != "" {
+		if _, err := filepath.Match(strings.ReplaceAll(include, "**", "*"), ""); err != nil {
+			return "", fmt.Errorf("invalid include glob %q: %w", include, err)
+		}
+	}
+	dir, _ := args["dir"].(string)
+	if dir == "" {
+		dir = "."
+	}
+
+	maxMatches := t.MaxMatches
+	if maxMatches <= 0 {
+		maxMatches = DefaultMaxSearchMatches
+	}
+
+	var matches []string
+	truncated := false
+	err = filesystem.Walk(dir, filesystem.WalkOptions{DisableGitIgnore: t.DisableGitIgnore}, func(entry filesystem.Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if include != "" && !matchInclude(include, dir, entry.Path) {
+			return nil
+		}
+
+		// Unreadable files are skipped rather than failing the whole search.
+		fileMatches, _ := searchFile(entry.Path, re, maxMatches-len(matches)+1)
+		matches = append(matches, fileMatches...)
+		if len(matches) > maxMatches {
+			matches = matches[:maxMatches]
+			truncated = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search %s: %w", dir, err)
+	}
+
+	if len(matches) == 0 {
+		return fmt.Sprintf("No matches found for pattern %q in %s", pattern, dir), nil
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Found %d matches for pattern %q in %s:\n", len(matches), pattern, dir))
+	for _, match := range matches {
+		result.WriteString(match)
+		result.WriteString("\n")
+	}
+	if truncated {
+		result.WriteString(fmt.Sprintf("(results truncated at %d matches; narrow the pattern, include or dir)\n", maxMatches))
+	}
+	return result.String(), nil
+}
+
+// matchInclude reports whether the file at path matches the include glob.
+// Globs without a slash match the base name; others match the slash-separated path
+// relative to dir, as in filesystem.MatchGlob.
+func matchInclude(include, dir, path string) bool {
+	if !strings.Contains(include, "/") {
+		ok, _ := filepath.Match(include, filepath.Base(path))
+		return ok
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return filesystem.MatchGlob(include, filepath.ToSlash(rel))
+}
+
+// searchFile returns up to limit "path:line: text" matches in the file at path.
+// Binary files have no matches. On a read error, the matches found so far are returned with the error.
+func searchFile(path string, re *regexp.Regexp, limit int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 8192)
+	sample, _ := reader.Peek(8000)
+	if filesystem.LooksBinary(sample) {
+		return nil, nil
+	}
+
+	var matches []string
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSearchScanLen)
+	for lineNumber := 1; scanner.Scan() && len(matches) < limit; lineNumber++ {
+		line := scanner.Text()
+		if !re.MatchString(line) {
+			continue
+		}
+		if runes := []rune(line); len(runes) > maxSearchLineLen {
+			line = string(runes[:maxSearchLineLen]) + "..."
+		}
+		matches = append(matches, fmt.Sprintf("%s:%d: %s", path, lineNumber, line))
+	}
+	return matches, scanner.Err()
+}
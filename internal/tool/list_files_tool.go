@@ -9,7 +9,10 @@ import (
 )
 
 // ListFilesTool implements the Tool interface for listing files.
-type ListFilesTool struct{}
+type ListFilesTool struct {
+	// DisableGitIgnore lists files matched by .gitignore files too.
+	DisableGitIgnore bool
+}
 
 // Name returns the name of the tool.
 func (t *ListFilesTool) Name() string {
@@ -47,6 +50,11 @@ func (t *ListFilesTool) FunctionDeclaration() shared.FunctionDeclaration {
 }
 
 
+// IsReadOnly reports whether the tool only reads state.
+func (t *ListFilesTool) IsReadOnly() bool {
+	return true
+}
+
 // Execute executes the list_files tool.
 func (t *ListFilesTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
 	dir, ok := args["dir"].(string)
@@ -65,19 +73,20 @@ func (t *ListFilesTool) Execute(_ context.Context, args map[string]interface{})
 		}
 	}
 
-	files, err := filesystem.WalkDir(dir, extensions)
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Files in %s (filtered by %v):\n", dir, extensions))
+	found := false
+	err := filesystem.Walk(dir, filesystem.WalkOptions{Extensions: extensions, DisableGitIgnore: t.DisableGitIgnore}, func(entry filesystem.Entry) error {
+		found = true
+		result.WriteString(fmt.Sprintf("- %s (size: %d bytes)\n", entry.Path, entry.Size))
+		return nil
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to list files in %s: %w", dir, err)
 	}
 
-	if len(files) == 0 {
+	if !found {
 		return fmt.Sprintf("No files found in %s with extensions %v", dir, extensions), nil
 	}
-
-	var result strings.Builder
-	result.WriteString(fmt.Sprintf("Files in %s (filtered by %v):\n", dir, extensions))
-	for _, file := range files {
-		result.WriteString(fmt.Sprintf("- %s (size: %d bytes)\n", file.Path, len(file.Content)))
-	}
 	return result.String(), nil
 }
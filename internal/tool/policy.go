@@ -0,0 +1,344 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gemini-cli-go/internal/shared"
+)
+
+// ToolPolicy decides which tools are available and which calls they accept.
+// It is built from the coreTools and excludeTools settings, whose entries are either
+// a tool name such as "read_file", or a name with an argument pattern such as
+// "run_shell_command(git *)". Patterns are matched against the tool's first required
+// argument; "*" matches any text and a pattern without wildcards also matches
+// the pattern followed by a space and more text, so "git" allows "git status".
+// Commands of run_shell_command are split into the commands they chain (see
+// SplitShellCommand): every one must be allowed and none may be excluded. Since a pattern
+// cannot tell where a redirection writes, commands that redirect to or from files (see
+// RedirectsShellCommand) match no coreTools pattern.
+type ToolPolicy struct {
+	// core maps the allowed tools to their allowed argument patterns.
+	// A nil map allows every tool; an empty pattern list allows every call.
+	core map[string][]string
+	// excluded maps tools to the argument patterns they must not be called with.
+	// An empty pattern list excludes the tool entirely.
+	excluded map[string][]string
+}
+
+// ParseToolPolicy parses coreTools and excludeTools entries.
+// An empty coreTools list allows all tools.
+func ParseToolPolicy(coreTools, excludeTools []string) (*ToolPolicy, error) {
+	policy := &ToolPolicy{excluded: make(map[string][]string)}
+
+	if len(coreTools) > 0 {
+		policy.core = make(map[string][]string)
+		unrestricted := make(map[string]bool)
+		for _, entry := range coreTools {
+			name, pattern, err := parseToolSpec(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid coreTools entry: %w", err)
+			}
+			if pattern == "" {
+				unrestricted[name] = true
+				policy.core[name] = nil
+				continue
+			}
+			if !unrestricted[name] {
+				policy.core[name] = append(policy.core[name], pattern)
+			}
+		}
+	}
+
+	for _, entry := range excludeTools {
+		name, pattern, err := parseToolSpec(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid excludeTools entry: %w", err)
+		}
+		if pattern == "" {
+			policy.excluded[name] = nil
+			continue
+		}
+		if patterns, ok := policy.excluded[name]; !ok || patterns != nil {
+			policy.excluded[name] = append(patterns, pattern)
+		}
+	}
+	return policy, nil
+}
+
+// parseToolSpec splits "name(pattern)" into its parts; the pattern is optional.
+func parseToolSpec(entry string) (name, pattern string, err error) {
+	entry = strings.TrimSpace(entry)
+	open := strings.Index(entry, "(")
+	if open < 0 {
+		if entry == "" {
+			return "", "", fmt.Errorf("empty tool name")
+		}
+		return entry, "", nil
+	}
+	if !strings.HasSuffix(entry, ")") {
+		return "", "", fmt.Errorf("%q: missing closing parenthesis", entry)
+	}
+	name = strings.TrimSpace(entry[:open])
+	pattern = strings.TrimSpace(entry[open+1 : len(entry)-1])
+	if name == "" || pattern == "" {
+		return "", "", fmt.Errorf("%q: expected name(pattern)", entry)
+	}
+	return name, pattern, nil
+}
+
+// Allows reports whether the tool called name is available at all.
+func (p *ToolPolicy) Allows(name string) bool {
+	if p == nil {
+		return true
+	}
+	if patterns, ok := p.excluded[name]; ok && patterns == nil {
+		return false
+	}
+	if p.core == nil {
+		return true
+	}
+	_, ok := p.core[name]
+	return ok
+}
+
+// restricts reports whether calls to the tool called name are checked against patterns.
+func (p *ToolPolicy) restricts(name string) bool {
+	if p == nil {
+		return false
+	}
+	return len(p.core[name]) > 0 || len(p.excluded[name]) > 0
+}
+
+// checkArgument returns an error if value is not an allowed argument for the tool called name.
+func (p *ToolPolicy) checkArgument(name, value string) error {
+	segments := []string{value}
+	redirects := false
+	if name == ShellToolName {
+		var commands []string
+		if commands, redirects = scanShellCommand(value); len(commands) > 0 {
+			segments = commands
+		}
+	}
+	for _, segment := range segments {
+		for _, pattern := range p.excluded[name] {
+			if matchToolPattern(pattern, segment) {
+				return fmt.Errorf("%q is blocked by excludeTools entry %s(%s)", segment, name, pattern)
+			}
+		}
+	}
+	allowed := p.core[name]
+	if len(allowed) == 0 {
+		return nil
+	}
+	if redirects {
+		return fmt.Errorf("%q redirects to or from a file, which the coreTools setting for %s cannot allow", value, name)
+	}
+	for _, segment := range segments {
+		if !matchesAny(allowed, segment) {
+			return fmt.Errorf("%q is not allowed by the coreTools setting for %s", segment, name)
+		}
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matchToolPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitShellCommand splits a shell command into the commands it runs: those chained with
+// ";", "&&", "||", "|", "&" or newlines, grouped in parentheses, and substituted with
+// backticks or "$(". Separators within single quotes, and all but substitutions within
+// double quotes, are part of the command. The split errs on the side of more commands;
+// it is no substitute for a sandbox.
+func SplitShellCommand(command string) []string {
+	segments, _ := scanShellCommand(command)
+	return segments
+}
+
+// RedirectsShellCommand reports whether a shell command redirects input or output to or
+// from a file with "<", ">" or ">>" outside quotes, in any of the commands it runs.
+// Duplicating or closing a file descriptor, as in "2>&1", is not such a redirection.
+func RedirectsShellCommand(command string) bool {
+	_, redirects := scanShellCommand(command)
+	return redirects
+}
+
+// scanShellCommand returns the commands of SplitShellCommand and whether any redirects
+// to or from a file.
+func scanShellCommand(command string) (segments []string, redirects bool) {
+	var current strings.Builder
+	flush := func() {
+		if segment := strings.TrimSpace(current.String()); segment != "" {
+			segments = append(segments, segment)
+		}
+		current.Reset()
+	}
+
+	// A substitution is a command of its own; the command around it resumes when it ends.
+	type substitution struct {
+		quote, closer rune
+		outer         string
+	}
+	var quote rune
+	var open []substitution
+	begin := func(closer rune) {
+		open = append(open, substitution{quote: quote, closer: closer, outer: current.String()})
+		current.Reset()
+		quote = 0
+	}
+	end := func() {
+		flush()
+		last := open[len(open)-1]
+		open = open[:len(open)-1]
+		quote = last.quote
+		current.WriteString(last.outer)
+	}
+	closes := func(r rune) bool {
+		return quote == 0 && len(open) > 0 && open[len(open)-1].closer == r
+	}
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+		case r == '\\' && i+1 < len(runes):
+			current.WriteRune(r)
+			i++
+			r = runes[i]
+		case closes(r):
+			end()
+			continue
+		case r == '`':
+			begin('`')
+			continue
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			begin(')')
+			i++
+			continue
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case strings.ContainsRune(";&|()\n", r):
+			flush()
+			continue
+		case r == '<' || r == '>':
+			if !duplicatesDescriptor(runes[i+1:]) {
+				redirects = true
+				break
+			}
+			// The "&" of "2>&1" is not a separator.
+			current.WriteRune(r)
+			i++
+			r = runes[i]
+		}
+		current.WriteRune(r)
+	}
+	for len(open) > 0 {
+		end()
+	}
+	flush()
+	return segments, redirects
+}
+
+// duplicatesDescriptor reports whether rest, what follows a "<" or ">", makes it duplicate
+// or close a file descriptor, as "&1" or "&-" do.
+func duplicatesDescriptor(rest []rune) bool {
+	return len(rest) > 1 && rest[0] == '&' && (rest[1] == '-' || rest[1] >= '0' && rest[1] <= '9')
+}
+
+// CommandRoots returns the programs a shell command runs: the first word of every command
+// SplitShellCommand finds in it, after any variable assignments, without duplicates.
+func CommandRoots(command string) []string {
+	var roots []string
+	seen := make(map[string]bool)
+	for _, segment := range SplitShellCommand(command) {
+		words := strings.Fields(segment)
+		root := words[0]
+		for _, word := range words {
+			if !shellAssignment.MatchString(word) {
+				root = word
+				break
+			}
+		}
+		if !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// shellAssignment matches a variable assignment before a command, such as "LANG=C".
+var shellAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// matchToolPattern matches value against pattern, where "*" matches any text, including "/".
+func matchToolPattern(pattern, value string) bool {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(pattern, "*") {
+		return value == pattern || strings.HasPrefix(value, pattern+" ")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
+// restrictedTool enforces a ToolPolicy's argument patterns on a tool.
+type restrictedTool struct {
+	shared.Tool
+	policy *ToolPolicy
+}
+
+// ConfirmationDetails refuses disallowed calls before the user is asked about them.
+func (t *restrictedTool) ConfirmationDetails(args map[string]interface{}) (shared.ConfirmationDetails, error) {
+	if err := t.check(args); err != nil {
+		return shared.ConfirmationDetails{}, err
+	}
+	return confirmationDetails(t.Tool, shared.FunctionCall{Name: t.Name(), Args: args})
+}
+
+// Execute runs the tool if the call is allowed.
+func (t *restrictedTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	if err := t.check(args); err != nil {
+		return "", err
+	}
+	return t.Tool.Execute(ctx, args)
+}
+
+// check matches the tool's first required argument against the policy.
+func (t *restrictedTool) check(args map[string]interface{}) error {
+	required := t.FunctionDeclaration().Parameters.Required
+	if len(required) == 0 {
+		return fmt.Errorf("%s has no argument to check against its allowed patterns", t.Name())
+	}
+	value, ok := args[required[0]].(string)
+	if !ok {
+		return fmt.Errorf("missing or invalid '%s' argument", required[0])
+	}
+	return t.policy.checkArgument(t.Name(), value)
+}
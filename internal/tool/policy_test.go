@@ -0,0 +1,291 @@
+package tool
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/shared"
+)
+
+// commandTool is a mutating tool whose first required argument is "command".
+type commandTool struct{}
+
+func (c *commandTool) Name() string        { return "run_shell_command" }
+func (c *commandTool) Description() string { return "Runs a command." }
+func (c *commandTool) FunctionDeclaration() shared.FunctionDeclaration {
+	return shared.FunctionDeclaration{
+		Name: c.Name(),
+		Parameters: shared.Schema{
+			Type: shared.TypeObject,
+			Properties: map[string]shared.Schema{
+				"command":   {Type: shared.TypeString},
+				"directory": {Type: shared.TypeString},
+			},
+			Required: []string{"command"},
+		},
+	}
+}
+func (c *commandTool) IsReadOnly() bool { return false }
+func (c *commandTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
+	return "ran: " + args["command"].(string), nil
+}
+
+func TestParseToolPolicyErrors(t *testing.T) {
+	for _, entry := range []string{"", "run_shell_command(git *", "(git *)", "run_shell_command()"} {
+		if _, err := ParseToolPolicy([]string{entry}, nil); err == nil {
+			t.Errorf("Expected an error for coreTools entry %q", entry)
+		}
+		if _, err := ParseToolPolicy(nil, []string{entry}); err == nil {
+			t.Errorf("Expected an error for excludeTools entry %q", entry)
+		}
+	}
+}
+
+func TestMatchToolPattern(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"git", "git", true},
+		{"git", "git status", true},
+		{"git", "gitk", false},
+		{"git *", "git log -- src/main.go", true},
+		{"git *", "rm -rf /", false},
+		{"*", "anything", true},
+		{"go test *", "go test ./...", true},
+		{"a*a", "a", false},
+		{"rm *", "  rm -rf /tmp/x  ", true},
+	}
+	for _, tt := range tests {
+		if got := matchToolPattern(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("matchToolPattern(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSplitShellCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"git status", []string{"git status"}},
+		{"git status; rm -rf ~", []string{"git status", "rm -rf ~"}},
+		{"cd . && rm -rf x", []string{"cd .", "rm -rf x"}},
+		{"false || rm x", []string{"false", "rm x"}},
+		{"cat f | sh", []string{"cat f", "sh"}},
+		{"sleep 1 & rm x", []string{"sleep 1", "rm x"}},
+		{"git log\nrm x", []string{"git log", "rm x"}},
+		{"echo `rm x`", []string{"rm x", "echo"}},
+		{"echo $(rm x) done", []string{"rm x", "echo  done"}},
+		{"(cd sub; rm x)", []string{"cd sub", "rm x"}},
+		{`echo 'a; b' "c && d"`, []string{`echo 'a; b' "c && d"`}},
+		{`echo "$(date; rm x)" ; rm y`, []string{"date", "rm x", `echo ""`, "rm y"}},
+		{`echo a\;b`, []string{`echo a\;b`}},
+		{"echo $(unclosed; rm x", []string{"unclosed", "rm x", "echo"}},
+		{"make 2>&1 | tee log", []string{"make 2>&1", "tee log"}},
+		{"  ;; ", nil},
+	}
+	for _, tt := range tests {
+		if got := SplitShellCommand(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitShellCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectsShellCommand(t *testing.T) {
+	tests := map[string]bool{
+		"git log":                   false,
+		"git log > out.txt":         true,
+		"git log >> out.txt":        true,
+		"git log>out.txt":           true,
+		"sort < in.txt":             true,
+		"git log &> out.txt":        true,
+		"cat <<EOF":                 true,
+		"echo $(git log > out.txt)": true,
+		"git log 2>&1":              false,
+		"git log >&2 2>&-":          false,
+		`echo 'a > b' "c < d"`:      false,
+		`echo a\>b`:                 false,
+	}
+	for command, want := range tests {
+		if got := RedirectsShellCommand(command); got != want {
+			t.Errorf("RedirectsShellCommand(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
+
+func TestCommandRoots(t *testing.T) {
+	tests := map[string]string{
+		"git status":                    "git",
+		"git status && git diff | less": "git less",
+		"LANG=C FOO=1 sort x; rm -rf /": "sort rm",
+		"echo $(rm x) `curl a`":         "rm curl echo",
+		"A=1":                           "A=1",
+		"  ":                            "",
+	}
+	for command, want := range tests {
+		if got := strings.Join(CommandRoots(command), " "); got != want {
+			t.Errorf("CommandRoots(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
+
+func TestToolPolicyChainedCommands(t *testing.T) {
+	policy, err := ParseToolPolicy([]string{"run_shell_command(git *)", "run_shell_command(cd)"}, []string{"run_shell_command(rm *)"})
+	if err != nil {
+		t.Fatalf("ParseToolPolicy failed: %v", err)
+	}
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{"git status && git diff", true},
+		{"cd sub; git log | git shortlog", true},
+		{"git status; rm -rf ~", false},
+		{"git status && touch x", false},
+		{"git status || touch x", false},
+		{"git log | sh", false},
+		{"git fetch & touch x", false},
+		{"git status\ntouch x", false},
+		{"git log `touch x`", false},
+		{"git log $(touch x)", false},
+		{"git commit -m 'a; touch x'", true},
+		{"cd . && rm -rf x", false},
+		{"git log > ~/.bashrc", false},
+		{"git log >> ~/.bashrc", false},
+		{"git apply < patch.diff", false},
+		{"git log 2>&1 | git shortlog", true},
+		{`git commit -m "a > b"`, true},
+	}
+	for _, tt := range tests {
+		err := policy.checkArgument("run_shell_command", tt.command)
+		if (err == nil) != tt.allowed {
+			t.Errorf("checkArgument(%q) = %v, want allowed: %v", tt.command, err, tt.allowed)
+		}
+	}
+
+	// Without a coreTools pattern, the excluded command is found in any segment.
+	policy, err = ParseToolPolicy(nil, []string{"run_shell_command(rm *)"})
+	if err != nil {
+		t.Fatalf("ParseToolPolicy failed: %v", err)
+	}
+	for _, command := range []string{"cd . && rm -rf x", "true; rm x", "echo $(rm x)", "(rm x)"} {
+		if err := policy.checkArgument("run_shell_command", command); err == nil || !strings.Contains(err.Error(), "excludeTools") {
+			t.Errorf("Expected %q to be blocked, got %v", command, err)
+		}
+	}
+	// Other tools' arguments are matched as a whole.
+	policy, err = ParseToolPolicy([]string{"other(a *)"}, nil)
+	if err != nil {
+		t.Fatalf("ParseToolPolicy failed: %v", err)
+	}
+	if err := policy.checkArgument("other", "a b; c"); err != nil {
+		t.Errorf("Expected the argument of another tool to be matched whole, got %v", err)
+	}
+}
+
+func declaredNames(registry *ToolRegistry) string {
+	var names []string
+	for _, declaration := range registry.GetFunctionDeclarations() {
+		names = append(names, declaration.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func TestNewToolRegistryFromSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings config.Settings
+		want     string
+	}{
+		{"defaults", config.Settings{}, "edit_file glob list_files read_file run_shell_command search_file_content write_file"},
+		{"allowlist", config.Settings{CoreTools: []string{"read_file", "list_files"}}, "list_files read_file"},
+		{"denylist", config.Settings{ExcludeTools: []string{"write_file", "edit_file", "run_shell_command"}}, "glob list_files read_file search_file_content"},
+		{"both", config.Settings{CoreTools: []string{"read_file", "write_file"}, ExcludeTools: []string{"write_file"}}, "read_file"},
+		{"pattern keeps tool", config.Settings{ExcludeTools: []string{"run_shell_command(rm *)"}}, "edit_file glob list_files read_file run_shell_command search_file_content write_file"},
+	}
+	for _, tt := range tests {
+		registry, err := NewToolRegistryFromSettings(tt.settings)
+		if err != nil {
+			t.Fatalf("%s: NewToolRegistryFromSettings failed: %v", tt.name, err)
+		}
+		if got := declaredNames(registry); got != tt.want {
+			t.Errorf("%s: expected tools %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	if _, err := NewToolRegistryFromSettings(config.Settings{CoreTools: []string{"read_file("}}); err == nil {
+		t.Error("Expected an error for an invalid coreTools entry")
+	}
+}
+
+func TestToolPolicyArgumentPatterns(t *testing.T) {
+	policy, err := ParseToolPolicy(
+		[]string{"run_shell_command(git *)", "run_shell_command(go test)"},
+		[]string{"run_shell_command(git push *)"},
+	)
+	if err != nil {
+		t.Fatalf("ParseToolPolicy failed: %v", err)
+	}
+	registry := NewToolRegistryWithPolicy(policy)
+	registry.RegisterTool(&commandTool{})
+	registry.RegisterTool(&MockTool{name: "other"})
+
+	if got := declaredNames(registry); got != "run_shell_command" {
+		t.Errorf("Expected only run_shell_command to be declared, got %q", got)
+	}
+
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{"git status", true},
+		{"go test ./...", true},
+		{"git push origin main", false},
+		{"rm -rf /", false},
+	}
+	for _, tt := range tests {
+		response := ExecuteFunctionCall(context.Background(), registry, shared.FunctionCall{
+			Name: "run_shell_command",
+			Args: map[string]interface{}{"command": tt.command},
+		})
+		if tt.allowed && response.Response["output"] != "ran: "+tt.command {
+			t.Errorf("Expected %q to run, got %v", tt.command, response.Response)
+		}
+		if !tt.allowed && response.Response["error"] == nil {
+			t.Errorf("Expected %q to be refused, got %v", tt.command, response.Response)
+		}
+	}
+}
+
+func TestRestrictedToolRefusedBeforeConfirmation(t *testing.T) {
+	policy, err := ParseToolPolicy([]string{"run_shell_command(git *)"}, nil)
+	if err != nil {
+		t.Fatalf("ParseToolPolicy failed: %v", err)
+	}
+	registry := NewToolRegistryWithPolicy(policy)
+	registry.RegisterTool(&commandTool{})
+
+	confirmer := &scriptedConfirmer{decisions: []Decision{Approve}}
+	executor := NewExecutor(registry, 1)
+	executor.SetConfirmer(confirmer)
+	responses := executor.Execute(context.Background(), []shared.FunctionCall{
+		{Name: "run_shell_command", Args: map[string]interface{}{"command": "rm -rf /"}},
+		{Name: "run_shell_command", Args: map[string]interface{}{"command": "git status"}},
+	})
+
+	if responses[0].Response["error"] == nil {
+		t.Errorf("Expected the disallowed command to be refused, got %v", responses[0].Response)
+	}
+	if responses[1].Response["output"] != "ran: git status" {
+		t.Errorf("Expected the allowed command to run, got %v", responses[1].Response)
+	}
+	if len(confirmer.asked) != 1 || confirmer.asked[0].Details != "command: git status\n" {
+		t.Errorf("Expected confirmation only for the allowed command, got %+v", confirmer.asked)
+	}
+}
@@ -38,6 +38,11 @@ func (t *ReadTool) FunctionDeclaration() shared.FunctionDeclaration {
 	}
 }
 
+// IsReadOnly reports whether the tool only reads state.
+func (t *ReadTool) IsReadOnly() bool {
+	return true
+}
+
 // Execute executes the read_file tool.
 func (t *ReadTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
 	path, ok := args["path"].(string)
@@ -0,0 +1,215 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gemini-cli-go/internal/process"
+	"gemini-cli-go/internal/shared"
+)
+
+const (
+	// DefaultShellTimeout is how long a command may run when ShellTool.Timeout is not set.
+	DefaultShellTimeout = 2 * time.Minute
+	// DefaultShellMaxOutput is how many bytes of stdout and of stderr are kept when ShellTool.MaxOutputBytes is not set.
+	DefaultShellMaxOutput = 16 * 1024
+)
+
+// ShellToolName is the name of ShellTool.
+const ShellToolName = "run_shell_command"
+
+// ShellTool implements the Tool interface for running shell commands.
+type ShellTool struct {
+	// Timeout bounds how long a command may run. Zero selects DefaultShellTimeout.
+	Timeout time.Duration
+	// MaxOutputBytes bounds how much of stdout and of stderr is returned. Zero selects DefaultShellMaxOutput.
+	MaxOutputBytes int
+}
+
+// Name returns the name of the tool.
+func (t *ShellTool) Name() string {
+	return ShellToolName
+}
+
+// Description returns a description of the tool.
+func (t *ShellTool) Description() string {
+	return "Runs a shell command and returns its stdout, stderr and exit code. Use it to build, test, or inspect the project (e.g. 'go test ./...', 'git status')."
+}
+
+// FunctionDeclaration returns the function declaration for the tool.
+func (t *ShellTool) FunctionDeclaration() shared.FunctionDeclaration {
+	return shared.FunctionDeclaration{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: shared.Schema{
+			Type: shared.TypeObject,
+			Properties: map[string]shared.Schema{
+				"command": {
+					Type:        shared.TypeString,
+					Description: "The command to run with the system shell.",
+				},
+				"directory": {
+					Type:        shared.TypeString,
+					Description: "The directory to run the command in. Defaults to the current directory.",
+				},
+			},
+			Required: []string{"command"},
+		},
+	}
+}
+
+// IsReadOnly reports whether the tool only reads state.
+func (t *ShellTool) IsReadOnly() bool {
+	return false
+}
+
+// ConfirmationDetails shows the command, where it will run, and which commands
+// always allowing it allows.
+func (t *ShellTool) ConfirmationDetails(args map[string]interface{}) (shared.ConfirmationDetails, error) {
+	command, directory, err := t.parseArgs(args)
+	if err != nil {
+		return shared.ConfirmationDetails{}, err
+	}
+	allows := strings.Join(CommandRoots(command), ", ")
+	if RedirectsShellCommand(command) {
+		allows += ", except with redirections, which are always confirmed"
+	}
+	return shared.ConfirmationDetails{
+		Title:   "Run shell command",
+		Details: fmt.Sprintf("$ %s\n(in %s; always allowing it allows: %s)\n", command, directory, allows),
+	}, nil
+}
+
+// Execute executes the run_shell_command tool.
+// A non-zero exit code is reported in the output rather than as an error;
+// failing to start, timing out or being cancelled are errors.
+func (t *ShellTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	command, directory, err := t.parseArgs(args)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = DefaultShellTimeout
+	}
+	maxOutput := t.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = DefaultShellMaxOutput
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := newBoundedBuffer(maxOutput)
+	stderr := newBoundedBuffer(maxOutput)
+	cmd := process.Shell(runCtx, command)
+	cmd.Dir = directory
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	runErr := cmd.Run()
+	output := formatShellOutput(command, directory, stdout, stderr, cmd.ProcessState)
+
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("command timed out after %s\n%s", timeout, output)
+	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("command cancelled: %w", ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		return "", fmt.Errorf("failed to run command: %w", runErr)
+	}
+	return output, nil
+}
+
+// parseArgs validates the arguments and resolves the working directory.
+func (t *ShellTool) parseArgs(args map[string]interface{}) (command, directory string, err error) {
+	command, ok := args["command"].(string)
+	if !ok || strings.TrimSpace(command) == "" {
+		return "", "", fmt.Errorf("missing or invalid 'command' argument")
+	}
+
+	directory, _ = args["directory"].(string)
+	if directory == "" {
+		directory = "."
+	}
+	directory, err = filepath.Abs(directory)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid directory %s: %w", directory, err)
+	}
+	info, err := os.Stat(directory)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid directory %s: %w", directory, err)
+	}
+	if !info.IsDir() {
+		return "", "", fmt.Errorf("invalid directory %s: not a directory", directory)
+	}
+	return command, directory, nil
+}
+
+func formatShellOutput(command, directory string, stdout, stderr *boundedBuffer, state *os.ProcessState) string {
+	exitCode := -1
+	if state != nil {
+		exitCode = state.ExitCode()
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Command: %s\nDirectory: %s\n", command, directory)
+	fmt.Fprintf(&builder, "Stdout:\n%s\n", stdout.String())
+	fmt.Fprintf(&builder, "Stderr:\n%s\n", stderr.String())
+	fmt.Fprintf(&builder, "Exit code: %d", exitCode)
+	return builder.String()
+}
+
+// boundedBuffer keeps the beginning and the end of what is written to it,
+// dropping the middle once more than limit bytes have been written.
+type boundedBuffer struct {
+	limit   int
+	head    []byte
+	tail    []byte
+	written int
+}
+
+func newBoundedBuffer(limit int) *boundedBuffer {
+	return &boundedBuffer{limit: limit}
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	b.written += len(p)
+	half := b.limit / 2
+
+	data := p
+	if room := half - len(b.head); room > 0 {
+		n := min(room, len(data))
+		b.head = append(b.head, data[:n]...)
+		data = data[n:]
+	}
+	b.tail = append(b.tail, data...)
+	// Trim lazily so that writes stay amortized O(len(p)).
+	if len(b.tail) > 2*(b.limit-half) {
+		b.tail = append([]byte(nil), b.tail[len(b.tail)-(b.limit-half):]...)
+	}
+	return len(p), nil
+}
+
+// String returns the kept output, marking where bytes were dropped.
+func (b *boundedBuffer) String() string {
+	keep := b.limit - len(b.head)
+	tail := b.tail
+	if len(tail) > keep {
+		tail = tail[len(tail)-keep:]
+	}
+	dropped := b.written - len(b.head) - len(tail)
+	if dropped <= 0 {
+		return string(b.head) + string(tail)
+	}
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", b.head, dropped, tail)
+}
@@ -0,0 +1,174 @@
+//go:build !windows
+
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gemini-cli-go/internal/config"
+	"gemini-cli-go/internal/shared"
+)
+
+func TestShellTool(t *testing.T) {
+	tool := &ShellTool{}
+
+	if tool.Name() != "run_shell_command" {
+		t.Errorf("Expected name 'run_shell_command', got '%s'", tool.Name())
+	}
+	if tool.IsReadOnly() {
+		t.Errorf("Expected run_shell_command IsReadOnly() to be false")
+	}
+	fd := tool.FunctionDeclaration()
+	if len(fd.Parameters.Required) != 1 || fd.Parameters.Required[0] != "command" {
+		t.Errorf("Expected 'command' to be the only required parameter, got %v", fd.Parameters.Required)
+	}
+}
+
+func TestShellToolExecute(t *testing.T) {
+	tool := &ShellTool{}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "marker.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command":   "ls; echo oops >&2; exit 3",
+		"directory": dir,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	for _, want := range []string{"Stdout:\nmarker.txt\n", "Stderr:\noops\n", "Exit code: 3"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	// Invalid arguments
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{}); err == nil {
+		t.Error("Expected error for missing command argument")
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command":   "true",
+		"directory": filepath.Join(dir, "missing"),
+	}); err == nil {
+		t.Error("Expected error for a missing directory")
+	}
+}
+
+func TestShellToolRefusesChainedCommands(t *testing.T) {
+	registry, err := NewToolRegistryFromSettings(config.Settings{CoreTools: []string{"run_shell_command(echo)"}})
+	if err != nil {
+		t.Fatalf("NewToolRegistryFromSettings failed: %v", err)
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker.txt")
+
+	for _, command := range []string{
+		"echo hi; touch " + marker,
+		"echo hi && touch " + marker,
+		"echo $(touch " + marker + ")",
+		"echo `touch " + marker + "`",
+	} {
+		response := ExecuteFunctionCall(context.Background(), registry, shared.FunctionCall{
+			Name: "run_shell_command",
+			Args: map[string]interface{}{"command": command, "directory": dir},
+		})
+		if response.Response["error"] == nil {
+			t.Errorf("Expected %q to be refused, got %v", command, response.Response)
+		}
+		if _, err := os.Stat(marker); !os.IsNotExist(err) {
+			t.Fatalf("Expected %q not to run, but the marker exists", command)
+		}
+	}
+
+	response := ExecuteFunctionCall(context.Background(), registry, shared.FunctionCall{
+		Name: "run_shell_command",
+		Args: map[string]interface{}{"command": "echo 'a; touch b'", "directory": dir},
+	})
+	if output, _ := response.Response["output"].(string); !strings.Contains(output, "a; touch b") {
+		t.Errorf("Expected the quoted separator to be allowed, got %v", response.Response)
+	}
+}
+
+func TestShellToolTimeoutKillsProcessGroup(t *testing.T) {
+	tool := &ShellTool{Timeout: 200 * time.Millisecond}
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+
+	start := time.Now()
+	_, err := tool.Execute(context.Background(), map[string]interface{}{
+		// The background child would keep the output pipe open if only the shell were killed.
+		"command": "sleep 30 & echo $! > " + pidFile + "; wait",
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the command to be killed promptly, took %s", elapsed)
+	}
+
+	pid, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("Failed to read child pid: %v", err)
+	}
+	// Give init a moment to reap the killed child.
+	time.Sleep(100 * time.Millisecond)
+	status, err := os.ReadFile(filepath.Join("/proc", strings.TrimSpace(string(pid)), "status"))
+	if err == nil && !strings.Contains(string(status), "zombie") {
+		t.Errorf("Expected background child %s to be killed", strings.TrimSpace(string(pid)))
+	}
+}
+
+func TestShellToolCancel(t *testing.T) {
+	tool := &ShellTool{}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := tool.Execute(ctx, map[string]interface{}{"command": "sleep 30"})
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+}
+
+func TestShellToolTruncatesOutput(t *testing.T) {
+	tool := &ShellTool{MaxOutputBytes: 100}
+	output, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command": "echo START; seq 1 10000; echo END",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(output, "START") || !strings.Contains(output, "END") {
+		t.Errorf("Expected the start and end of the output to be kept, got:\n%s", output)
+	}
+	if !strings.Contains(output, "bytes truncated") {
+		t.Errorf("Expected a truncation marker, got:\n%s", output)
+	}
+	if len(output) > 500 {
+		t.Errorf("Expected output to be truncated, got %d bytes", len(output))
+	}
+}
+
+func TestBoundedBuffer(t *testing.T) {
+	buffer := newBoundedBuffer(10)
+	buffer.Write([]byte("abc"))
+	if buffer.String() != "abc" {
+		t.Errorf("Expected 'abc', got %q", buffer.String())
+	}
+	for i := 0; i < 100; i++ {
+		buffer.Write([]byte("0123456789"))
+	}
+	buffer.Write([]byte("xyz"))
+	want := "abc01\n... [996 bytes truncated] ...\n89xyz"
+	if got := buffer.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -1,23 +1,69 @@
 package tool
 
 import (
+	"fmt"
+
+	"gemini-cli-go/internal/config"
 	"gemini-cli-go/internal/shared"
 )
 
 // ToolRegistry manages available tools.
 type ToolRegistry struct {
-	tools map[string]shared.Tool
+	tools  map[string]shared.Tool
+	policy *ToolPolicy
 }
 
-// NewToolRegistry creates a new ToolRegistry.
+// NewToolRegistry creates a new ToolRegistry that accepts every tool.
 func NewToolRegistry() *ToolRegistry {
+	return NewToolRegistryWithPolicy(nil)
+}
+
+// NewToolRegistryWithPolicy creates a new ToolRegistry whose tools are restricted by policy.
+// A nil policy accepts every tool.
+func NewToolRegistryWithPolicy(policy *ToolPolicy) *ToolRegistry {
 	return &ToolRegistry{
-		tools: make(map[string]shared.Tool),
+		tools:  make(map[string]shared.Tool),
+		policy: policy,
+	}
+}
+
+// NewToolRegistryFromSettings creates a registry with the built-in tools allowed by
+// the coreTools and excludeTools settings.
+func NewToolRegistryFromSettings(settings config.Settings) (*ToolRegistry, error) {
+	policy, err := ParseToolPolicy(settings.CoreTools, settings.ExcludeTools)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tool settings: %w", err)
+	}
+	registry := NewToolRegistryWithPolicy(policy)
+	for _, builtin := range BuiltinTools(settings) {
+		registry.RegisterTool(builtin)
+	}
+	return registry, nil
+}
+
+// BuiltinTools returns a new instance of every built-in tool, configured by settings.
+func BuiltinTools(settings config.Settings) []shared.Tool {
+	disableGitIgnore := !settings.RespectsGitIgnore()
+	return []shared.Tool{
+		&ReadTool{},
+		&ListFilesTool{DisableGitIgnore: disableGitIgnore},
+		&GrepTool{DisableGitIgnore: disableGitIgnore},
+		&GlobTool{DisableGitIgnore: disableGitIgnore},
+		&WriteFileTool{},
+		&EditFileTool{},
+		&ShellTool{},
 	}
 }
 
 // RegisterTool registers a tool with the registry.
+// Tools denied by the registry's policy are silently dropped.
 func (tr *ToolRegistry) RegisterTool(tool shared.Tool) {
+	if !tr.policy.Allows(tool.Name()) {
+		return
+	}
+	if tr.policy.restricts(tool.Name()) {
+		tool = &restrictedTool{Tool: tool, policy: tr.policy}
+	}
 	tr.tools[tool.Name()] = tool
 }
 
@@ -34,4 +80,4 @@ func (tr *ToolRegistry) GetFunctionDeclarations() []shared.FunctionDeclaration {
 		declarations = append(declarations, tool.FunctionDeclaration())
 	}
 	return declarations
-}
\ No newline at end of file
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"gemini-cli-go/internal/shared"
 )
@@ -105,6 +107,11 @@ func TestReadTool(t *testing.T) {
 		t.Errorf("Expected name 'read_file', got '%s'", tool.Name())
 	}
 
+	// Test IsReadOnly
+	if !tool.IsReadOnly() {
+		t.Errorf("Expected read_file IsReadOnly() to be true")
+	}
+
 	// Test Description
 	expectedDesc := "Reads the content of a specified file from the local filesystem."
 	if tool.Description() != expectedDesc {
@@ -186,6 +193,11 @@ func TestWriteFileTool(t *testing.T) {
 		t.Errorf("Expected name 'write_file', got '%s'", tool.Name())
 	}
 
+	// Test IsReadOnly
+	if tool.IsReadOnly() {
+		t.Errorf("Expected write_file IsReadOnly() to be false")
+	}
+
 	// Test Description
 	expectedDesc := "Writes content to a specified file. If the file does not exist, it will be created. If it exists, its content will be truncated."
 	if tool.Description() != expectedDesc {
@@ -269,6 +281,345 @@ func TestWriteFileToolExecute(t *testing.T) {
 	}
 }
 
+func TestWriteFileToolConfirmationDetails(t *testing.T) {
+	tool := &WriteFileTool{}
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+
+	// New file
+	details, err := tool.ConfirmationDetails(map[string]interface{}{"filePath": testFile, "content": "hello\n"})
+	if err != nil {
+		t.Fatalf("ConfirmationDetails failed: %v", err)
+	}
+	if details.Title != "Create "+testFile {
+		t.Errorf("Unexpected title %q", details.Title)
+	}
+	if !containsString(details.Details, "+hello") {
+		t.Errorf("Expected diff to add 'hello', got:\n%s", details.Details)
+	}
+
+	// Existing file
+	if err := os.WriteFile(testFile, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	details, err = tool.ConfirmationDetails(map[string]interface{}{"filePath": testFile, "content": "goodbye\n"})
+	if err != nil {
+		t.Fatalf("ConfirmationDetails failed: %v", err)
+	}
+	if details.Title != "Write to "+testFile {
+		t.Errorf("Unexpected title %q", details.Title)
+	}
+	if !containsString(details.Details, "-hello") || !containsString(details.Details, "+goodbye") {
+		t.Errorf("Expected diff from 'hello' to 'goodbye', got:\n%s", details.Details)
+	}
+
+	// Missing argument
+	if _, err := tool.ConfirmationDetails(map[string]interface{}{"filePath": testFile}); err == nil {
+		t.Error("Expected error for missing content argument")
+	}
+}
+
+func TestEditFileTool(t *testing.T) {
+	tool := &EditFileTool{}
+
+	if tool.Name() != "edit_file" {
+		t.Errorf("Expected name 'edit_file', got '%s'", tool.Name())
+	}
+	if tool.IsReadOnly() {
+		t.Errorf("Expected edit_file IsReadOnly() to be false")
+	}
+	fd := tool.FunctionDeclaration()
+	if len(fd.Parameters.Required) != 3 || fd.Parameters.Required[0] != "filePath" {
+		t.Errorf("Expected required parameters filePath, oldString, newString, got %v", fd.Parameters.Required)
+	}
+}
+
+func TestEditFileToolExecute(t *testing.T) {
+	tool := &EditFileTool{}
+	testFile := filepath.Join(t.TempDir(), "main.go")
+	original := "func main() {\n\tfmt.Println(\"hi\")\n\tfmt.Println(\"hi\")\n}\n"
+
+	reset := func() {
+		if err := os.WriteFile(testFile, []byte(original), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edit := func(args map[string]interface{}) (string, error) {
+		args["filePath"] = testFile
+		return tool.Execute(context.Background(), args)
+	}
+	content := func() string {
+		data, err := os.ReadFile(testFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// Ambiguous match is refused and the file is left alone.
+	reset()
+	_, err := edit(map[string]interface{}{"oldString": "fmt.Println(\"hi\")", "newString": "fmt.Println(\"bye\")"})
+	if err == nil || !containsString(err.Error(), "occurs 2 times") {
+		t.Errorf("Expected an ambiguity error, got %v", err)
+	}
+	if content() != original {
+		t.Errorf("Expected file to be unchanged after a failed edit")
+	}
+
+	// Replacing every occurrence when the count is given.
+	output, err := edit(map[string]interface{}{
+		"oldString":            "fmt.Println(\"hi\")",
+		"newString":            "fmt.Println(\"bye\")",
+		"expectedReplacements": float64(2),
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if content() != strings.ReplaceAll(original, "hi", "bye") {
+		t.Errorf("Unexpected content after edit:\n%s", content())
+	}
+	for _, want := range []string{"(2 replacements)", "-\tfmt.Println(\"hi\")", "+\tfmt.Println(\"bye\")"} {
+		if !containsString(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	// Whitespace must match exactly: spaces do not match the tab indentation.
+	reset()
+	_, err = edit(map[string]interface{}{"oldString": "func main() {\n    fmt", "newString": "x"})
+	if err == nil || !containsString(err.Error(), "not found") {
+		t.Errorf("Expected a not-found error for mismatched indentation, got %v", err)
+	}
+
+	// Including surrounding context makes the match unique.
+	_, err = edit(map[string]interface{}{
+		"oldString": "\tfmt.Println(\"hi\")\n}",
+		"newString": "\tfmt.Println(\"last\")\n}",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !containsString(content(), "\"hi\")\n\tfmt.Println(\"last\")") {
+		t.Errorf("Expected only the last call to change, got:\n%s", content())
+	}
+
+	// Wrong expected count
+	reset()
+	_, err = edit(map[string]interface{}{"oldString": "func main", "newString": "func run", "expectedReplacements": float64(3)})
+	if err == nil {
+		t.Error("Expected an error for a wrong expectedReplacements count")
+	}
+
+	// No-op and invalid arguments
+	if _, err := edit(map[string]interface{}{"oldString": "main", "newString": "main"}); err == nil {
+		t.Error("Expected an error for identical oldString and newString")
+	}
+	if _, err := edit(map[string]interface{}{"oldString": "main"}); err == nil {
+		t.Error("Expected an error for missing newString")
+	}
+	if _, err := edit(map[string]interface{}{"oldString": "main", "newString": "x", "expectedReplacements": float64(0.5)}); err == nil {
+		t.Error("Expected an error for a fractional expectedReplacements")
+	}
+
+	// Creating a new file with an empty oldString
+	newFile := filepath.Join(filepath.Dir(testFile), "new.txt")
+	output, err = tool.Execute(context.Background(), map[string]interface{}{"filePath": newFile, "oldString": "", "newString": "hello\n"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !containsString(output, "+hello") {
+		t.Errorf("Expected a diff for the new file, got:\n%s", output)
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"filePath": newFile, "oldString": "", "newString": "again"}); err == nil {
+		t.Error("Expected an error for an empty oldString on an existing file")
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"filePath": newFile + ".missing", "oldString": "a", "newString": "b"}); err == nil {
+		t.Error("Expected an error for editing a missing file")
+	}
+}
+
+func TestGrepTool(t *testing.T) {
+	tool := &GrepTool{}
+
+	if tool.Name() != "search_file_content" {
+		t.Errorf("Expected name 'search_file_content', got '%s'", tool.Name())
+	}
+	if !tool.IsReadOnly() {
+		t.Errorf("Expected search_file_content IsReadOnly() to be true")
+	}
+	fd := tool.FunctionDeclaration()
+	if len(fd.Parameters.Required) != 1 || fd.Parameters.Required[0] != "pattern" {
+		t.Errorf("Expected 'pattern' to be the only required parameter, got %v", fd.Parameters.Required)
+	}
+}
+
+func TestGrepToolExecute(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"main.go":         "package main\n\nfunc main() {\n\tgreet()\n}\n",
+		"greet.go":        "package main\n\nfunc greet() {}\n",
+		"docs/notes.txt":  "call greet() first\n",
+		"internal/a/b.go": "package a\n\nfunc greetAll() {}\n",
+		"image.bin":       "func greet\x00binary",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(tmpDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := &GrepTool{}
+	output, err := tool.Execute(context.Background(), map[string]interface{}{"pattern": `func greet\w*\(`, "dir": tmpDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	for _, want := range []string{
+		filepath.Join(tmpDir, "greet.go") + ":3: func greet() {}",
+		filepath.Join(tmpDir, "internal/a/b.go") + ":3: func greetAll() {}",
+	} {
+		if !containsString(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	if containsString(output, "image.bin") {
+		t.Errorf("Expected binary files to be skipped, got:\n%s", output)
+	}
+
+	// include glob on the base name
+	output, err = tool.Execute(context.Background(), map[string]interface{}{"pattern": "greet", "include": "*.txt", "dir": tmpDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !containsString(output, "Found 1 matches") || !containsString(output, "notes.txt:1: call greet() first") {
+		t.Errorf("Expected only the .txt match, got:\n%s", output)
+	}
+
+	// include glob on the relative path
+	output, err = tool.Execute(context.Background(), map[string]interface{}{"pattern": "greet", "include": "internal/*/*.go", "dir": tmpDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !containsString(output, "Found 1 matches") || !containsString(output, "b.go:3:") {
+		t.Errorf("Expected only the internal match, got:\n%s", output)
+	}
+
+	// No matches
+	output, err = tool.Execute(context.Background(), map[string]interface{}{"pattern": "nothing here", "dir": tmpDir})
+	if err != nil || !containsString(output, "No matches found") {
+		t.Errorf("Expected no matches, got %q, %v", output, err)
+	}
+
+	// Invalid arguments
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"pattern": "(", "dir": tmpDir}); err == nil {
+		t.Error("Expected error for an invalid regular expression")
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"dir": tmpDir}); err == nil {
+		t.Error("Expected error for missing pattern argument")
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"pattern": "x", "include": "[", "dir": tmpDir}); err == nil {
+		t.Error("Expected error for an invalid include glob")
+	}
+}
+
+func TestGrepToolCapsMatches(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := strings.Repeat("match\n", 50)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := &GrepTool{MaxMatches: 60}
+	output, err := tool.Execute(context.Background(), map[string]interface{}{"pattern": "match", "dir": tmpDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := strings.Count(output, ": match"); got != 60 {
+		t.Errorf("Expected 60 matches, got %d", got)
+	}
+	if !containsString(output, "results truncated at 60 matches") {
+		t.Errorf("Expected a truncation notice, got:\n%s", output)
+	}
+}
+
+func TestGlobTool(t *testing.T) {
+	tool := &GlobTool{}
+
+	if tool.Name() != "glob" {
+		t.Errorf("Expected name 'glob', got '%s'", tool.Name())
+	}
+	if !tool.IsReadOnly() {
+		t.Errorf("Expected glob IsReadOnly() to be true")
+	}
+}
+
+func TestGlobToolExecute(t *testing.T) {
+	tmpDir := t.TempDir()
+	now := time.Now()
+	files := []struct {
+		path string
+		age  time.Duration
+	}{
+		{"internal/old_test.go", 3 * time.Hour},
+		{"internal/tool/new_test.go", time.Hour},
+		{"internal/tool/newest_test.go", 0},
+		{"internal/tool/tool.go", 0},
+		{"main_test.go", 0},
+	}
+	for _, file := range files {
+		fullPath := filepath.Join(tmpDir, file.path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte("package x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(-file.age)
+		if err := os.Chtimes(fullPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := &GlobTool{}
+	output, err := tool.Execute(context.Background(), map[string]interface{}{"pattern": "internal/**/*_test.go", "dir": tmpDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "Found 3 files matching \"internal/**/*_test.go\" in " + tmpDir + ", most recently modified first:\n" +
+		filepath.Join(tmpDir, "internal/tool/newest_test.go") + "\n" +
+		filepath.Join(tmpDir, "internal/tool/new_test.go") + "\n" +
+		filepath.Join(tmpDir, "internal/old_test.go") + "\n"
+	if output != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", output, want)
+	}
+
+	// Capped results
+	tool = &GlobTool{MaxResults: 2}
+	output, err = tool.Execute(context.Background(), map[string]interface{}{"pattern": "**/*.go", "dir": tmpDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !containsString(output, "Found 5 files") || !containsString(output, "showing the first 2 of 5 files") {
+		t.Errorf("Expected capped results, got:\n%s", output)
+	}
+
+	// No matches and invalid arguments
+	output, err = tool.Execute(context.Background(), map[string]interface{}{"pattern": "*.py", "dir": tmpDir})
+	if err != nil || !containsString(output, "No files found") {
+		t.Errorf("Expected no files, got %q, %v", output, err)
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"dir": tmpDir}); err == nil {
+		t.Error("Expected error for missing pattern argument")
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"pattern": "[", "dir": tmpDir}); err == nil {
+		t.Error("Expected error for a malformed pattern")
+	}
+}
+
 func TestListFilesTool(t *testing.T) {
 	tool := &ListFilesTool{}
 
@@ -277,6 +628,11 @@ func TestListFilesTool(t *testing.T) {
 		t.Errorf("Expected name 'list_files', got '%s'", tool.Name())
 	}
 
+	// Test IsReadOnly
+	if !tool.IsReadOnly() {
+		t.Errorf("Expected list_files IsReadOnly() to be true")
+	}
+
 	// Test Description
 	expectedDesc := "Lists files in a directory, optionally filtered by extension."
 	if tool.Description() != expectedDesc {
@@ -363,6 +719,7 @@ func TestListFilesToolExecute(t *testing.T) {
 type MockTool struct {
 	name        string
 	description string
+	readOnly    bool
 }
 
 func (m *MockTool) Name() string {
@@ -383,6 +740,10 @@ func (m *MockTool) FunctionDeclaration() shared.FunctionDeclaration {
 	}
 }
 
+func (m *MockTool) IsReadOnly() bool {
+	return m.readOnly
+}
+
 func (m *MockTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
 	return "mock output", nil
 }
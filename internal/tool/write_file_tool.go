@@ -2,9 +2,12 @@ package tool
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"gemini-cli-go/internal/diff"
 	"gemini-cli-go/internal/filesystem"
 	"gemini-cli-go/internal/shared"
+	"os"
 )
 
 // WriteFileTool implements the Tool interface for writing content to a file.
@@ -42,6 +45,38 @@ func (t *WriteFileTool) FunctionDeclaration() shared.FunctionDeclaration {
 	}
 }
 
+// IsReadOnly reports whether the tool only reads state.
+func (t *WriteFileTool) IsReadOnly() bool {
+	return false
+}
+
+// ConfirmationDetails shows the change to the file as a unified diff.
+func (t *WriteFileTool) ConfirmationDetails(args map[string]interface{}) (shared.ConfirmationDetails, error) {
+	filePath, ok := args["filePath"].(string)
+	if !ok {
+		return shared.ConfirmationDetails{}, fmt.Errorf("missing or invalid 'filePath' argument")
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return shared.ConfirmationDetails{}, fmt.Errorf("missing or invalid 'content' argument")
+	}
+
+	oldContent, err := filesystem.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return shared.ConfirmationDetails{
+			Title:   fmt.Sprintf("Create %s", filePath),
+			Details: diff.Unified("/dev/null", filePath, "", content),
+		}, nil
+	}
+	if err != nil {
+		return shared.ConfirmationDetails{}, err
+	}
+	return shared.ConfirmationDetails{
+		Title:   fmt.Sprintf("Write to %s", filePath),
+		Details: diff.Unified(filePath, filePath, string(oldContent), content),
+	}, nil
+}
+
 // Execute executes the write_file tool.
 func (t *WriteFileTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
 	filePath, ok := args["filePath"].(string)
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"gemini-cli-go/internal/shared"
+	"gemini-cli-go/internal/tool"
+)
+
+// ConfirmPrompt is the prompt shown when asking to approve a tool call.
+const ConfirmPrompt = "Allow? [y]es / [n]o / [a]lways: "
+
+// PromptConfirmer asks the user on the terminal before a mutating tool runs.
+type PromptConfirmer struct {
+	reader LineReader
+	out    io.Writer
+}
+
+// NewPromptConfirmer creates a PromptConfirmer that reads answers from reader
+// and shows what the tool will do on out.
+func NewPromptConfirmer(reader LineReader, out io.Writer) *PromptConfirmer {
+	return &PromptConfirmer{reader: reader, out: out}
+}
+
+// Confirm shows details and waits for an answer. Ctrl-C or Ctrl-D at the prompt deny the call.
+func (c *PromptConfirmer) Confirm(ctx context.Context, call shared.FunctionCall, details shared.ConfirmationDetails) (tool.Decision, error) {
+	fmt.Fprintf(c.out, "  ? %s\n", details.Title)
+	if details.Details != "" {
+		fmt.Fprint(c.out, details.Details)
+		if !strings.HasSuffix(details.Details, "\n") {
+			fmt.Fprintln(c.out)
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return tool.Deny, err
+		}
+		answer, err := c.reader.ReadLine()
+		if err == io.EOF {
+			return tool.Deny, nil
+		}
+		if err != nil {
+			return tool.Deny, fmt.Errorf("failed to read answer: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return tool.Approve, nil
+		case "a", "always":
+			return tool.AlwaysApprove, nil
+		case "n", "no":
+			return tool.Deny, nil
+		default:
+			fmt.Fprintln(c.out, "Please answer y, n or a.")
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/shared"
+	"gemini-cli-go/internal/tool"
+)
+
+func TestPromptConfirmer(t *testing.T) {
+	details := shared.ConfirmationDetails{Title: "Write to a.txt", Details: "--- a.txt\n+++ a.txt\n"}
+	call := shared.FunctionCall{Name: "write_file"}
+
+	tests := []struct {
+		answers []string
+		want    tool.Decision
+	}{
+		{[]string{"y"}, tool.Approve},
+		{[]string{"Always"}, tool.AlwaysApprove},
+		{[]string{"maybe", "n"}, tool.Deny},
+		{nil, tool.Deny}, // EOF
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		confirmer := NewPromptConfirmer(&scriptedReader{lines: tt.answers}, &out)
+		got, err := confirmer.Confirm(context.Background(), call, details)
+		if err != nil {
+			t.Fatalf("Confirm(%v) failed: %v", tt.answers, err)
+		}
+		if got != tt.want {
+			t.Errorf("Confirm(%v) = %v, want %v", tt.answers, got, tt.want)
+		}
+		if !strings.Contains(out.String(), "? Write to a.txt\n--- a.txt") {
+			t.Errorf("Expected details to be shown, got:\n%s", out.String())
+		}
+	}
+}
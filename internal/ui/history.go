@@ -0,0 +1,109 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+
+	"gemini-cli-go/internal/config"
+)
+
+const (
+	historyFileName   = "history"
+	maxHistoryEntries = 1000
+)
+
+// DefaultHistoryPath returns the path of the input history file under ~/.gemini.
+func DefaultHistoryPath() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, config.SettingsDirectoryName, historyFileName), nil
+}
+
+// FileHistory is a term.History that persists every entry to a file,
+// so previous inputs are available in the next session.
+type FileHistory struct {
+	path    string
+	entries []string // oldest first
+}
+
+// LoadFileHistory reads the history stored at path. A missing file yields an empty history.
+func LoadFileHistory(path string) (*FileHistory, error) {
+	h := &FileHistory{path: path}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file %s: %w", path, err)
+	}
+
+	if len(h.entries) > maxHistoryEntries {
+		h.entries = h.entries[len(h.entries)-maxHistoryEntries:]
+		// Rewrite the file so it does not grow without bound.
+		data := strings.Join(h.entries, "\n") + "\n"
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			return nil, fmt.Errorf("failed to trim history file %s: %w", path, err)
+		}
+	}
+	return h, nil
+}
+
+// Add records entry and appends it to the history file.
+// Empty entries and repeats of the most recent entry are ignored.
+func (h *FileHistory) Add(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || strings.Contains(entry, "\n") {
+		return
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return
+	}
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxHistoryEntries {
+		h.entries = h.entries[1:]
+	}
+
+	// term.History has no way to report errors; losing a history line is not worth interrupting the session.
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, entry)
+}
+
+// Len returns the number of entries.
+func (h *FileHistory) Len() int {
+	return len(h.entries)
+}
+
+// At returns an entry; index 0 is the most recent.
+func (h *FileHistory) At(idx int) string {
+	if idx < 0 || idx >= len(h.entries) {
+		panic(fmt.Sprintf("history index %d out of range", idx))
+	}
+	return h.entries[len(h.entries)-1-idx]
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history")
+
+	history, err := LoadFileHistory(path)
+	if err != nil {
+		t.Fatalf("LoadFileHistory failed for missing file: %v", err)
+	}
+	if history.Len() != 0 {
+		t.Fatalf("Expected empty history, got %d entries", history.Len())
+	}
+
+	history.Add("first")
+	history.Add("second")
+	history.Add("second") // repeated entries are collapsed
+	history.Add("   ")    // blank entries are ignored
+
+	if history.Len() != 2 {
+		t.Fatalf("Expected 2 entries, got %d", history.Len())
+	}
+	if history.At(0) != "second" || history.At(1) != "first" {
+		t.Errorf("Expected most recent entry first, got %q, %q", history.At(0), history.At(1))
+	}
+
+	// A new session sees the persisted entries.
+	reloaded, err := LoadFileHistory(path)
+	if err != nil {
+		t.Fatalf("LoadFileHistory failed: %v", err)
+	}
+	if reloaded.Len() != 2 || reloaded.At(0) != "second" {
+		t.Errorf("Expected persisted history, got %d entries", reloaded.Len())
+	}
+}
+
+func TestFileHistoryTrimsOldEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	var lines []string
+	for i := 0; i < maxHistoryEntries+10; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := LoadFileHistory(path)
+	if err != nil {
+		t.Fatalf("LoadFileHistory failed: %v", err)
+	}
+	if history.Len() != maxHistoryEntries {
+		t.Fatalf("Expected %d entries, got %d", maxHistoryEntries, history.Len())
+	}
+	if history.At(history.Len()-1) != lines[10] {
+		t.Errorf("Expected the oldest entries to be dropped")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "\n"); got != maxHistoryEntries {
+		t.Errorf("Expected history file to be trimmed to %d lines, got %d", maxHistoryEntries, got)
+	}
+}
@@ -0,0 +1,193 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gemini-cli-go/internal/api"
+	"gemini-cli-go/internal/command"
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// TurnRunner runs one user turn, including any tool calls, against the model.
+// *api.Agent implements it.
+type TurnRunner interface {
+	RunTurn(ctx context.Context, handler api.TurnHandler, parts ...genai.Part) error
+}
+
+// REPL is the interactive read-eval-print loop.
+type REPL struct {
+	reader     LineReader
+	runner     TurnRunner
+	session    *command.Session
+	out        io.Writer
+	interrupts <-chan os.Signal
+}
+
+// NewREPL creates a REPL that reads input from reader and prints to session.Out.
+// Input starting with "/" runs a command from session.Commands and input starting
+// with "!" runs a shell command; anything else is sent to runner.
+// A value received on interrupts cancels the generation or shell command in progress;
+// interrupts received while waiting for input are ignored.
+func NewREPL(reader LineReader, runner TurnRunner, session *command.Session, interrupts <-chan os.Signal) *REPL {
+	return &REPL{
+		reader:     reader,
+		runner:     runner,
+		session:    session,
+		out:        session.Out,
+		interrupts: interrupts,
+	}
+}
+
+// Run reads and handles input until the reader returns io.EOF, /quit is entered or ctx is done.
+func (r *REPL) Run(ctx context.Context) error {
+	fmt.Fprintln(r.out, "Welcome to Gemini CLI! Type /help for commands, Ctrl-C to cancel a response and Ctrl-D to exit.")
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := r.reader.ReadLine()
+		if err == io.EOF {
+			fmt.Fprintln(r.out)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		input := strings.TrimSpace(line)
+		if input == "" {
+			continue
+		}
+		r.drainInterrupts()
+		if err := r.handleInput(ctx, input); errors.Is(err, command.ErrQuit) {
+			return nil
+		}
+	}
+}
+
+// handleInput dispatches one line of input. It only returns command.ErrQuit;
+// other failures are reported to the user.
+func (r *REPL) handleInput(ctx context.Context, input string) error {
+	switch {
+	case strings.HasPrefix(input, "/"):
+		err := r.runCommand(ctx, input)
+		if errors.Is(err, command.ErrQuit) {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+		}
+	case strings.HasPrefix(input, "!"):
+		r.runInterruptible(ctx, "Command cancelled.", func(ctx context.Context) error {
+			return command.RunShellCommand(ctx, strings.TrimSpace(input[1:]), r.out)
+		})
+	default:
+		prompt, err := command.ExpandFileReferences(ctx, input, r.session.Walk, r.session.Classify, r.out)
+		if err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			return nil
+		}
+		r.runInterruptible(ctx, "Generation cancelled.", func(ctx context.Context) error {
+			err := r.runner.RunTurn(ctx, &replHandler{out: r.out}, genai.Text(prompt))
+			fmt.Fprintln(r.out)
+			return err
+		})
+	}
+	return nil
+}
+
+func (r *REPL) runCommand(ctx context.Context, input string) error {
+	if r.session.Commands == nil {
+		return fmt.Errorf("commands are not available")
+	}
+	return r.session.Commands.Execute(ctx, r.session, input)
+}
+
+// runInterruptible runs fn, cancelling its context if an interrupt arrives.
+// cancelledMessage is printed when fn was cancelled by an interrupt.
+func (r *REPL) runInterruptible(ctx context.Context, cancelledMessage string, fn func(ctx context.Context) error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-r.interrupts:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	err := fn(runCtx)
+	if err == nil {
+		return
+	}
+	if errors.Is(runCtx.Err(), context.Canceled) && ctx.Err() == nil {
+		fmt.Fprintln(r.out, cancelledMessage)
+		return
+	}
+	fmt.Fprintf(r.out, "Error: %v\n", err)
+}
+
+// drainInterrupts discards interrupts delivered while no turn was running.
+func (r *REPL) drainInterrupts() {
+	for {
+		select {
+		case <-r.interrupts:
+		default:
+			return
+		}
+	}
+}
+
+// replHandler shows streamed text and tool activity inline.
+type replHandler struct {
+	out io.Writer
+}
+
+func (h *replHandler) OnText(text string) {
+	fmt.Fprint(h.out, text)
+}
+
+func (h *replHandler) OnToolCall(call shared.FunctionCall) {
+	fmt.Fprintf(h.out, "\n  > %s %s\n", call.Name, formatArgs(call.Args))
+}
+
+func (h *replHandler) OnToolResult(response shared.FunctionResponse) {
+	if errMsg, ok := response.Response["error"]; ok {
+		fmt.Fprintf(h.out, "  x %s failed: %v\n", response.Name, errMsg)
+		return
+	}
+	fmt.Fprintf(h.out, "  ✓ %s\n", response.Name)
+}
+
+// formatArgs renders tool arguments on a single line, shortening long values.
+func formatArgs(args map[string]interface{}) string {
+	const maxValueLen = 60
+
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.ReplaceAll(fmt.Sprintf("%v", args[key]), "\n", "\\n")
+		if runes := []rune(value); len(runes) > maxValueLen {
+			value = string(runes[:maxValueLen]) + "..."
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", key, value))
+	}
+	return strings.Join(parts, " ")
+}
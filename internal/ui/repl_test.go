@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gemini-cli-go/internal/api"
+	"gemini-cli-go/internal/command"
+	"gemini-cli-go/internal/shared"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// scriptedReader returns the given lines and then io.EOF.
+type scriptedReader struct {
+	lines []string
+}
+
+func (r *scriptedReader) ReadLine() (string, error) {
+	if len(r.lines) == 0 {
+		return "", io.EOF
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	return line, nil
+}
+
+// fakeRunner records inputs and lets each test decide how a turn behaves.
+type fakeRunner struct {
+	inputs []string
+	turn   func(ctx context.Context, handler api.TurnHandler) error
+}
+
+func (f *fakeRunner) RunTurn(ctx context.Context, handler api.TurnHandler, parts ...genai.Part) error {
+	for _, part := range parts {
+		if text, ok := part.(genai.Text); ok {
+			f.inputs = append(f.inputs, string(text))
+		}
+	}
+	if f.turn != nil {
+		return f.turn(ctx, handler)
+	}
+	return nil
+}
+
+func TestREPLRunsTurnsUntilEOF(t *testing.T) {
+	runner := &fakeRunner{
+		turn: func(ctx context.Context, handler api.TurnHandler) error {
+			handler.OnToolCall(shared.FunctionCall{Name: "read_file", Args: map[string]interface{}{"path": "/tmp/a.txt"}})
+			handler.OnToolResult(shared.FunctionResponse{Name: "read_file", Response: map[string]interface{}{"output": "ok"}})
+			handler.OnText("Hello!")
+			return nil
+		},
+	}
+	var out bytes.Buffer
+	repl := NewREPL(&scriptedReader{lines: []string{"hi", "  ", "bye"}}, runner, &command.Session{Out: &out}, nil)
+
+	if err := repl.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(runner.inputs) != 2 || runner.inputs[0] != "hi" || runner.inputs[1] != "bye" {
+		t.Errorf("Expected turns for 'hi' and 'bye', got %v", runner.inputs)
+	}
+	output := out.String()
+	for _, want := range []string{"> read_file path=/tmp/a.txt", "✓ read_file", "Hello!"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestREPLInterruptCancelsTurn(t *testing.T) {
+	interrupts := make(chan os.Signal, 1)
+	turns := 0
+	runner := &fakeRunner{
+		turn: func(ctx context.Context, handler api.TurnHandler) error {
+			turns++
+			if turns == 1 {
+				interrupts <- os.Interrupt
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			handler.OnText("still alive")
+			return nil
+		},
+	}
+	var out bytes.Buffer
+	repl := NewREPL(&scriptedReader{lines: []string{"long task", "next"}}, runner, &command.Session{Out: &out}, interrupts)
+
+	if err := repl.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	output := out.String()
+	if !strings.Contains(output, "Generation cancelled.") {
+		t.Errorf("Expected cancellation notice, got:\n%s", output)
+	}
+	if !strings.Contains(output, "still alive") {
+		t.Errorf("Expected the REPL to keep running after an interrupt, got:\n%s", output)
+	}
+}
+
+func TestREPLReportsErrors(t *testing.T) {
+	runner := &fakeRunner{
+		turn: func(ctx context.Context, handler api.TurnHandler) error {
+			return errors.New("quota exceeded")
+		},
+	}
+	var out bytes.Buffer
+	repl := NewREPL(&scriptedReader{lines: []string{"hi"}}, runner, &command.Session{Out: &out}, nil)
+
+	if err := repl.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Error: quota exceeded") {
+		t.Errorf("Expected error to be shown, got:\n%s", out.String())
+	}
+}
+
+func TestREPLDispatchesCommandsAndShell(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("remember the milk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &fakeRunner{}
+	var out bytes.Buffer
+	session := &command.Session{Out: &out, Commands: command.NewBuiltinRegistry()}
+	lines := []string{"/help", "/nope", "!echo from-shell", "summarize @" + path, "/quit", "never sent"}
+	repl := NewREPL(&scriptedReader{lines: lines}, runner, session, nil)
+
+	if err := repl.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(runner.inputs) != 1 {
+		t.Fatalf("Expected one turn, got %v", runner.inputs)
+	}
+	if !strings.HasPrefix(runner.inputs[0], "summarize @") || !strings.Contains(runner.inputs[0], "remember the milk") {
+		t.Errorf("Expected the referenced file to be included, got %q", runner.inputs[0])
+	}
+	output := out.String()
+	for _, want := range []string{"/quit", "Error: unknown command /nope", "from-shell"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
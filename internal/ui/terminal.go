@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// LineReader reads one line of user input at a time.
+type LineReader interface {
+	ReadLine() (string, error)
+}
+
+// terminalReader reads lines from a TTY with line editing and history.
+// The terminal is only put in raw mode while a line is being read, so
+// Ctrl-C during generation still raises SIGINT.
+type terminalReader struct {
+	fd       int
+	terminal *term.Terminal
+}
+
+// NewTerminalReader returns a LineReader with line editing for the terminal in.
+func NewTerminalReader(in *os.File, out io.Writer, prompt string, history term.History) LineReader {
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, prompt)
+	if history != nil {
+		terminal.History = history
+	}
+	return &terminalReader{
+		fd:       int(in.Fd()),
+		terminal: terminal,
+	}
+}
+
+// ReadLine reads a line, returning io.EOF on Ctrl-D or Ctrl-C at the prompt.
+func (t *terminalReader) ReadLine() (string, error) {
+	if width, height, err := term.GetSize(t.fd); err == nil && width > 0 {
+		t.terminal.SetSize(width, height)
+	}
+
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(t.fd, state)
+
+	return t.terminal.ReadLine()
+}